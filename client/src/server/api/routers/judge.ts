@@ -38,29 +38,96 @@ export interface DeployResponse {
   image_id: string;
   build_logs: string;
   build_failed: boolean;
+  cache_hit?: boolean;
+  endpoint?: string;
+  startup_latency_ms?: number;
+  terminal_reason?: string;
+  stdout?: string;
+  stderr?: string;
+  logs_truncated?: boolean;
+  exit_code?: number;
+  restart_count?: number;
+  test_report?: TestReport;
+  suite_report?: SuiteReport;
+  tests_passed?: boolean;
 }
-export type AnalyzerResponse = AnalyzerResult;
-
-const REACT_DOCKER_FILE = `FROM node:18-bullseye
 
-WORKDIR /app
-
-COPY package*.json ./
+export type Verdict = "AC" | "WA" | "TLE" | "MLE" | "RE" | "CE";
+
+export interface TestResult {
+  name: string;
+  verdict: Verdict;
+  time_ms: number;
+  peak_memory_kb: number;
+  exit_code: number;
+  first_diff?: { line: number; expected: string; actual: string };
+  message?: string;
+  checker_verdict?: string;
+  stdout?: string;
+  stderr?: string;
+}
 
-RUN npm install
+export interface TestReport {
+  verdict: Verdict;
+  passed: number;
+  total: number;
+  tests: TestResult[];
+}
 
-COPY . .
+export interface SuiteCase {
+  name: string;
+  full_name?: string;
+  status: "passed" | "failed" | "skipped";
+  duration_ms: number;
+  failure?: string;
+}
 
-# Build the React app
-RUN npm run build
+export interface SuiteReport {
+  format: string;
+  success: boolean;
+  total: number;
+  passed: number;
+  failed: number;
+  skipped: number;
+  suites?: {
+    name: string;
+    duration_ms: number;
+    error?: string;
+    cases?: SuiteCase[];
+  }[];
+  error?: string;
+}
+export type AnalyzerResponse = AnalyzerResult;
 
-# Install a lightweight static file server
-RUN npm install -g serve
+type DeployJobStage =
+  | "queued"
+  | "building"
+  | "pushing"
+  | "scheduling"
+  | "running"
+  | "testing"
+  | "completed"
+  | "failed"
+  | "cancelled";
+
+interface DeployJobStatus {
+  job_id: string;
+  stage: DeployJobStage;
+  error?: string;
+  result?: DeployResponse;
+}
 
-EXPOSE 3000
+const DEPLOY_POLL_INTERVAL_MS = 2000;
+const DEPLOY_TIMEOUT_MS = 10 * 60 * 1000;
 
-CMD ["serve", "-s", "build", "-l", "3000"]
-`;
+const DEPLOY_PROFILES: Record<
+  (typeof Problem.$inferSelect)["category"],
+  string
+> = {
+  React: "react",
+  Python: "python",
+  "C++": "cpp",
+};
 
 export const judgeRouter = createTRPCRouter({
   submit: protectedProcedure
@@ -104,22 +171,40 @@ export const judgeRouter = createTRPCRouter({
 
         // Build docker image
         try {
-          const response = await fetch(`${env.AGENT_SERVER_URL}/deploy`, {
-            method: "POST",
-            headers: {
-              "Content-Type": "application/json",
-            },
-            body: JSON.stringify({
-              docker_file: REACT_DOCKER_FILE,
-              base64TarFile: tarArchiveBase64,
-            }),
+          const requestDeploy = (buildSpec: Record<string, string>) =>
+            fetch(`${env.AGENT_SERVER_URL}/deploy`, {
+              method: "POST",
+              headers: {
+                "Content-Type": "application/json",
+              },
+              body: JSON.stringify({
+                ...buildSpec,
+                base64TarFile: tarArchiveBase64,
+              }),
+            });
+          // A problem with a package on the server builds with the
+          // package's profile, hidden tests and checker. Problems without
+          // one are not found there and build with their category's profile.
+          let response = await requestDeploy({
+            problem_id: String(input.problemId),
           });
-          deployFetchOk = response.ok;
-          try {
-            deployResponse = (await response.json()) as DeployResponse;
-          } catch (error) {
-            console.warn("Failed to parse deploy response:", error);
-            console.log("Deploy Response: ", deployResponse);
+          if (response.status === 404) {
+            await response.body?.cancel();
+            response = await requestDeploy({
+              profile: DEPLOY_PROFILES[problem?.category ?? "React"],
+            });
+          }
+          if (response.ok) {
+            const job = (await response.json()) as DeployJobStatus;
+            const finalStatus = await waitForDeployJob(job.job_id);
+            deployFetchOk =
+              finalStatus.stage === "running" ||
+              finalStatus.stage === "completed";
+            deployResponse = finalStatus.result ?? null;
+          } else {
+            console.warn(
+              `Deploy request failed: ${response.status} ${response.statusText}`,
+            );
           }
         } catch (error) {
           console.warn("Deploy request failed:", error);
@@ -194,7 +279,12 @@ export const judgeRouter = createTRPCRouter({
             problemId: input.problemId,
             accountId: userAccount.id,
             submittedCode: normalizedFiles,
-            status: buildFailed || !analyzerResult ? "failure" : "success",
+            status:
+              buildFailed ||
+              deployResponse?.tests_passed === false ||
+              !analyzerResult
+                ? "failure"
+                : "success",
             chatHistory: input.chatHistory,
             analysis: analyzerResult,
           })
@@ -230,16 +320,18 @@ export const judgeRouter = createTRPCRouter({
         );
         throw error;
       } finally {
-        const containerId =
-          deployResponse?.container_id ?? deployResponse?.container_name;
-        if (containerId) {
+        const containerName = deployResponse?.container_name;
+        if (containerName) {
           try {
             const response = await fetch(`${env.AGENT_SERVER_URL}/shutdown`, {
               method: "POST",
               headers: {
                 "Content-Type": "application/json",
               },
-              body: JSON.stringify({ container_id: containerId }),
+              body: JSON.stringify({
+                container_id: containerName,
+                delete_image: true,
+              }),
             });
             if (!response.ok) {
               console.warn(
@@ -351,6 +443,31 @@ export const judgeRouter = createTRPCRouter({
   }),
 });
 
+async function waitForDeployJob(jobId: string): Promise<DeployJobStatus> {
+  const deadline = Date.now() + DEPLOY_TIMEOUT_MS;
+  const jobUrl = `${env.AGENT_SERVER_URL}/deploy/${encodeURIComponent(jobId)}`;
+
+  while (Date.now() < deadline) {
+    const response = await fetch(jobUrl);
+    if (!response.ok) {
+      throw new Error(`Deploy status request failed: ${response.status}`);
+    }
+    const status = (await response.json()) as DeployJobStatus;
+    if (
+      status.stage === "running" ||
+      status.stage === "completed" ||
+      status.stage === "failed" ||
+      status.stage === "cancelled"
+    ) {
+      return status;
+    }
+    await new Promise((resolve) => setTimeout(resolve, DEPLOY_POLL_INTERVAL_MS));
+  }
+
+  await fetch(jobUrl, { method: "DELETE" }).catch(() => undefined);
+  throw new Error("Deploy timed out.");
+}
+
 function normalizeSandpackFiles(
   sandpackFiles: SandpackFiles,
 ): Record<string, string> {
@@ -435,6 +552,7 @@ function parseAnalyzerResult(bodyText: string): AnalyzerResponse | null {
           parts?: Array<{
             kind?: string;
             text?: string;
+            data?: unknown;
           }>;
         }>;
       };
@@ -443,6 +561,10 @@ function parseAnalyzerResult(bodyText: string): AnalyzerResponse | null {
     const parts = response.result?.artifacts?.flatMap(
       (artifact) => artifact.parts ?? [],
     );
+    const dataPart = parts?.find((part) => part.kind === "data")?.data;
+    if (dataPart) return dataPart as AnalyzerResponse;
+
+    // Older judge servers answer with the JSON as text.
     const textPart = parts?.find((part) => part.kind === "text")?.text;
     if (!textPart) return null;
 
@@ -2,4 +2,5 @@ export interface AnalyzerResult {
   buildTime: string;
   buildScore: { score: number; rationale: string };
   tokenEfficiency: { score: number; rationale: string };
+  verdict?: "pass" | "partial" | "fail";
 }
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"main/judge-agent/mcptransport"
+)
+
+// maxDeployEnvelopeBytes bounds the non-archive part of a deploy request.
+const maxDeployEnvelopeBytes = 1 << 20
+
+// deployRequestError is a client error found while reading a deploy request.
+type deployRequestError struct {
+	status  int
+	code    string
+	message string
+}
+
+func (e *deployRequestError) Error() string {
+	return e.message
+}
+
+// readDeployRequest accepts either the JSON body with a base64url archive or
+// a multipart/form-data upload with docker_file, profile, problem_id and
+// context parts, plus optional tests, limits, checker and run_test_suite
+// parts holding JSON. Either way the archive comes back as a normalized tar.
+func readDeployRequest(w http.ResponseWriter, r *http.Request, limits mcptransport.ContextLimits) (deployRequest, *bytes.Buffer, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		r.Body = http.MaxBytesReader(w, r.Body, limits.MaxArchiveBytes()+maxDeployEnvelopeBytes)
+		return readMultipartDeployRequest(r, limits)
+	}
+
+	// Leave room for the Dockerfile and the rest of the JSON envelope.
+	r.Body = http.MaxBytesReader(w, r.Body, limits.MaxEncodedBytes()+maxDeployEnvelopeBytes)
+	var payload deployRequest
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return deployRequest{}, nil, err
+		}
+		return deployRequest{}, nil, &deployRequestError{http.StatusBadRequest, "invalid_json", "invalid json body"}
+	}
+	if payload.Base64TarFile == "" {
+		return payload, &bytes.Buffer{}, nil
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(payload.Base64TarFile)
+	if err != nil {
+		return deployRequest{}, nil, &deployRequestError{http.StatusBadRequest, "invalid_build_context", "invalid base64TarFile"}
+	}
+	payload.Base64TarFile = ""
+	buildContents, err := mcptransport.NormalizeArchive(bytes.NewReader(decoded), "", limits)
+	if err != nil {
+		return deployRequest{}, nil, err
+	}
+	return payload, buildContents, nil
+}
+
+func readMultipartDeployRequest(r *http.Request, limits mcptransport.ContextLimits) (deployRequest, *bytes.Buffer, error) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return deployRequest{}, nil, &deployRequestError{http.StatusBadRequest, "invalid_multipart", err.Error()}
+	}
+
+	var payload deployRequest
+	buildContents := &bytes.Buffer{}
+	seenContext := false
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return deployRequest{}, nil, multipartError(err)
+		}
+
+		switch part.FormName() {
+		case "docker_file", "profile", "problem_id", "tests", "limits", "checker", "run_test_suite":
+			value, err := io.ReadAll(io.LimitReader(part, maxDeployEnvelopeBytes+1))
+			if err != nil {
+				return deployRequest{}, nil, multipartError(err)
+			}
+			if len(value) > maxDeployEnvelopeBytes {
+				return deployRequest{}, nil, &deployRequestError{
+					http.StatusRequestEntityTooLarge, "field_too_large",
+					fmt.Sprintf("%s exceeds %d bytes", part.FormName(), maxDeployEnvelopeBytes),
+				}
+			}
+			switch part.FormName() {
+			case "docker_file":
+				payload.DockerFile = string(value)
+			case "profile":
+				payload.Profile = strings.TrimSpace(string(value))
+			case "problem_id":
+				payload.ProblemID = strings.TrimSpace(string(value))
+			case "tests":
+				err = json.Unmarshal(value, &payload.Tests)
+			case "limits":
+				err = json.Unmarshal(value, &payload.Limits)
+			case "checker":
+				err = json.Unmarshal(value, &payload.Checker)
+			case "run_test_suite":
+				err = json.Unmarshal(value, &payload.RunTestSuite)
+			}
+			if err != nil {
+				return deployRequest{}, nil, &deployRequestError{
+					http.StatusBadRequest, "invalid_" + part.FormName(),
+					fmt.Sprintf("%s is not valid JSON: %v", part.FormName(), err),
+				}
+			}
+		case "context":
+			if seenContext {
+				return deployRequest{}, nil, &deployRequestError{http.StatusBadRequest, "duplicate_context", "only one context part is allowed"}
+			}
+			seenContext = true
+			buildContents, err = mcptransport.NormalizeArchive(part, part.Header.Get("Content-Type"), limits)
+			if err != nil {
+				return deployRequest{}, nil, multipartError(err)
+			}
+		}
+		part.Close()
+	}
+	return payload, buildContents, nil
+}
+
+// multipartError keeps size and context rejections intact and reports any
+// other read failure as a malformed upload.
+func multipartError(err error) error {
+	var tooLarge *http.MaxBytesError
+	var contextErr *mcptransport.ContextError
+	if errors.As(err, &tooLarge) || errors.As(err, &contextErr) {
+		return err
+	}
+	return &deployRequestError{http.StatusBadRequest, "invalid_multipart", err.Error()}
+}
+
+func writeDeployRequestError(w http.ResponseWriter, err error) {
+	var requestErr *deployRequestError
+	var tooLarge *http.MaxBytesError
+	var contextErr *mcptransport.ContextError
+	switch {
+	case errors.As(err, &requestErr):
+		writeJSONError(w, requestErr.status, requestErr.code, requestErr.message)
+	case errors.As(err, &tooLarge):
+		writeJSONError(w, http.StatusRequestEntityTooLarge, "request_too_large",
+			fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit))
+	case errors.As(err, &contextErr):
+		status := http.StatusBadRequest
+		if contextErr.TooLarge() {
+			status = http.StatusRequestEntityTooLarge
+		}
+		writeJSON(w, status, errorResponse{
+			Code:    contextErr.Reason,
+			Error:   contextErr.Error(),
+			Details: contextErr,
+		})
+	default:
+		writeJSONError(w, http.StatusBadRequest, "invalid_request", err.Error())
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/a2aproject/a2a-go/a2a"
+	"github.com/a2aproject/a2a-go/a2asrv"
+
+	"google.golang.org/adk/agent"
+	"google.golang.org/adk/runner"
+	"google.golang.org/adk/server/adka2a"
+	"google.golang.org/adk/session"
+)
+
+// Endpoint is an agent served over A2A JSON-RPC: its card, and the handler
+// to mount at the card's URL.
+type Endpoint struct {
+	Card    *a2a.AgentCard
+	Handler http.Handler
+}
+
+// NewEndpoint serves a at url, running it with an in-memory session
+// service. afterEvent, when not nil, sees each A2A event before it is sent.
+func NewEndpoint(a agent.Agent, url string, afterEvent adka2a.AfterEventCallback) *Endpoint {
+	executor := adka2a.NewExecutor(adka2a.ExecutorConfig{
+		RunnerConfig: runner.Config{
+			AppName:        a.Name(),
+			Agent:          a,
+			SessionService: session.InMemoryService(),
+		},
+		AfterEventCallback: afterEvent,
+	})
+	return &Endpoint{
+		Card: &a2a.AgentCard{
+			Name:               a.Name(),
+			Description:        a.Description(),
+			Skills:             adka2a.BuildAgentSkills(a),
+			PreferredTransport: a2a.TransportProtocolJSONRPC,
+			URL:                url,
+			Capabilities:       a2a.AgentCapabilities{Streaming: true},
+		},
+		Handler: a2asrv.NewJSONRPCHandler(a2asrv.NewHandler(executor)),
+	}
+}
+
+// NewAnalyzerEndpoint serves a new analyzer agent at url, answering with
+// its analysis as a DataPart. The model comes from models.New, so a test
+// can run the endpoint offline after models.UseModel.
+func NewAnalyzerEndpoint(ctx context.Context, url string) (*Endpoint, error) {
+	a, err := NewAnalyzerAgent(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return NewEndpoint(a, url, AnalysisArtifact), nil
+}
+
+// BearerScheme names the security scheme of endpoints behind RequireToken.
+const BearerScheme a2a.SecuritySchemeName = "bearer"
+
+// RequireToken makes the endpoint answer only requests carrying token as a
+// bearer token, and advertises that on its card.
+func (e *Endpoint) RequireToken(token string) {
+	e.Card.SecuritySchemes = a2a.NamedSecuritySchemes{
+		BearerScheme: a2a.HTTPAuthSecurityScheme{Scheme: "Bearer"},
+	}
+	e.Card.Security = []a2a.SecurityRequirements{{BearerScheme: a2a.SecuritySchemeScopes{}}}
+	next := e.Handler
+	e.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scheme, credential, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+		if token == "" || !strings.EqualFold(scheme, "Bearer") || subtle.ConstantTimeCompare([]byte(strings.TrimSpace(credential)), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="judge"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
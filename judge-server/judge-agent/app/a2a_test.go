@@ -0,0 +1,156 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/a2aproject/a2a-go/a2a"
+	"github.com/a2aproject/a2a-go/a2aclient"
+
+	"main/judge-agent/models"
+)
+
+// serve mounts endpoint on a test server whose address is known before the
+// endpoint is built, as the card needs it.
+func serve(t *testing.T, build func(url string) *Endpoint) (*Endpoint, *httptest.Server) {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(nil)
+	endpoint := build("http://" + srv.Listener.Addr().String())
+	srv.Config.Handler = endpoint.Handler
+	srv.Start()
+	t.Cleanup(srv.Close)
+	return endpoint, srv
+}
+
+func sendText(t *testing.T, endpoint *Endpoint, client *http.Client, text string) (a2a.SendMessageResult, error) {
+	t.Helper()
+	ctx := context.Background()
+	a2aClient, err := a2aclient.NewFromCard(ctx, endpoint.Card, a2aclient.WithJSONRPCTransport(client))
+	if err != nil {
+		t.Fatalf("NewFromCard: %v", err)
+	}
+	return a2aClient.SendMessage(ctx, &a2a.MessageSendParams{
+		Message: a2a.NewMessage(a2a.MessageRoleUser, a2a.TextPart{Text: text}),
+	})
+}
+
+type bearer string
+
+func (b bearer) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.Header.Set("Authorization", "Bearer "+string(b))
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestRequireToken(t *testing.T) {
+	models.UseModel(DockerAgentName, &models.FakeModel{Script: []models.FakeTurn{{Text: "deployed"}}})
+	t.Cleanup(func() { models.UseModel(DockerAgentName, nil) })
+
+	endpoint, srv := serve(t, func(url string) *Endpoint {
+		a, err := NewDockerAgent(context.Background())
+		if err != nil {
+			t.Fatalf("NewDockerAgent: %v", err)
+		}
+		endpoint := NewEndpoint(a, url, nil)
+		endpoint.RequireToken("s3cret")
+		return endpoint
+	})
+
+	if _, ok := endpoint.Card.SecuritySchemes[BearerScheme]; !ok || len(endpoint.Card.Security) != 1 {
+		t.Errorf("card does not advertise the bearer scheme: %+v", endpoint.Card)
+	}
+
+	for _, header := range []string{"", "Bearer wrong", "Basic s3cret", "Bearer "} {
+		req, _ := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader(`{}`))
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("Authorization %q: status %d, want 401", header, resp.StatusCode)
+		}
+	}
+
+	if _, err := sendText(t, endpoint, &http.Client{Transport: bearer("s3cret")}, "deploy this"); err != nil {
+		t.Fatalf("SendMessage with the token: %v", err)
+	}
+}
+
+func TestAnalyzerEndpoint(t *testing.T) {
+	const analysis = `{"buildScore": {"score": 90, "rationale": "builds cleanly"}, "buildTime": "1m 5s",
+		"tokenEfficiency": {"score": 70, "rationale": "a few detours"}, "verdict": "pass"}`
+	fake := &models.FakeModel{Script: []models.FakeTurn{
+		{Text: "Looks good to me."},
+		{Text: analysis},
+	}}
+	models.UseModel(AnalyzerAgentName, fake)
+	t.Cleanup(func() { models.UseModel(AnalyzerAgentName, nil) })
+
+	endpoint, _ := serve(t, func(url string) *Endpoint {
+		endpoint, err := NewAnalyzerEndpoint(context.Background(), url)
+		if err != nil {
+			t.Fatalf("NewAnalyzerEndpoint: %v", err)
+		}
+		return endpoint
+	})
+	result, err := sendText(t, endpoint, http.DefaultClient, "build logs: ok")
+	if err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+
+	task, ok := result.(*a2a.Task)
+	if !ok {
+		t.Fatalf("result is %T, want *a2a.Task", result)
+	}
+	if task.Status.State != a2a.TaskStateCompleted {
+		t.Errorf("task state = %s, want completed", task.Status.State)
+	}
+	var data []map[string]any
+	for _, artifact := range task.Artifacts {
+		for _, part := range artifact.Parts {
+			if part, ok := part.(a2a.DataPart); ok {
+				data = append(data, part.Data)
+			}
+		}
+	}
+	if len(data) != 1 {
+		t.Fatalf("got %d data parts in %+v, want 1", len(data), task.Artifacts)
+	}
+	want, err := ParseAnalysis(analysis)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := json.Marshal(data[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ParseAnalysis(string(encoded))
+	if err != nil || got != want {
+		t.Errorf("data part = %s (%v), want %+v", encoded, err, want)
+	}
+	if requests := fake.Requests(); len(requests) != 2 {
+		t.Errorf("model was called %d times, want 2 (one retry for the invalid reply)", len(requests))
+	}
+}
@@ -0,0 +1,231 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/a2aproject/a2a-go/a2a"
+	"google.golang.org/genai"
+
+	"google.golang.org/adk/model"
+	"google.golang.org/adk/server/adka2a"
+	"google.golang.org/adk/session"
+)
+
+// Analysis verdicts.
+const (
+	VerdictPass    = "pass"
+	VerdictPartial = "partial"
+	VerdictFail    = "fail"
+)
+
+// analysisRetries bounds how many times the analyzer is asked to correct
+// output that does not match AnalysisSchema.
+const analysisRetries = 2
+
+// Score is a 0-100 grade with the reasoning behind it.
+type Score struct {
+	Score     int    `json:"score"`
+	Rationale string `json:"rationale"`
+}
+
+// Analysis is what submission_analyzer returns for a submission.
+type Analysis struct {
+	BuildScore      Score  `json:"buildScore"`
+	BuildTime       string `json:"buildTime"`
+	TokenEfficiency Score  `json:"tokenEfficiency"`
+	Verdict         string `json:"verdict"`
+}
+
+// AnalysisSchema is the response schema the analyzer model must follow.
+var AnalysisSchema = &genai.Schema{
+	Type: genai.TypeObject,
+	Properties: map[string]*genai.Schema{
+		"buildScore": scoreSchema("overall build likelihood and completeness, from the logs and code"),
+		"buildTime": {
+			Type:        genai.TypeString,
+			Description: `estimated build time as a short string, for example "2m 15s"`,
+		},
+		"tokenEfficiency": scoreSchema("how efficiently tokens were used to reach the solution"),
+		"verdict": {
+			Type:        genai.TypeString,
+			Description: "overall verdict on the submission",
+			Enum:        []string{VerdictPass, VerdictPartial, VerdictFail},
+		},
+	},
+	PropertyOrdering: []string{"buildScore", "buildTime", "tokenEfficiency", "verdict"},
+	Required:         []string{"buildScore", "buildTime", "tokenEfficiency", "verdict"},
+}
+
+func scoreSchema(description string) *genai.Schema {
+	return &genai.Schema{
+		Type:        genai.TypeObject,
+		Description: description,
+		Properties: map[string]*genai.Schema{
+			"score":     {Type: genai.TypeInteger, Minimum: genai.Ptr[float64](0), Maximum: genai.Ptr[float64](100)},
+			"rationale": {Type: genai.TypeString, Description: "a short rationale for the score"},
+		},
+		PropertyOrdering: []string{"score", "rationale"},
+		Required:         []string{"score", "rationale"},
+	}
+}
+
+// ParseAnalysis decodes and validates model output against AnalysisSchema.
+func ParseAnalysis(text string) (Analysis, error) {
+	var analysis Analysis
+	decoder := json.NewDecoder(strings.NewReader(strings.TrimSpace(text)))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&analysis); err != nil {
+		return Analysis{}, fmt.Errorf("not a JSON analysis object: %w", err)
+	}
+	if decoder.More() {
+		return Analysis{}, errors.New("unexpected text after the JSON object")
+	}
+	// Decoding cannot tell a missing field from a zero one.
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(text), &fields); err != nil {
+		return Analysis{}, err
+	}
+	var missing []string
+	for _, name := range AnalysisSchema.Required {
+		if _, ok := fields[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return Analysis{}, fmt.Errorf("missing %s", strings.Join(missing, ", "))
+	}
+	var problems []string
+	for name, score := range map[string]Score{"buildScore": analysis.BuildScore, "tokenEfficiency": analysis.TokenEfficiency} {
+		if score.Score < 0 || score.Score > 100 {
+			problems = append(problems, fmt.Sprintf("%s.score must be between 0 and 100", name))
+		}
+		if strings.TrimSpace(score.Rationale) == "" {
+			problems = append(problems, fmt.Sprintf("%s.rationale is empty", name))
+		}
+	}
+	if strings.TrimSpace(analysis.BuildTime) == "" {
+		problems = append(problems, "buildTime is empty")
+	}
+	if !slices.Contains(AnalysisSchema.Properties["verdict"].Enum, analysis.Verdict) {
+		problems = append(problems, fmt.Sprintf("verdict must be one of %s", strings.Join(AnalysisSchema.Properties["verdict"].Enum, ", ")))
+	}
+	if len(problems) > 0 {
+		slices.Sort(problems)
+		return Analysis{}, errors.New(strings.Join(problems, "; "))
+	}
+	return analysis, nil
+}
+
+// validatedModel asks its model again, saying what was wrong, while the
+// final text of a response fails validate. After retries more attempts it
+// answers with an error response, which fails the A2A task.
+type validatedModel struct {
+	model.LLM
+	validate func(text string) error
+	retries  int
+}
+
+func (m validatedModel) GenerateContent(ctx context.Context, req *model.LLMRequest, _ bool) iter.Seq2[*model.LLMResponse, error] {
+	return func(yield func(*model.LLMResponse, error) bool) {
+		// Validation needs the whole reply, so never stream.
+		attempt := *req
+		attempt.Contents = slices.Clone(req.Contents)
+		for try := 0; ; try++ {
+			var final *model.LLMResponse
+			for resp, err := range m.LLM.GenerateContent(ctx, &attempt, false) {
+				if err != nil {
+					yield(nil, err)
+					return
+				}
+				final = resp
+			}
+			if final == nil || final.ErrorCode != "" || final.Content == nil || hasFunctionCalls(final.Content) {
+				yield(final, nil)
+				return
+			}
+			err := m.validate(responseText(final.Content))
+			if err == nil {
+				yield(final, nil)
+				return
+			}
+			if try >= m.retries {
+				log.Printf("%s: output still invalid after %d retries: %v", m.Name(), m.retries, err)
+				yield(&model.LLMResponse{
+					ErrorCode:     "INVALID_OUTPUT",
+					ErrorMessage:  fmt.Sprintf("model output does not match the response schema: %v", err),
+					UsageMetadata: final.UsageMetadata,
+				}, nil)
+				return
+			}
+			log.Printf("%s: invalid output, retrying (%d of %d): %v", m.Name(), try+1, m.retries, err)
+			attempt.Contents = append(attempt.Contents, final.Content, genai.NewContentFromText(
+				fmt.Sprintf("Your previous reply was rejected: %v. Reply again with only the JSON object the response schema describes.", err),
+				genai.RoleUser,
+			))
+		}
+	}
+}
+
+func hasFunctionCalls(content *genai.Content) bool {
+	return slices.ContainsFunc(content.Parts, func(part *genai.Part) bool { return part.FunctionCall != nil })
+}
+
+func responseText(content *genai.Content) string {
+	var b strings.Builder
+	for _, part := range content.Parts {
+		if part.Text != "" && !part.Thought {
+			b.WriteString(part.Text)
+		}
+	}
+	return b.String()
+}
+
+// AnalysisArtifact is an adka2a.AfterEventCallback that turns the
+// analyzer's JSON text into a typed DataPart, so A2A clients read fields
+// rather than scrape text.
+func AnalysisArtifact(_ adka2a.ExecutorContext, _ *session.Event, processed *a2a.TaskArtifactUpdateEvent) error {
+	if processed == nil || processed.Artifact == nil {
+		return nil
+	}
+	for i, part := range processed.Artifact.Parts {
+		text, ok := part.(a2a.TextPart)
+		if !ok {
+			continue
+		}
+		analysis, err := ParseAnalysis(text.Text)
+		if err != nil {
+			continue
+		}
+		encoded, err := json.Marshal(analysis)
+		if err != nil {
+			return err
+		}
+		var data map[string]any
+		if err := json.Unmarshal(encoded, &data); err != nil {
+			return err
+		}
+		processed.Artifact.Parts[i] = a2a.DataPart{Data: data, Metadata: text.Metadata}
+	}
+	return nil
+}
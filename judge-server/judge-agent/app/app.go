@@ -17,26 +17,33 @@ package app
 import (
 	"context"
 	"fmt"
-	"os"
-
-	"google.golang.org/genai"
+	"strings"
 
 	"google.golang.org/adk/agent"
 	"google.golang.org/adk/agent/llmagent"
 	"google.golang.org/adk/cmd/launcher"
 	"google.golang.org/adk/cmd/launcher/full"
-	"google.golang.org/adk/model/gemini"
 	"google.golang.org/adk/tool"
 	"google.golang.org/adk/tool/mcptoolset"
 
 	"main/judge-agent/mcptransport"
+	"main/judge-agent/models"
+	"main/judge-agent/profiles"
+)
+
+// Agent names, which also select each agent's models in the model config
+// and for models.UseModel.
+const (
+	HelperAgentName       = "helper_agent"
+	DockerAgentName       = "docker_agent"
+	PlannerAgentName      = "planner_agent"
+	OrchestratorAgentName = "judge_orchestrator"
+	AnalyzerAgentName     = "submission_analyzer"
 )
 
 // Run wires up the agent, toolsets, and launcher, then executes the CLI.
 func Run(ctx context.Context, args []string) error {
-	model, err := gemini.NewModel(ctx, "gemini-2.5-flash", &genai.ClientConfig{
-		APIKey: os.Getenv("GOOGLE_API_KEY"),
-	})
+	model, err := models.New(ctx, HelperAgentName)
 	if err != nil {
 		return fmt.Errorf("failed to create model: %w", err)
 	}
@@ -51,7 +58,7 @@ func Run(ctx context.Context, args []string) error {
 	}
 
 	a, err := llmagent.New(llmagent.Config{
-		Name:        "helper_agent",
+		Name:        HelperAgentName,
 		Model:       model,
 		Description: "Helper agent.",
 		Instruction: "You are a helpful assistant that helps users with various tasks.",
@@ -74,139 +81,186 @@ func Run(ctx context.Context, args []string) error {
 	return nil
 }
 
-func NewDockerAgent(ctx context.Context) agent.Agent {
-	model, err := gemini.NewModel(ctx, "gemini-2.5-flash", &genai.ClientConfig{
-		APIKey: os.Getenv("GOOGLE_API_KEY"),
-	})
+// NewDockerAgent and NewPlannerAgent are served over A2A, so their tools
+// cannot read the server's filesystem.
+func NewDockerAgent(ctx context.Context) (agent.Agent, error) {
+	model, err := models.New(ctx, DockerAgentName)
 	if err != nil {
-		panic(fmt.Errorf("failed to create model: %w", err))
+		return nil, fmt.Errorf("failed to create model: %w", err)
 	}
 
-	transport := mcptransport.Local(ctx)
+	transport := mcptransport.Sandboxed(ctx)
 
 	mcpToolSet, err := mcptoolset.New(mcptoolset.Config{
 		Transport: transport,
 	})
 	if err != nil {
-		panic(fmt.Errorf("failed to create MCP tool set: %w", err))
+		return nil, fmt.Errorf("failed to create MCP tool set: %w", err)
 	}
 
+	description, instruction := dockerAgentPrompt()
 	a, err := llmagent.New(llmagent.Config{
-		Name:        "docker_agent",
+		Name:        DockerAgentName,
 		Model:       model,
-		Description: "Deploys containers using a Dockerfile and a tar archive payload.",
-		Instruction: `You are a Docker container deploying agent your job is to receive a dockerfile
-					  and a tar archive of contents that you will use the dockerfile to deploy the contents
-					  of the tar archive into a container`,
+		Description: description,
+		Instruction: instruction,
 		Toolsets: []tool.Toolset{
 			mcpToolSet,
 		},
 	})
 	if err != nil {
-		panic(fmt.Errorf("failed to create agent: %w", err))
+		return nil, fmt.Errorf("failed to create agent: %w", err)
 	}
 
-	return a
+	return a, nil
 }
 
-func NewPlannerAgent(ctx context.Context) agent.Agent {
-	model, err := gemini.NewModel(ctx, "gemini-2.5-flash", &genai.ClientConfig{
-		APIKey: os.Getenv("GOOGLE_API_KEY"),
-	})
+func NewPlannerAgent(ctx context.Context) (agent.Agent, error) {
+	model, err := models.New(ctx, PlannerAgentName)
 	if err != nil {
-		panic(fmt.Errorf("failed to create model: %w", err))
+		return nil, fmt.Errorf("failed to create model: %w", err)
 	}
 
-	transport := mcptransport.Local(ctx)
+	transport := mcptransport.Sandboxed(ctx)
 
 	mcpToolSet, err := mcptoolset.New(mcptoolset.Config{
 		Transport: transport,
 	})
 	if err != nil {
-		panic(fmt.Errorf("failed to create MCP tool set: %w", err))
+		return nil, fmt.Errorf("failed to create MCP tool set: %w", err)
 	}
 
+	description, instruction := plannerAgentPrompt()
 	a, err := llmagent.New(llmagent.Config{
-		Name:        "planner_agent",
+		Name:        PlannerAgentName,
 		Model:       model,
-		Description: "Generates Dockerfiles for a provided directory or project contents.",
-		Instruction: `You are a Planning agent your job is to receive the contents 
-					  of a directory and create a dockerfile to deploy a container
-					  that runs these contents`,
+		Description: description,
+		Instruction: instruction,
 		Toolsets: []tool.Toolset{
 			mcpToolSet,
 		},
 	})
 	if err != nil {
-		panic(fmt.Errorf("failed to create agent: %w", err))
+		return nil, fmt.Errorf("failed to create agent: %w", err)
 	}
 
-	return a
+	return a, nil
 }
 
-func NewOrchestratorAgent(ctx context.Context, subAgents ...agent.Agent) agent.Agent {
-	model, err := gemini.NewModel(ctx, "gemini-2.5-flash", &genai.ClientConfig{
-		APIKey: os.Getenv("GOOGLE_API_KEY"),
-	})
+func NewOrchestratorAgent(ctx context.Context, subAgents ...agent.Agent) (agent.Agent, error) {
+	model, err := models.New(ctx, OrchestratorAgentName)
 	if err != nil {
-		panic(fmt.Errorf("failed to create model: %w", err))
+		return nil, fmt.Errorf("failed to create model: %w", err)
 	}
 
 	a, err := llmagent.New(llmagent.Config{
-		Name:        "judge_orchestrator",
+		Name:        OrchestratorAgentName,
 		Model:       model,
 		Description: "Routes requests to docker_agent or planner_agent based on user intent.",
-		Instruction: `You are the orchestrator. Delegate requests to sub-agents.
-- Use docker_agent when the user provides a Dockerfile and/or a tar archive to deploy or run.
-- Use planner_agent when the user provides directory contents and needs a Dockerfile created.
-- If unclear, ask a brief clarification question.`,
-		SubAgents: subAgents,
+		Instruction: orchestratorInstruction(),
+		SubAgents:   subAgents,
 	})
 	if err != nil {
-		panic(fmt.Errorf("failed to create orchestrator agent: %w", err))
+		return nil, fmt.Errorf("failed to create orchestrator agent: %w", err)
 	}
 
-	return a
+	return a, nil
 }
 
-func NewAnalyzerAgent(ctx context.Context) agent.Agent {
-	model, err := gemini.NewModel(ctx, "gemini-2.5-flash", &genai.ClientConfig{
-		APIKey: os.Getenv("GOOGLE_API_KEY"),
-	})
-	if err != nil {
-		panic(fmt.Errorf("failed to create model: %w", err))
+// The deploy agents' prompts follow MCP_ALLOW_DOCKERFILE: while the
+// server refuses raw Dockerfiles, the planner picks a language profile
+// instead of writing one, and the docker agent deploys with it.
+
+func dockerAgentPrompt() (description, instruction string) {
+	if mcptransport.DockerfilesAllowed() {
+		return "Deploys containers using a Dockerfile and a tar archive payload.",
+			`You are a Docker container deploying agent your job is to receive a dockerfile
+					  and a tar archive of contents that you will use the dockerfile to deploy the contents
+					  of the tar archive into a container`
 	}
+	return "Deploys a tar archive payload with one of the server's language profiles.",
+		fmt.Sprintf(`You are a container deploying agent your job is to receive a language profile
+					  and a tar archive of contents, and deploy the contents with the deploy tool's
+					  profile argument. This server refuses raw Dockerfiles, so never pass docker_file.
+					  The profiles are: %s.`, strings.Join(profiles.Names(), ", "))
+}
 
-	transport := mcptransport.Local(ctx)
+func plannerAgentPrompt() (description, instruction string) {
+	if mcptransport.DockerfilesAllowed() {
+		return "Generates Dockerfiles for a provided directory or project contents.",
+			`You are a Planning agent your job is to receive the contents 
+					  of a directory and create a dockerfile to deploy a container
+					  that runs these contents`
+	}
+	return "Picks the language profile to build a provided directory or project contents with.",
+		fmt.Sprintf(`You are a Planning agent your job is to receive the contents
+					  of a directory and pick the language profile that builds and runs them.
+					  This server refuses raw Dockerfiles, so do not write one.
+					  The profiles are: %s.`, strings.Join(profiles.Names(), ", "))
+}
 
-	mcpToolSet, err := mcptoolset.New(mcptoolset.Config{
-		Transport: transport,
-	})
+func orchestratorInstruction() string {
+	if mcptransport.DockerfilesAllowed() {
+		return `You are the orchestrator. Delegate requests to sub-agents.
+- Use docker_agent when the user provides a Dockerfile and/or a tar archive to deploy or run.
+- Use planner_agent when the user provides directory contents and needs a Dockerfile created.
+- When the user provides a project and wants it running, use planner_agent to create the Dockerfile, then docker_agent to deploy the project with it.
+- If unclear, ask a brief clarification question.`
+	}
+	return `You are the orchestrator. Delegate requests to sub-agents.
+- Use docker_agent when the user provides a tar archive and a language profile to deploy or run.
+- Use planner_agent when the user provides directory contents and needs a language profile picked.
+- When the user provides a project and wants it running, use planner_agent to pick the profile, then docker_agent to deploy the project with it.
+- This server refuses raw Dockerfiles; if the user insists on one, say so.
+- If unclear, ask a brief clarification question.`
+}
+
+// NewJudgeOrchestrator returns the orchestrator with docker_agent and
+// planner_agent as sub-agents. They are new instances, since an agent can
+// only have one parent and the standalone ones are served on their own.
+func NewJudgeOrchestrator(ctx context.Context) (agent.Agent, error) {
+	docker, err := NewDockerAgent(ctx)
+	if err != nil {
+		return nil, err
+	}
+	planner, err := NewPlannerAgent(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return NewOrchestratorAgent(ctx, docker, planner)
+}
+
+// NewAnalyzerAgent grades submissions. Its reply is constrained to
+// AnalysisSchema and validated, with corrective retries, before it leaves
+// the model. It has no tools: Gemini cannot combine a response schema with
+// function calling, and everything it grades arrives in the request.
+func NewAnalyzerAgent(ctx context.Context) (agent.Agent, error) {
+	model, err := models.New(ctx, AnalyzerAgentName)
 	if err != nil {
-		panic(fmt.Errorf("failed to create MCP tool set: %w", err))
+		return nil, fmt.Errorf("failed to create model: %w", err)
 	}
 
 	a, err := llmagent.New((llmagent.Config{
-		Name:        "submission_analyzer",
-		Model:       model,
+		Name: AnalyzerAgentName,
+		Model: validatedModel{
+			LLM:      model,
+			validate: func(text string) error { _, err := ParseAnalysis(text); return err },
+			retries:  analysisRetries,
+		},
 		Description: "Analyzes submission quality, functionality, and buildability.",
 		Instruction: `You are an analyzer. You receive the build logs, the project files as strings, the problem description, and the chat history for the submission.
 					  Analyze the current information provided and grade the submission on:
-					  - BuildScore (0-100): overall build likelihood and completeness based on logs and code.
-					  - BuildTime: estimated build time as a short string (for example: "2m 15s"), based on logs and project scope.
-					  - TokenEfficiency (0-100): how efficiently tokens were used to reach the solution, based on chat history, code, and tokens used.
-					  Include a short rationale for each score and an overall verdict.
-					  Output only raw JSON in the form:
-					  {"buildScore":{"score":0,"rationale":""},"buildTime":"","tokenEfficiency":{"score":0,"rationale":""}}
-					  Do not wrap the JSON in markdown, code fences, or extra commentary.`,
-		Toolsets: []tool.Toolset{
-			mcpToolSet,
-		},
+					  - buildScore (0-100): overall build likelihood and completeness based on logs and code.
+					  - buildTime: estimated build time as a short string (for example: "2m 15s"), based on logs and project scope.
+					  - tokenEfficiency (0-100): how efficiently tokens were used to reach the solution, based on chat history, code, and tokens used.
+					  Include a short rationale for each score and an overall verdict of pass, partial or fail.
+					  Reply with the JSON object described by the response schema.`,
+		OutputSchema: AnalysisSchema,
 	}))
 
 	if err != nil {
-		panic(fmt.Errorf("Failed to create agent: ", err))
+		return nil, fmt.Errorf("failed to create agent: %w", err)
 	}
 
-	return a
+	return a, nil
 }
@@ -0,0 +1,46 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"strings"
+	"testing"
+
+	"main/judge-agent/profiles"
+)
+
+func TestDeployPromptsFollowDockerfileSetting(t *testing.T) {
+	t.Setenv("MCP_ALLOW_DOCKERFILE", "false")
+	_, docker := dockerAgentPrompt()
+	_, planner := plannerAgentPrompt()
+	for name, instruction := range map[string]string{"docker_agent": docker, "planner_agent": planner} {
+		if !strings.Contains(instruction, "refuses raw Dockerfiles") {
+			t.Errorf("%s with Dockerfiles disabled: instruction does not say they are refused:\n%s", name, instruction)
+		}
+		for _, profile := range profiles.Names() {
+			if !strings.Contains(instruction, profile) {
+				t.Errorf("%s with Dockerfiles disabled: instruction does not offer profile %s", name, profile)
+			}
+		}
+	}
+	if orchestrator := orchestratorInstruction(); strings.Contains(orchestrator, "create the Dockerfile") {
+		t.Errorf("orchestrator with Dockerfiles disabled still plans Dockerfiles:\n%s", orchestrator)
+	}
+
+	t.Setenv("MCP_ALLOW_DOCKERFILE", "true")
+	if _, planner := plannerAgentPrompt(); !strings.Contains(planner, "create a dockerfile") {
+		t.Errorf("planner_agent with Dockerfiles allowed does not write one:\n%s", planner)
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deployjobs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// ErrStreamingUnsupported is returned by ServeEvents for a response writer
+// that cannot flush.
+var ErrStreamingUnsupported = errors.New("streaming unsupported")
+
+// ServeEvents streams the job's events to w as Server-Sent Events. Every
+// subscriber gets a replay from the first event, or from just after
+// Last-Event-ID when reconnecting. It returns once the "done" event has
+// been sent or the client has gone away, with the error of a failed write.
+func (j *Job) ServeEvents(w http.ResponseWriter, r *http.Request) error {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return ErrStreamingUnsupported
+	}
+
+	next := 0
+	if lastID, err := strconv.Atoi(r.Header.Get("Last-Event-ID")); err == nil && lastID >= 0 {
+		next = lastID + 1
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		events, changed, finished := j.EventsSince(next)
+		for _, event := range events {
+			data, err := json.Marshal(event.Data)
+			if err != nil {
+				log.Printf("Failed to encode deploy event: %v", err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Name, data); err != nil {
+				return err
+			}
+			next = event.ID + 1
+		}
+		flusher.Flush()
+		if finished {
+			return nil
+		}
+
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return nil
+		}
+	}
+}
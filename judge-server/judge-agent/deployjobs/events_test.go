@@ -0,0 +1,107 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deployjobs
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"main/judge-agent/mcptransport"
+)
+
+// sentEvents returns the id and event name of every event in an SSE body.
+func sentEvents(body string) []string {
+	var sent []string
+	for _, block := range strings.Split(strings.TrimSpace(body), "\n\n") {
+		var id, name string
+		for _, line := range strings.Split(block, "\n") {
+			if value, ok := strings.CutPrefix(line, "id: "); ok {
+				id = value
+			}
+			if value, ok := strings.CutPrefix(line, "event: "); ok {
+				name = value
+			}
+		}
+		if id != "" {
+			sent = append(sent, id+" "+name)
+		}
+	}
+	return sent
+}
+
+func TestServeEventsReplaysAfterLastEventID(t *testing.T) {
+	job := &Job{id: "job", stage: mcptransport.StageQueued, notify: make(chan struct{})}
+	job.setStage(mcptransport.StageBuilding)
+	job.addBuildEvent(mcptransport.BuildEvent{Type: mcptransport.BuildEventLog, Data: "step 1"})
+	job.setStage(mcptransport.StageScheduling)
+	job.finish(mcptransport.StageRunning, &Response{}, "")
+
+	tests := []struct {
+		lastEventID string
+		want        []string
+	}{
+		{"", []string{"0 stage", "1 log", "2 stage", "3 done"}},
+		{"1", []string{"2 stage", "3 done"}},
+		{"3", nil},
+		{"not a number", []string{"0 stage", "1 log", "2 stage", "3 done"}},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/deploy/job/logs", nil)
+		if tt.lastEventID != "" {
+			r.Header.Set("Last-Event-ID", tt.lastEventID)
+		}
+		w := httptest.NewRecorder()
+		if err := job.ServeEvents(w, r); err != nil {
+			t.Fatalf("Last-Event-ID %q: ServeEvents() = %v", tt.lastEventID, err)
+		}
+		if got := w.Header().Get("Content-Type"); got != "text/event-stream" {
+			t.Errorf("Content-Type = %q, want text/event-stream", got)
+		}
+		got := sentEvents(w.Body.String())
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("Last-Event-ID %q: sent %v, want %v", tt.lastEventID, got, tt.want)
+		}
+	}
+}
+
+func TestServeEventsEndsWithDone(t *testing.T) {
+	job := &Job{id: "job", stage: mcptransport.StageQueued, notify: make(chan struct{})}
+	job.setStage(mcptransport.StageBuilding)
+
+	w := httptest.NewRecorder()
+	served := make(chan error, 1)
+	go func() {
+		served <- job.ServeEvents(w, httptest.NewRequest(http.MethodGet, "/deploy/job/logs", nil))
+	}()
+
+	job.setStage(mcptransport.StageScheduling)
+	job.finish(mcptransport.StageFailed, &Response{BuildFailed: true}, "no nodes")
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Fatalf("ServeEvents() = %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("stream stayed open after the done event")
+	}
+	got := sentEvents(w.Body.String())
+	want := []string{"0 stage", "1 stage", "2 done"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("sent %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,279 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package deployjobs runs deploys in the background and keeps each one's
+// stage, result and event log for clients to poll or stream.
+package deployjobs
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"main/judge-agent/judging"
+	"main/judge-agent/mcptransport"
+	"main/judge-agent/testreport"
+)
+
+const (
+	// finishedJobTTL is how long a terminal job stays queryable.
+	finishedJobTTL = time.Hour
+	// maxJobEvents bounds the replay buffer kept for log subscribers.
+	maxJobEvents = 20_000
+)
+
+// Event is one entry in a job's replayable event log. Name is the SSE
+// event name: a build event type, "stage", "truncated" or "done".
+type Event struct {
+	ID   int
+	Name string
+	Data any
+}
+
+// Job is one deploy running in the background.
+type Job struct {
+	mu        sync.Mutex
+	id        string
+	stage     mcptransport.DeployStage
+	errMsg    string
+	result    *Response
+	createdAt time.Time
+	updatedAt time.Time
+	cancel    context.CancelFunc
+	events    []Event
+	truncated bool
+	// done is set by finish, which appends the "done" event.
+	done bool
+	// notify is closed and replaced whenever the job changes.
+	notify chan struct{}
+}
+
+// Status is what clients see of a job: its stage and, once it has
+// finished, its result or error.
+type Status struct {
+	JobID     string                   `json:"job_id"`
+	Stage     mcptransport.DeployStage `json:"stage"`
+	Error     string                   `json:"error,omitempty"`
+	Result    *Response                `json:"result,omitempty"`
+	CreatedAt time.Time                `json:"created_at"`
+	UpdatedAt time.Time                `json:"updated_at"`
+}
+
+// Response is the result of a deploy as the HTTP API returns it.
+type Response struct {
+	ContainerName    string              `json:"container_name"`
+	ContainerID      string              `json:"container_id"`
+	ImageName        string              `json:"image_name"`
+	ImageID          string              `json:"image_id"`
+	BuildLogs        string              `json:"build_logs"`
+	BuildFailed      bool                `json:"build_failed"`
+	CacheHit         bool                `json:"cache_hit"`
+	Endpoint         string              `json:"endpoint,omitempty"`
+	StartupLatencyMs int64               `json:"startup_latency_ms"`
+	TerminalReason   string              `json:"terminal_reason,omitempty"`
+	Stdout           string              `json:"stdout"`
+	Stderr           string              `json:"stderr"`
+	LogsTruncated    bool                `json:"logs_truncated"`
+	ExitCode         *int                `json:"exit_code,omitempty"`
+	RestartCount     int                 `json:"restart_count"`
+	TestReport       *judging.TestReport `json:"test_report,omitempty"`
+	SuiteReport      *testreport.Report  `json:"suite_report,omitempty"`
+	TestsPassed      *bool               `json:"tests_passed,omitempty"`
+}
+
+func (j *Job) setStage(stage mcptransport.DeployStage) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	// Terminal stages are finish's alone, so a job never looks finished
+	// before its result is stored.
+	if j.done || stage.Terminal() {
+		return
+	}
+	j.stage = stage
+	j.updatedAt = time.Now()
+	j.appendEventLocked("stage", map[string]mcptransport.DeployStage{"stage": stage})
+}
+
+func (j *Job) addBuildEvent(event mcptransport.BuildEvent) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.appendEventLocked(string(event.Type), event)
+}
+
+func (j *Job) finish(stage mcptransport.DeployStage, result *Response, errMsg string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.stage = stage
+	j.result = result
+	j.errMsg = errMsg
+	j.done = true
+	j.updatedAt = time.Now()
+	j.appendEventLocked("done", j.statusLocked())
+}
+
+func (j *Job) appendEventLocked(name string, data any) {
+	if len(j.events) >= maxJobEvents && name != "done" {
+		if j.truncated {
+			return
+		}
+		j.truncated = true
+		name, data = "truncated", map[string]int{"max_events": maxJobEvents}
+	}
+	j.events = append(j.events, Event{ID: len(j.events), Name: name, Data: data})
+	close(j.notify)
+	j.notify = make(chan struct{})
+}
+
+// EventsSince returns the events from index from onwards, a channel that is
+// closed on the next change, and whether the job has finished, which is
+// once its "done" event is among the events.
+func (j *Job) EventsSince(from int) ([]Event, <-chan struct{}, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	var events []Event
+	if from < len(j.events) {
+		events = append(events, j.events[from:]...)
+	}
+	return events, j.notify, j.done
+}
+
+func (j *Job) Status() Status {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.statusLocked()
+}
+
+// ID identifies the job in the API.
+func (j *Job) ID() string {
+	return j.id
+}
+
+// Cancel stops the deploy. The job finishes as cancelled once the deploy
+// has returned.
+func (j *Job) Cancel() {
+	j.cancel()
+}
+
+func (j *Job) statusLocked() Status {
+	return Status{
+		JobID:     j.id,
+		Stage:     j.stage,
+		Error:     j.errMsg,
+		Result:    j.result,
+		CreatedAt: j.createdAt,
+		UpdatedAt: j.updatedAt,
+	}
+}
+
+// Store keeps the jobs started on this server until they have been
+// finished for an hour.
+type Store struct {
+	mu   sync.Mutex
+	jobs map[string]*Job
+}
+
+// NewStore returns an empty Store.
+func NewStore() *Store {
+	return &Store{jobs: map[string]*Job{}}
+}
+
+// Start registers a job and runs the deploy in the background. The job owns
+// its own context so it outlives the HTTP request that created it.
+func (s *Store) Start(input mcptransport.Input) *Job {
+	ctx, cancel := context.WithCancel(context.Background())
+	now := time.Now()
+	job := &Job{
+		id:        uuid.NewString(),
+		stage:     mcptransport.StageQueued,
+		createdAt: now,
+		updatedAt: now,
+		cancel:    cancel,
+		notify:    make(chan struct{}),
+	}
+
+	s.mu.Lock()
+	s.pruneLocked(now)
+	s.jobs[job.id] = job
+	s.mu.Unlock()
+
+	ctx = mcptransport.WithStageReporter(ctx, job.setStage)
+	ctx = mcptransport.WithBuildEventSink(ctx, job.addBuildEvent)
+	go s.run(ctx, job, input)
+	return job
+}
+
+func (s *Store) run(ctx context.Context, job *Job, input mcptransport.Input) {
+	defer job.cancel()
+
+	_, output, err := mcptransport.DeployContainer(ctx, nil, input)
+	result := &Response{
+		ContainerName:    output.ContainerName,
+		ContainerID:      output.ContainerID,
+		ImageName:        output.ImageName,
+		ImageID:          output.ImageID,
+		BuildLogs:        output.BuildLogs,
+		BuildFailed:      output.BuildFailed,
+		CacheHit:         output.CacheHit,
+		Endpoint:         output.Endpoint,
+		StartupLatencyMs: output.StartupLatencyMs,
+		TerminalReason:   output.TerminalReason,
+		Stdout:           output.Stdout,
+		Stderr:           output.Stderr,
+		LogsTruncated:    output.LogsTruncated,
+		ExitCode:         output.ExitCode,
+		RestartCount:     output.RestartCount,
+		TestReport:       output.TestReport,
+		SuiteReport:      output.SuiteReport,
+		TestsPassed:      output.TestsPassed,
+	}
+	switch {
+	case errors.Is(ctx.Err(), context.Canceled):
+		if result.ContainerName != "" {
+			// The cancel raced with a successful deploy; don't leak the pod.
+			shutdown := mcptransport.ShutdownInput{ContainerID: result.ContainerName}
+			if _, _, err := mcptransport.ShutdownContainer(context.Background(), nil, shutdown); err != nil {
+				log.Printf("Deploy job %s: failed to remove cancelled pod: %v", job.id, err)
+			}
+		}
+		job.finish(mcptransport.StageCancelled, result, "deploy cancelled")
+	case err != nil:
+		log.Printf("Deploy job %s failed: %v", job.id, err)
+		result.BuildFailed = true
+		job.finish(mcptransport.StageFailed, result, err.Error())
+	case result.TestReport != nil:
+		job.finish(mcptransport.StageCompleted, result, "")
+	default:
+		job.finish(mcptransport.StageRunning, result, "")
+	}
+}
+
+func (s *Store) Get(id string) (*Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	return job, ok
+}
+
+func (s *Store) pruneLocked(now time.Time) {
+	for id, job := range s.jobs {
+		status := job.Status()
+		if status.Stage.Terminal() && now.Sub(status.UpdatedAt) > finishedJobTTL {
+			delete(s.jobs, id)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deployjobs
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"main/judge-agent/mcptransport"
+)
+
+// blockingBuilder holds every build until its context is cancelled.
+type blockingBuilder struct {
+	started chan struct{}
+}
+
+func (b *blockingBuilder) Build(ctx context.Context, req mcptransport.BuildRequest) (mcptransport.BuildResult, error) {
+	close(b.started)
+	<-ctx.Done()
+	return mcptransport.BuildResult{}, ctx.Err()
+}
+
+// waitForDone follows job until it finishes and returns all of its events.
+func waitForDone(t *testing.T, job *Job) []Event {
+	t.Helper()
+	timeout := time.After(10 * time.Second)
+	for {
+		events, changed, finished := job.EventsSince(0)
+		if finished {
+			return events
+		}
+		select {
+		case <-changed:
+		case <-timeout:
+			t.Fatalf("job %s did not finish; status %+v", job.ID(), job.Status())
+		}
+	}
+}
+
+func stageEvents(events []Event) []mcptransport.DeployStage {
+	var stages []mcptransport.DeployStage
+	for _, event := range events {
+		if event.Name == "stage" {
+			stages = append(stages, event.Data.(map[string]mcptransport.DeployStage)["stage"])
+		}
+	}
+	return stages
+}
+
+func TestJobReportsStagesInOrder(t *testing.T) {
+	mcptransport.UseBuilder(&mcptransport.FakeBuilder{Script: []mcptransport.FakeBuildStep{{Log: "compiling"}}})
+	defer mcptransport.UseBuilder(nil)
+	mcptransport.UseRuntime(mcptransport.NewFakeRuntime())
+	defer mcptransport.UseRuntime(nil)
+
+	// A source of its own keeps the build out of the build cache, which
+	// would skip the push.
+	var contents bytes.Buffer
+	tw := tar.NewWriter(&contents)
+	source := []byte("print('" + uuid.NewString() + "')\n")
+	if err := tw.WriteHeader(&tar.Header{Name: "main.py", Typeflag: tar.TypeReg, Mode: 0o644, Size: int64(len(source))}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write(source); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	job := NewStore().Start(mcptransport.Input{Profile: "python", BuildContents: contents})
+	events := waitForDone(t, job)
+
+	want := []mcptransport.DeployStage{mcptransport.StageBuilding, mcptransport.StagePushing, mcptransport.StageScheduling}
+	got := stageEvents(events)
+	if len(got) != len(want) {
+		t.Fatalf("stages = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("stages = %v, want %v", got, want)
+		}
+	}
+	for i, event := range events {
+		if event.ID != i {
+			t.Errorf("event %d has id %d", i, event.ID)
+		}
+	}
+	last := events[len(events)-1]
+	if last.Name != "done" {
+		t.Fatalf("last event = %q, want done", last.Name)
+	}
+	if status := last.Data.(Status); status.Stage != mcptransport.StageRunning || status.Result == nil || status.Error != "" {
+		t.Errorf("done event = %+v, want a running job with its result", status)
+	}
+}
+
+func TestJobIgnoresStagesOnceFinished(t *testing.T) {
+	job := &Job{id: "job", stage: mcptransport.StageQueued, notify: make(chan struct{})}
+
+	// Terminal stages only come from finish, with the result.
+	job.setStage(mcptransport.StageRunning)
+	if stage := job.Status().Stage; stage != mcptransport.StageQueued {
+		t.Errorf("after setStage(running), stage = %s, want queued", stage)
+	}
+
+	job.setStage(mcptransport.StageBuilding)
+	job.finish(mcptransport.StageFailed, &Response{BuildFailed: true}, "build failed")
+	job.setStage(mcptransport.StageScheduling)
+
+	if status := job.Status(); status.Stage != mcptransport.StageFailed || status.Error != "build failed" {
+		t.Errorf("status = %+v, want the failed stage kept", status)
+	}
+	events, _, finished := job.EventsSince(0)
+	if !finished {
+		t.Fatalf("finished job reports it is still running")
+	}
+	if len(events) != 2 || events[0].Name != "stage" || events[1].Name != "done" {
+		t.Errorf("events = %+v, want one stage event and done", events)
+	}
+}
+
+func TestJobCancel(t *testing.T) {
+	builder := &blockingBuilder{started: make(chan struct{})}
+	mcptransport.UseBuilder(builder)
+	defer mcptransport.UseBuilder(nil)
+
+	job := NewStore().Start(mcptransport.Input{Profile: "python"})
+	select {
+	case <-builder.started:
+	case <-time.After(10 * time.Second):
+		t.Fatal("build never started")
+	}
+	job.Cancel()
+	events := waitForDone(t, job)
+
+	if status := job.Status(); status.Stage != mcptransport.StageCancelled || status.Error != "deploy cancelled" {
+		t.Errorf("status = %+v, want a cancelled job", status)
+	}
+	if last := events[len(events)-1]; last.Name != "done" || last.Data.(Status).Stage != mcptransport.StageCancelled {
+		t.Errorf("last event = %+v, want done with the cancelled stage", last)
+	}
+}
@@ -0,0 +1,328 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dockerpolicy checks submitted Dockerfiles against a configurable
+// policy before they reach BuildKit.
+package dockerpolicy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/distribution/reference"
+	"github.com/moby/buildkit/frontend/dockerfile/instructions"
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+)
+
+// Policy lists what a Dockerfile may do. Image patterns use path.Match
+// syntax against the familiar reference, for example "node:*".
+type Policy struct {
+	AllowedBaseImages     []string `json:"allowed_base_images"`
+	ForbiddenInstructions []string `json:"forbidden_instructions"`
+	ForbiddenMountTypes   []string `json:"forbidden_mount_types"`
+	AllowHostNetwork      bool     `json:"allow_host_network"`
+	AllowInsecureSecurity bool     `json:"allow_insecure_security"`
+	AllowRemoteAdd        bool     `json:"allow_remote_add"`
+	MaxStages             int      `json:"max_stages"`
+}
+
+// Default returns the policy used when no policy file is configured.
+func Default() *Policy {
+	return &Policy{
+		AllowedBaseImages: []string{
+			"node:*",
+			"python:*",
+			"gcc:*",
+			"alpine:*",
+			"debian:*",
+			"ubuntu:*",
+			"scratch",
+		},
+		ForbiddenInstructions: []string{"ONBUILD", "VOLUME"},
+		ForbiddenMountTypes:   []string{string(instructions.MountTypeSecret), string(instructions.MountTypeSSH)},
+		MaxStages:             4,
+	}
+}
+
+// LoadFile reads a JSON policy. Fields left out of the file keep their
+// Default values.
+func LoadFile(path string) (*Policy, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read policy %q: %w", path, err)
+	}
+	policy := Default()
+	if err := json.Unmarshal(contents, policy); err != nil {
+		return nil, fmt.Errorf("parse policy %q: %w", path, err)
+	}
+	if err := policy.Check(); err != nil {
+		return nil, fmt.Errorf("policy %q: %w", path, err)
+	}
+	return policy, nil
+}
+
+var (
+	activeOnce   sync.Once
+	activePolicy *Policy
+	activeErr    error
+)
+
+// Active returns the policy named by MCP_DOCKERFILE_POLICY, or Default when
+// the variable is unset. A file that cannot be loaded is an error rather
+// than a quiet fallback to a looser policy.
+func Active() (*Policy, error) {
+	activeOnce.Do(func() {
+		path := strings.TrimSpace(os.Getenv("MCP_DOCKERFILE_POLICY"))
+		if path == "" {
+			activePolicy = Default()
+			return
+		}
+		activePolicy, activeErr = LoadFile(path)
+	})
+	return activePolicy, activeErr
+}
+
+// Check rejects policies that could not be enforced as written. It is
+// named apart from Validate, which checks a Dockerfile against p.
+func (p *Policy) Check() error {
+	if len(p.AllowedBaseImages) == 0 {
+		return fmt.Errorf("allowed_base_images needs at least one pattern")
+	}
+	for _, pattern := range p.AllowedBaseImages {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("allowed_base_images: invalid pattern %q", pattern)
+		}
+	}
+	for _, name := range p.ForbiddenInstructions {
+		if strings.TrimSpace(name) == "" {
+			return fmt.Errorf("forbidden_instructions must not contain empty names")
+		}
+	}
+	for _, mountType := range p.ForbiddenMountTypes {
+		switch instructions.MountType(strings.ToLower(mountType)) {
+		case instructions.MountTypeBind, instructions.MountTypeCache, instructions.MountTypeTmpfs,
+			instructions.MountTypeSecret, instructions.MountTypeSSH:
+		default:
+			return fmt.Errorf("forbidden_mount_types: unknown mount type %q", mountType)
+		}
+	}
+	if p.MaxStages < 0 {
+		return fmt.Errorf("max_stages must not be negative")
+	}
+	return nil
+}
+
+// Violation is a single policy breach located in the Dockerfile.
+type Violation struct {
+	Rule        string `json:"rule"`
+	Line        int    `json:"line,omitempty"`
+	Instruction string `json:"instruction,omitempty"`
+	Message     string `json:"message"`
+}
+
+// ViolationError carries every violation found in a Dockerfile.
+type ViolationError struct {
+	Violations []Violation `json:"violations"`
+}
+
+func (e *ViolationError) Error() string {
+	messages := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		if v.Line > 0 {
+			messages = append(messages, fmt.Sprintf("line %d: %s", v.Line, v.Message))
+		} else {
+			messages = append(messages, v.Message)
+		}
+	}
+	return "dockerfile policy violation: " + strings.Join(messages, "; ")
+}
+
+// Validate parses dockerfile with BuildKit's parser and returns a
+// *ViolationError listing every breach of p, or nil when it complies.
+func (p *Policy) Validate(dockerfile string) error {
+	result, err := parser.Parse(strings.NewReader(dockerfile))
+	if err != nil {
+		return &ViolationError{Violations: []Violation{{Rule: "parse", Message: err.Error()}}}
+	}
+
+	var violations []Violation
+	add := func(rule string, line int, instruction, format string, args ...any) {
+		violations = append(violations, Violation{
+			Rule:        rule,
+			Line:        line,
+			Instruction: instruction,
+			Message:     fmt.Sprintf(format, args...),
+		})
+	}
+
+	forbidden := map[string]bool{}
+	for _, name := range p.ForbiddenInstructions {
+		forbidden[strings.ToLower(name)] = true
+	}
+	for _, node := range result.AST.Children {
+		if forbidden[strings.ToLower(node.Value)] {
+			add("forbidden_instruction", node.StartLine, strings.ToUpper(node.Value),
+				"%s is not allowed", strings.ToUpper(node.Value))
+		}
+	}
+
+	stages, _, err := instructions.Parse(result.AST, nil)
+	if err != nil {
+		return &ViolationError{Violations: append(violations, Violation{Rule: "parse", Message: err.Error()})}
+	}
+	if p.MaxStages > 0 && len(stages) > p.MaxStages {
+		add("max_stages", 0, "FROM", "%d build stages exceed the limit of %d", len(stages), p.MaxStages)
+	}
+
+	for i, stage := range stages {
+		line := rangeLine(stage.Location)
+		if _, isStage := instructions.HasStage(stages[:i], stage.BaseName); !isStage {
+			if msg := p.checkImage(stage.BaseName); msg != "" {
+				add("base_image", line, "FROM", "%s", msg)
+			}
+		}
+		for _, command := range stage.Commands {
+			line := rangeLine(command.Location())
+			switch cmd := command.(type) {
+			case *instructions.RunCommand:
+				violations = append(violations, p.checkRun(cmd, stages[:i], line)...)
+			case *instructions.AddCommand:
+				if p.AllowRemoteAdd {
+					continue
+				}
+				for _, src := range cmd.SourcePaths {
+					if isRemoteSource(src) {
+						add("remote_add", line, "ADD", "ADD of remote source %q is not allowed", src)
+					}
+				}
+			case *instructions.CopyCommand:
+				if cmd.From == "" {
+					continue
+				}
+				if msg := p.checkFrom(cmd.From, stages[:i]); msg != "" {
+					add("copy_from", line, "COPY", "%s", msg)
+				}
+			}
+		}
+	}
+
+	if len(violations) > 0 {
+		return &ViolationError{Violations: violations}
+	}
+	return nil
+}
+
+func (p *Policy) checkRun(cmd *instructions.RunCommand, previous []instructions.Stage, line int) []Violation {
+	var violations []Violation
+	// BuildKit defers parsing mount options until variables are expanded.
+	// Expanding with the identity function keeps build args unresolved, so
+	// mounts that depend on them are rejected rather than guessed at.
+	if err := cmd.Expand(func(word string) (string, error) { return word, nil }); err != nil {
+		return []Violation{{Rule: "run_mount", Line: line, Instruction: "RUN", Message: err.Error()}}
+	}
+	for _, mount := range instructions.GetMounts(cmd) {
+		for _, forbidden := range p.ForbiddenMountTypes {
+			if strings.EqualFold(string(mount.Type), forbidden) {
+				violations = append(violations, Violation{
+					Rule:        "run_mount",
+					Line:        line,
+					Instruction: "RUN",
+					Message:     fmt.Sprintf("RUN --mount=type=%s is not allowed", mount.Type),
+				})
+			}
+		}
+		if mount.From != "" {
+			if msg := p.checkFrom(mount.From, previous); msg != "" {
+				violations = append(violations, Violation{Rule: "run_mount", Line: line, Instruction: "RUN", Message: msg})
+			}
+		}
+	}
+	if !p.AllowHostNetwork && instructions.GetNetwork(cmd) == instructions.NetworkHost {
+		violations = append(violations, Violation{
+			Rule:        "run_network",
+			Line:        line,
+			Instruction: "RUN",
+			Message:     "RUN --network=host is not allowed",
+		})
+	}
+	if !p.AllowInsecureSecurity && instructions.GetSecurity(cmd) == instructions.SecurityInsecure {
+		violations = append(violations, Violation{
+			Rule:        "run_security",
+			Line:        line,
+			Instruction: "RUN",
+			Message:     "RUN --security=insecure is not allowed",
+		})
+	}
+	return violations
+}
+
+// checkFrom validates a --from value, which may name an earlier stage, a
+// stage index or an image.
+func (p *Policy) checkFrom(from string, previous []instructions.Stage) string {
+	if _, ok := instructions.HasStage(previous, from); ok {
+		return ""
+	}
+	if index, err := strconv.Atoi(from); err == nil && index >= 0 && index < len(previous) {
+		return ""
+	}
+	return p.checkImage(from)
+}
+
+// checkImage returns a reason when image is not on the allowlist.
+func (p *Policy) checkImage(image string) string {
+	if strings.Contains(image, "$") {
+		return fmt.Sprintf("image %q must not use build arguments", image)
+	}
+	if image == "scratch" {
+		for _, pattern := range p.AllowedBaseImages {
+			if pattern == "scratch" {
+				return ""
+			}
+		}
+		return "image \"scratch\" is not allowed"
+	}
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return fmt.Sprintf("invalid image reference %q: %v", image, err)
+	}
+	named = reference.TagNameOnly(named)
+	for _, pattern := range p.AllowedBaseImages {
+		if ok, err := reference.FamiliarMatch(pattern, named); err == nil && ok {
+			return ""
+		}
+	}
+	return fmt.Sprintf("image %q is not in the allowed base images", reference.FamiliarString(named))
+}
+
+func isRemoteSource(src string) bool {
+	lower := strings.ToLower(src)
+	for _, prefix := range []string{"http://", "https://", "git://", "git@", "ssh://"} {
+		if strings.HasPrefix(lower, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func rangeLine(ranges []parser.Range) int {
+	if len(ranges) == 0 {
+		return 0
+	}
+	return ranges[0].Start.Line
+}
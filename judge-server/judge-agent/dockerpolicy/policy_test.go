@@ -0,0 +1,127 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dockerpolicy
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name       string
+		dockerfile string
+		rules      []string
+	}{
+		{"allowed image", "FROM python:3.12-slim\nRUN echo ok\n", nil},
+		{"allowed scratch", "FROM scratch\nCOPY app /app\n", nil},
+		{"image off the allowlist", "FROM evil/miner:latest\n", []string{"base_image"}},
+		{"image from another registry", "FROM ghcr.io/library/python:3.12\n", []string{"base_image"}},
+		{"image from a build arg", "ARG BASE=python:3.12\nFROM $BASE\n", []string{"base_image"}},
+		{"earlier stage as base", "FROM gcc:13 AS build\nRUN make\nFROM build\n", nil},
+		{"secret mount", "FROM alpine:3\nRUN --mount=type=secret,id=token cat /run/secrets/token\n", []string{"run_mount"}},
+		{"ssh mount", "FROM alpine:3\nRUN --mount=type=ssh ssh-add -l\n", []string{"run_mount"}},
+		{"cache mount", "FROM alpine:3\nRUN --mount=type=cache,target=/root/.cache echo ok\n", nil},
+		{"mount from an image", "FROM alpine:3\nRUN --mount=from=evil/tools,target=/t ls /t\n", []string{"run_mount"}},
+		{"host network", "FROM alpine:3\nRUN --network=host wget example.com\n", []string{"run_network"}},
+		{"no network", "FROM alpine:3\nRUN --network=none echo ok\n", nil},
+		{"insecure security", "FROM alpine:3\nRUN --security=insecure mount\n", []string{"run_security"}},
+		{"remote ADD over https", "FROM alpine:3\nADD https://example.com/x.tar.gz /x\n", []string{"remote_add"}},
+		{"remote ADD over git", "FROM alpine:3\nADD git@github.com:org/repo.git /repo\n", []string{"remote_add"}},
+		{"local ADD", "FROM alpine:3\nADD app.tar.gz /app\n", nil},
+		{"COPY from an image", "FROM alpine:3\nCOPY --from=evil/tools /bin/x /x\n", []string{"copy_from"}},
+		{"COPY from a stage index", "FROM gcc:13\nRUN make\nFROM alpine:3\nCOPY --from=0 /a.out /a.out\n", nil},
+		{"forbidden instruction", "FROM alpine:3\nONBUILD RUN echo\nVOLUME /data\n", []string{"forbidden_instruction", "forbidden_instruction"}},
+		{"max stages", "FROM alpine:3\nFROM alpine:3\nFROM alpine:3\nFROM alpine:3\nFROM alpine:3\n", []string{"max_stages"}},
+		{"unknown mount type", "FROM alpine:3\nRUN --mount=type=nope echo\n", []string{"run_mount"}},
+	}
+	for _, tt := range tests {
+		err := Default().Validate(tt.dockerfile)
+		if len(tt.rules) == 0 {
+			if err != nil {
+				t.Errorf("%s: Validate() = %v, want nil", tt.name, err)
+			}
+			continue
+		}
+		var violations *ViolationError
+		if !errors.As(err, &violations) {
+			t.Errorf("%s: Validate() = %v, want a *ViolationError", tt.name, err)
+			continue
+		}
+		var rules []string
+		for _, v := range violations.Violations {
+			rules = append(rules, v.Rule)
+		}
+		if !slices.Equal(rules, tt.rules) {
+			t.Errorf("%s: violated rules = %v, want %v", tt.name, rules, tt.rules)
+		}
+	}
+}
+
+func TestValidateReportsLines(t *testing.T) {
+	err := Default().Validate("FROM alpine:3\nRUN echo ok\nRUN --network=host wget example.com\n")
+	var violations *ViolationError
+	if !errors.As(err, &violations) || len(violations.Violations) != 1 {
+		t.Fatalf("Validate() = %v, want one violation", err)
+	}
+	if got := violations.Violations[0]; got.Line != 3 || got.Instruction != "RUN" {
+		t.Errorf("violation = %+v, want RUN on line 3", got)
+	}
+}
+
+func TestPolicyOverrides(t *testing.T) {
+	policy := Default()
+	policy.AllowHostNetwork = true
+	policy.AllowRemoteAdd = true
+	policy.MaxStages = 0
+	dockerfile := "FROM alpine:3\nFROM alpine:3\nFROM alpine:3\nFROM alpine:3\nFROM alpine:3\n" +
+		"RUN --network=host wget example.com\nADD https://example.com/x /x\n"
+	if err := policy.Validate(dockerfile); err != nil {
+		t.Errorf("Validate() = %v, want nil", err)
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy string
+		ok     bool
+	}{
+		{"empty object keeps defaults", `{}`, true},
+		{"custom allowlist", `{"allowed_base_images": ["golang:1.*"]}`, true},
+		{"not json", `{"max_stages": `, false},
+		{"empty allowlist", `{"allowed_base_images": []}`, false},
+		{"bad pattern", `{"allowed_base_images": ["node:["]}`, false},
+		{"unknown mount type", `{"forbidden_mount_types": ["volume"]}`, false},
+		{"empty instruction", `{"forbidden_instructions": [" "]}`, false},
+		{"negative max stages", `{"max_stages": -1}`, false},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "policy.json")
+		if err := os.WriteFile(path, []byte(tt.policy), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := LoadFile(path)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: LoadFile() error = %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+	if _, err := LoadFile(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Errorf("missing file: LoadFile() succeeded")
+	}
+}
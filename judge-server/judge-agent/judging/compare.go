@@ -0,0 +1,225 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package judging
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Checker types. CheckerProgram runs a problem-supplied program instead of
+// a built-in comparator.
+const (
+	CheckerExact     = "exact"
+	CheckerLines     = "lines"
+	CheckerTokens    = "tokens"
+	CheckerFloat     = "float"
+	CheckerUnordered = "unordered"
+	CheckerProgram   = "program"
+)
+
+// DefaultEpsilon is the float tolerance used when a float checker sets
+// neither epsilon.
+const DefaultEpsilon = 1e-6
+
+// CheckerSpec selects how program output is judged. The zero value
+// compares line by line.
+type CheckerSpec struct {
+	Type string `json:"type,omitempty" jsonschema:"exact, lines (default), tokens, float, unordered or program"`
+	// AbsEpsilon and RelEpsilon bound the error a float checker accepts;
+	// a number passes if it is within either.
+	AbsEpsilon float64 `json:"abs_epsilon,omitempty" jsonschema:"absolute tolerance for the float checker"`
+	RelEpsilon float64 `json:"rel_epsilon,omitempty" jsonschema:"relative tolerance for the float checker"`
+	// Program is the checker source for CheckerProgram. It is called
+	// testlib-style with the input, the program's output and the
+	// expected answer as file arguments.
+	Program *CheckerSource `json:"program,omitempty" jsonschema:"checker program source, for the program type"`
+}
+
+// CheckerSource is a checker program built like a submission, from a
+// language profile or a Dockerfile plus its source files.
+type CheckerSource struct {
+	Profile    string            `json:"profile,omitempty" jsonschema:"language profile to build the checker with"`
+	DockerFile string            `json:"docker_file,omitempty" jsonschema:"Dockerfile to build the checker with instead of a profile"`
+	Files      map[string]string `json:"files" jsonschema:"checker source files by path"`
+}
+
+// Validate rejects specs no checker can be made from.
+func (s CheckerSpec) Validate() error {
+	switch s.Type {
+	case "", CheckerExact, CheckerLines, CheckerTokens, CheckerUnordered:
+	case CheckerFloat:
+		if s.AbsEpsilon < 0 || s.RelEpsilon < 0 {
+			return fmt.Errorf("checker epsilons must not be negative")
+		}
+	case CheckerProgram:
+		if s.Program == nil || len(s.Program.Files) == 0 {
+			return fmt.Errorf("program checker needs source files")
+		}
+		if (s.Program.Profile == "") == (s.Program.DockerFile == "") {
+			return fmt.Errorf("program checker needs exactly one of profile or docker_file")
+		}
+	default:
+		return fmt.Errorf("unknown checker type %q", s.Type)
+	}
+	return nil
+}
+
+// Comparison is the outcome of judging one output. Diff is set by the
+// line-based comparators only; CheckerVerdict by checker programs only.
+type Comparison struct {
+	OK             bool
+	Diff           *LineDiff
+	Message        string
+	CheckerVerdict string
+}
+
+// Comparator judges program output against the expected answer.
+type Comparator interface {
+	Compare(expected, actual string) Comparison
+}
+
+// ComparatorFunc adapts a function to Comparator.
+type ComparatorFunc func(expected, actual string) Comparison
+
+func (f ComparatorFunc) Compare(expected, actual string) Comparison {
+	return f(expected, actual)
+}
+
+// NewComparator returns the built-in comparator for spec. Program
+// checkers need a sandbox and are not built here.
+func NewComparator(spec CheckerSpec) (Comparator, error) {
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	switch spec.Type {
+	case "", CheckerLines:
+		return ComparatorFunc(compareLines), nil
+	case CheckerExact:
+		return ComparatorFunc(compareExact), nil
+	case CheckerTokens:
+		return ComparatorFunc(func(expected, actual string) Comparison {
+			return compareTokens(expected, actual, func(want, got string) bool { return want == got })
+		}), nil
+	case CheckerFloat:
+		abs, rel := spec.AbsEpsilon, spec.RelEpsilon
+		if abs == 0 && rel == 0 {
+			abs, rel = DefaultEpsilon, DefaultEpsilon
+		}
+		return ComparatorFunc(func(expected, actual string) Comparison {
+			return compareTokens(expected, actual, func(want, got string) bool {
+				return floatsMatch(want, got, abs, rel)
+			})
+		}), nil
+	case CheckerUnordered:
+		return ComparatorFunc(compareUnordered), nil
+	default:
+		return nil, fmt.Errorf("checker type %q has no built-in comparator", spec.Type)
+	}
+}
+
+func compareLines(expected, actual string) Comparison {
+	if diff := CompareLines(expected, actual); diff != nil {
+		return Comparison{Diff: diff, Message: diff.String()}
+	}
+	return Comparison{OK: true}
+}
+
+// compareExact requires byte-identical output but still points at the
+// first differing line.
+func compareExact(expected, actual string) Comparison {
+	if expected == actual {
+		return Comparison{OK: true}
+	}
+	expectedLines := strings.Split(expected, "\n")
+	actualLines := strings.Split(actual, "\n")
+	for i := 0; ; i++ {
+		if i >= len(expectedLines) || i >= len(actualLines) || expectedLines[i] != actualLines[i] {
+			diff := &LineDiff{Line: i + 1}
+			if i < len(expectedLines) {
+				diff.Expected = expectedLines[i]
+			}
+			if i < len(actualLines) {
+				diff.Actual = actualLines[i]
+			}
+			return Comparison{Diff: diff, Message: diff.String()}
+		}
+	}
+}
+
+// compareTokens compares whitespace-separated tokens in order.
+func compareTokens(expected, actual string, match func(want, got string) bool) Comparison {
+	want, got := strings.Fields(expected), strings.Fields(actual)
+	for i := 0; i < min(len(want), len(got)); i++ {
+		if !match(want[i], got[i]) {
+			return Comparison{Message: fmt.Sprintf("token %d: expected %q, got %q", i+1, want[i], got[i])}
+		}
+	}
+	switch {
+	case len(got) < len(want):
+		return Comparison{Message: fmt.Sprintf("expected %d tokens, got %d", len(want), len(got))}
+	case len(got) > len(want):
+		return Comparison{Message: fmt.Sprintf("expected %d tokens, got %d; first extra %q", len(want), len(got), got[len(want)])}
+	}
+	return Comparison{OK: true}
+}
+
+// floatsMatch compares two tokens as numbers when both parse, and as
+// strings otherwise, so words mixed into numeric output still compare.
+func floatsMatch(want, got string, abs, rel float64) bool {
+	wantValue, wantErr := strconv.ParseFloat(want, 64)
+	gotValue, gotErr := strconv.ParseFloat(got, 64)
+	if wantErr != nil || gotErr != nil {
+		return want == got
+	}
+	if wantValue == gotValue {
+		return true
+	}
+	if math.IsNaN(wantValue) || math.IsNaN(gotValue) {
+		return math.IsNaN(wantValue) && math.IsNaN(gotValue)
+	}
+	diff := math.Abs(wantValue - gotValue)
+	return diff <= abs || diff <= rel*math.Abs(wantValue)
+}
+
+// compareUnordered accepts the expected lines in any order.
+func compareUnordered(expected, actual string) Comparison {
+	want := normalizedLines(expected)
+	got := normalizedLines(actual)
+	slices.Sort(want)
+	slices.Sort(got)
+	i, j := 0, 0
+	for i < len(want) && j < len(got) {
+		switch {
+		case want[i] == got[j]:
+			i++
+			j++
+		case want[i] < got[j]:
+			return Comparison{Message: fmt.Sprintf("missing line %q", want[i])}
+		default:
+			return Comparison{Message: fmt.Sprintf("unexpected line %q", got[j])}
+		}
+	}
+	if i < len(want) {
+		return Comparison{Message: fmt.Sprintf("missing line %q", want[i])}
+	}
+	if j < len(got) {
+		return Comparison{Message: fmt.Sprintf("unexpected line %q", got[j])}
+	}
+	return Comparison{OK: true}
+}
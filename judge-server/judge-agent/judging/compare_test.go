@@ -0,0 +1,93 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package judging
+
+import "testing"
+
+func TestComparators(t *testing.T) {
+	tests := []struct {
+		name     string
+		spec     CheckerSpec
+		expected string
+		actual   string
+		ok       bool
+	}{
+		{"lines default", CheckerSpec{}, "1\n2\n", "1  \r\n2\n\n", true},
+		{"lines mismatch", CheckerSpec{Type: CheckerLines}, "1\n2\n", "1\n3\n", false},
+		{"exact trailing space", CheckerSpec{Type: CheckerExact}, "1\n", "1 \n", false},
+		{"exact identical", CheckerSpec{Type: CheckerExact}, "a b\n", "a b\n", true},
+		{"tokens ignore layout", CheckerSpec{Type: CheckerTokens}, "1 2\n3\n", "1\n2 3", true},
+		{"tokens missing", CheckerSpec{Type: CheckerTokens}, "1 2 3", "1 2", false},
+		{"tokens extra", CheckerSpec{Type: CheckerTokens}, "1 2", "1 2 3", false},
+		{"float within default epsilon", CheckerSpec{Type: CheckerFloat}, "0.3333333", "0.33333334", true},
+		{"float outside default epsilon", CheckerSpec{Type: CheckerFloat}, "0.333", "0.334", false},
+		{"float absolute epsilon", CheckerSpec{Type: CheckerFloat, AbsEpsilon: 0.01}, "0.333", "0.34", true},
+		{"float relative epsilon", CheckerSpec{Type: CheckerFloat, RelEpsilon: 1e-3}, "1000000", "1000500", true},
+		{"float relative epsilon exceeded", CheckerSpec{Type: CheckerFloat, RelEpsilon: 1e-3}, "1000000", "1002000", false},
+		{"float exponent notation", CheckerSpec{Type: CheckerFloat}, "1e-3", "0.001", true},
+		{"float NaN matches NaN", CheckerSpec{Type: CheckerFloat}, "NaN", "nan", true},
+		{"float NaN against number", CheckerSpec{Type: CheckerFloat, AbsEpsilon: 1e9}, "NaN", "1", false},
+		{"float number against NaN", CheckerSpec{Type: CheckerFloat, AbsEpsilon: 1e9}, "1", "NaN", false},
+		{"float infinities", CheckerSpec{Type: CheckerFloat}, "Inf", "+Inf", true},
+		{"float words compare as strings", CheckerSpec{Type: CheckerFloat}, "YES 1.0", "YES 1", true},
+		{"float word mismatch", CheckerSpec{Type: CheckerFloat}, "YES", "yes", false},
+		{"unordered any order", CheckerSpec{Type: CheckerUnordered}, "a\nb\nc\n", "c\na\nb", true},
+		{"unordered keeps duplicates", CheckerSpec{Type: CheckerUnordered}, "a\na\nb\n", "a\nb\nb\n", false},
+		{"unordered duplicates in any order", CheckerSpec{Type: CheckerUnordered}, "a\nb\na\n", "a\na\nb\n", true},
+		{"unordered missing line", CheckerSpec{Type: CheckerUnordered}, "a\nb\n", "a\n", false},
+		{"unordered extra line", CheckerSpec{Type: CheckerUnordered}, "a\n", "a\nb\n", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			comparator, err := NewComparator(tt.spec)
+			if err != nil {
+				t.Fatalf("NewComparator(%+v) error = %v", tt.spec, err)
+			}
+			got := comparator.Compare(tt.expected, tt.actual)
+			if got.OK != tt.ok {
+				t.Errorf("Compare(%q, %q) = %+v, want OK %v", tt.expected, tt.actual, got, tt.ok)
+			}
+			if !got.OK && got.Message == "" {
+				t.Errorf("Compare(%q, %q) failed without a message", tt.expected, tt.actual)
+			}
+		})
+	}
+}
+
+func TestCheckerSpecValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    CheckerSpec
+		wantErr bool
+	}{
+		{"zero value", CheckerSpec{}, false},
+		{"unknown type", CheckerSpec{Type: "fuzzy"}, true},
+		{"negative epsilon", CheckerSpec{Type: CheckerFloat, AbsEpsilon: -1}, true},
+		{"program without files", CheckerSpec{Type: CheckerProgram, Program: &CheckerSource{Profile: "cpp"}}, true},
+		{"program with profile and Dockerfile", CheckerSpec{Type: CheckerProgram, Program: &CheckerSource{
+			Profile: "cpp", DockerFile: "FROM gcc", Files: map[string]string{"main.cpp": ""},
+		}}, true},
+		{"program with profile", CheckerSpec{Type: CheckerProgram, Program: &CheckerSource{
+			Profile: "cpp", Files: map[string]string{"main.cpp": ""},
+		}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.spec.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package judging defines test cases, verdicts and the per-test report the
+// judge returns for submissions graded against expected output.
+package judging
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Verdict is the standard competitive-programming outcome of a test.
+type Verdict string
+
+const (
+	Accepted            Verdict = "AC"
+	WrongAnswer         Verdict = "WA"
+	TimeLimitExceeded   Verdict = "TLE"
+	MemoryLimitExceeded Verdict = "MLE"
+	RuntimeError        Verdict = "RE"
+	CompilationError    Verdict = "CE"
+)
+
+const (
+	DefaultTimeLimitMs   = 2000
+	DefaultMemoryLimitKB = 256 * 1024
+)
+
+// Limits bound a single run of the program. Zero fields fall back to the
+// defaults.
+type Limits struct {
+	TimeLimitMs   int64 `json:"time_limit_ms,omitempty" jsonschema:"wall-clock limit per test in milliseconds"`
+	MemoryLimitKB int64 `json:"memory_limit_kb,omitempty" jsonschema:"peak resident memory limit per test in KiB"`
+}
+
+// Or returns l with zero fields taken from fallback.
+func (l Limits) Or(fallback Limits) Limits {
+	if l.TimeLimitMs <= 0 {
+		l.TimeLimitMs = fallback.TimeLimitMs
+	}
+	if l.MemoryLimitKB <= 0 {
+		l.MemoryLimitKB = fallback.MemoryLimitKB
+	}
+	return l
+}
+
+// DefaultLimits are used when neither the request nor the test sets any.
+func DefaultLimits() Limits {
+	return Limits{TimeLimitMs: DefaultTimeLimitMs, MemoryLimitKB: DefaultMemoryLimitKB}
+}
+
+// TestCase is one input fed to the program on stdin and the output it must
+// print.
+type TestCase struct {
+	Name           string `json:"name,omitempty"`
+	Input          string `json:"input"`
+	ExpectedOutput string `json:"expected_output"`
+	Limits
+}
+
+// LineDiff locates the first line where the output differs. Line is
+// 1-based; a missing line is reported as an empty string.
+type LineDiff struct {
+	Line     int    `json:"line"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+}
+
+func (d *LineDiff) String() string {
+	return fmt.Sprintf("line %d: expected %q, got %q", d.Line, d.Expected, d.Actual)
+}
+
+// TestResult is the outcome of one test.
+type TestResult struct {
+	Name         string    `json:"name"`
+	Verdict      Verdict   `json:"verdict"`
+	TimeMs       int64     `json:"time_ms"`
+	PeakMemoryKB int64     `json:"peak_memory_kb"`
+	ExitCode     int       `json:"exit_code"`
+	FirstDiff    *LineDiff `json:"first_diff,omitempty"`
+	Message      string    `json:"message,omitempty"`
+	// CheckerVerdict is a checker program's own outcome, such as "ok" or
+	// "presentation error"; Message then holds what it printed.
+	CheckerVerdict string `json:"checker_verdict,omitempty"`
+	Stdout         string `json:"stdout,omitempty"`
+	Stderr         string `json:"stderr,omitempty"`
+}
+
+// TestReport summarizes every test of a submission. Verdict is the first
+// non-accepted verdict in test order, or AC when all passed.
+type TestReport struct {
+	Verdict Verdict      `json:"verdict"`
+	Passed  int          `json:"passed"`
+	Total   int          `json:"total"`
+	Tests   []TestResult `json:"tests"`
+}
+
+// NewReport summarizes results.
+func NewReport(results []TestResult) *TestReport {
+	report := &TestReport{Verdict: Accepted, Total: len(results), Tests: results}
+	for _, result := range results {
+		if result.Verdict == Accepted {
+			report.Passed++
+		} else if report.Verdict == Accepted {
+			report.Verdict = result.Verdict
+		}
+	}
+	return report
+}
+
+// CompileErrorReport marks every test CE with the build log as the message.
+func CompileErrorReport(tests []TestCase, buildLog string) *TestReport {
+	results := make([]TestResult, len(tests))
+	for i, test := range tests {
+		results[i] = TestResult{Name: test.Name, Verdict: CompilationError, Message: buildLog}
+	}
+	report := NewReport(results)
+	report.Verdict = CompilationError
+	return report
+}
+
+// CompareLines compares program output line by line, ignoring trailing
+// whitespace on each line, Windows line endings and trailing blank lines.
+func CompareLines(expected, actual string) *LineDiff {
+	expectedLines := normalizedLines(expected)
+	actualLines := normalizedLines(actual)
+	for i := 0; i < max(len(expectedLines), len(actualLines)); i++ {
+		var want, got string
+		if i < len(expectedLines) {
+			want = expectedLines[i]
+		}
+		if i < len(actualLines) {
+			got = actualLines[i]
+		}
+		if want != got || (i >= len(expectedLines)) != (i >= len(actualLines)) {
+			return &LineDiff{Line: i + 1, Expected: want, Actual: got}
+		}
+	}
+	return nil
+}
+
+func normalizedLines(output string) []string {
+	lines := strings.Split(strings.ReplaceAll(output, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r")
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
@@ -0,0 +1,48 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package judging
+
+import "testing"
+
+func TestCompareLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+		actual   string
+		want     *LineDiff
+	}{
+		{"identical", "1\n2\n", "1\n2\n", nil},
+		{"trailing whitespace", "1\n2\n", "1 \t\n2  \n", nil},
+		{"windows line endings", "1\n2\n", "1\r\n2\r\n", nil},
+		{"trailing blank lines", "1\n", "1\n\n\n", nil},
+		{"missing final newline", "1\n2\n", "1\n2", nil},
+		{"leading whitespace matters", "1\n", " 1\n", &LineDiff{Line: 1, Expected: "1", Actual: " 1"}},
+		{"different line", "1\n2\n3\n", "1\n4\n3\n", &LineDiff{Line: 2, Expected: "2", Actual: "4"}},
+		{"missing line", "1\n2\n", "1\n", &LineDiff{Line: 2, Expected: "2"}},
+		{"extra line", "1\n", "1\n2\n", &LineDiff{Line: 2, Actual: "2"}},
+		{"blank line inside output", "1\n\n2\n", "1\n2\n", &LineDiff{Line: 2, Actual: "2"}},
+		{"empty output", "1\n", "", &LineDiff{Line: 1, Expected: "1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := CompareLines(tt.expected, tt.actual)
+			switch {
+			case got == nil && tt.want == nil:
+			case got == nil || tt.want == nil || *got != *tt.want:
+				t.Errorf("CompareLines(%q, %q) = %v, want %v", tt.expected, tt.actual, got, tt.want)
+			}
+		})
+	}
+}
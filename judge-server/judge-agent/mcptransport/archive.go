@@ -0,0 +1,252 @@
+package mcptransport
+
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// ArchiveFormat is the container format of an uploaded build context.
+type ArchiveFormat string
+
+const (
+	ArchiveTar     ArchiveFormat = "tar"
+	ArchiveTarGzip ArchiveFormat = "tar.gz"
+	ArchiveTarZstd ArchiveFormat = "tar.zst"
+	ArchiveZip     ArchiveFormat = "zip"
+)
+
+var archiveContentTypes = map[string]ArchiveFormat{
+	"application/x-tar":  ArchiveTar,
+	"application/tar":    ArchiveTar,
+	"application/gzip":   ArchiveTarGzip,
+	"application/x-gzip": ArchiveTarGzip,
+	"application/zstd":   ArchiveTarZstd,
+	"application/x-zstd": ArchiveTarZstd,
+	"application/zip":    ArchiveZip,
+	"application/x-zip":  ArchiveZip,
+}
+
+// detectArchiveFormat prefers the declared content type and falls back to
+// magic bytes when it is missing or generic.
+func detectArchiveFormat(contentType string, head []byte) (ArchiveFormat, error) {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		if format, ok := archiveContentTypes[strings.ToLower(mediaType)]; ok {
+			return format, nil
+		}
+	}
+	switch {
+	case bytes.HasPrefix(head, []byte{0x1f, 0x8b}):
+		return ArchiveTarGzip, nil
+	case bytes.HasPrefix(head, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		return ArchiveTarZstd, nil
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")), bytes.HasPrefix(head, []byte("PK\x05\x06")):
+		return ArchiveZip, nil
+	case len(head) >= 262 && string(head[257:262]) == "ustar":
+		return ArchiveTar, nil
+	case len(head) >= 1024 && bytes.Count(head[:1024], []byte{0}) == 1024:
+		// An empty tar archive is just the two zero end-of-archive blocks.
+		return ArchiveTar, nil
+	}
+	return "", &ContextError{Reason: "unsupported_archive", Detail: "expected tar, tar.gz, tar.zst or zip"}
+}
+
+// NormalizeArchive reads a build context in any supported format from r and
+// returns it as the normalized tar stream DeployContainer consumes. The
+// compressed input and the expanded output are both bounded by limits.
+func NormalizeArchive(r io.Reader, contentType string, limits ContextLimits) (*bytes.Buffer, error) {
+	compressed := &countingReader{r: io.LimitReader(r, limits.MaxArchiveBytes()+1)}
+	buffered := bufio.NewReaderSize(compressed, 1024)
+	head, _ := buffered.Peek(1024)
+	format, err := detectArchiveFormat(contentType, head)
+	if err != nil {
+		return nil, err
+	}
+
+	var tarStream io.Reader
+	switch format {
+	case ArchiveTar:
+		tarStream = buffered
+	case ArchiveTarGzip:
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, &ContextError{Reason: "invalid_archive", Detail: err.Error()}
+		}
+		defer gz.Close()
+		tarStream = gz
+	case ArchiveTarZstd:
+		zr, err := zstd.NewReader(buffered, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxMemory(uint64(limits.MaxArchiveBytes())))
+		if err != nil {
+			return nil, &ContextError{Reason: "invalid_archive", Detail: err.Error()}
+		}
+		defer zr.Close()
+		tarStream = zr
+	case ArchiveZip:
+		converted, err := zipToTar(buffered, limits)
+		if err != nil {
+			return nil, err
+		}
+		// Closing stops the converter when normalizing gives up early.
+		defer converted.Close()
+		tarStream = converted
+	}
+	if format != ArchiveTar {
+		tarStream = &expansionGuard{r: tarStream, compressed: compressed, limits: limits}
+	}
+
+	var normalized bytes.Buffer
+	tw := tar.NewWriter(&normalized)
+	if _, err := normalizeBuildContext(tarStream, tw, limits, contextLayout{}); err != nil {
+		return nil, err
+	}
+	if compressed.n > limits.MaxArchiveBytes() {
+		return nil, &ContextError{
+			Reason: "context_too_large",
+			Detail: fmt.Sprintf("archive exceeds %d bytes", limits.MaxArchiveBytes()),
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("close tar writer: %w", err)
+	}
+	return &normalized, nil
+}
+
+// zipToTar buffers a zip archive, which needs random access, and re-encodes
+// its regular files and directories as a tar stream. Anything else, such as
+// symlinks, is passed through with its type so normalizeBuildContext rejects
+// it. The caller must close the stream, which ends the conversion if it has
+// not finished.
+func zipToTar(r io.Reader, limits ContextLimits) (io.ReadCloser, error) {
+	raw, err := io.ReadAll(io.LimitReader(r, limits.MaxArchiveBytes()+1))
+	if err != nil {
+		return nil, &ContextError{Reason: "invalid_archive", Detail: err.Error()}
+	}
+	if int64(len(raw)) > limits.MaxArchiveBytes() {
+		return nil, &ContextError{
+			Reason: "context_too_large",
+			Detail: fmt.Sprintf("archive exceeds %d bytes", limits.MaxArchiveBytes()),
+		}
+	}
+	zr, err := zip.NewReader(bytes.NewReader(raw), int64(len(raw)))
+	if err != nil {
+		return nil, &ContextError{Reason: "invalid_archive", Detail: err.Error()}
+	}
+	if len(zr.File) > limits.MaxEntries {
+		return nil, &ContextError{
+			Reason: "too_many_entries",
+			Detail: fmt.Sprintf("more than %d entries", limits.MaxEntries),
+		}
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		tw := tar.NewWriter(pw)
+		for _, file := range zr.File {
+			if err := writeZipEntry(tw, file, limits); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+		pw.CloseWithError(tw.Close())
+	}()
+	return pr, nil
+}
+
+func writeZipEntry(tw *tar.Writer, file *zip.File, limits ContextLimits) error {
+	header, err := tar.FileInfoHeader(file.FileInfo(), "")
+	if err != nil {
+		return &ContextError{Reason: "invalid_archive", Entry: file.Name, Detail: err.Error()}
+	}
+	header.Name = file.Name
+	if header.Typeflag != tar.TypeReg {
+		header.Size = 0
+		return tw.WriteHeader(header)
+	}
+	if file.UncompressedSize64 > uint64(limits.MaxFileBytes) {
+		return &ContextError{
+			Reason: "file_too_large",
+			Entry:  file.Name,
+			Detail: fmt.Sprintf("%d bytes exceeds the %d byte file limit", file.UncompressedSize64, limits.MaxFileBytes),
+		}
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	contents, err := file.Open()
+	if err != nil {
+		return &ContextError{Reason: "invalid_archive", Entry: file.Name, Detail: err.Error()}
+	}
+	defer contents.Close()
+	// The zip reader verifies the declared size and checksum, so a short or
+	// oversized entry surfaces as a read error here.
+	if _, err := io.Copy(tw, contents); err != nil {
+		return &ContextError{Reason: "invalid_archive", Entry: file.Name, Detail: err.Error()}
+	}
+	return nil
+}
+
+// minGuardedExpansion is how much output a compressed stream may produce
+// before the expansion ratio is enforced, so tiny archives of text aren't
+// rejected for compressing well.
+const minGuardedExpansion = 1 << 20
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// expansionGuard fails a decompressed stream that grows past the archive
+// size limit or expands too far relative to its compressed input.
+type expansionGuard struct {
+	r          io.Reader
+	compressed *countingReader
+	limits     ContextLimits
+	n          int64
+}
+
+func (g *expansionGuard) Read(p []byte) (int, error) {
+	n, err := g.r.Read(p)
+	g.n += int64(n)
+	if g.n > g.limits.MaxArchiveBytes() {
+		return n, &ContextError{
+			Reason: "context_too_large",
+			Detail: fmt.Sprintf("expanded archive exceeds %d bytes", g.limits.MaxArchiveBytes()),
+		}
+	}
+	if g.n > minGuardedExpansion && g.limits.MaxExpansionRatio > 0 &&
+		g.n > g.compressed.n*g.limits.MaxExpansionRatio {
+		return n, &ContextError{
+			Reason: "expansion_ratio",
+			Detail: fmt.Sprintf("archive expands more than %dx", g.limits.MaxExpansionRatio),
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,143 @@
+package mcptransport
+
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"errors"
+	"io"
+	"io/fs"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestNormalizeArchiveZipStopsConverterOnRejection(t *testing.T) {
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	link := &zip.FileHeader{Name: "link", Method: zip.Store}
+	link.SetMode(fs.ModeSymlink | 0o777)
+	w, err := zw.CreateHeader(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("/etc/passwd"))
+	for i := 0; i < 8; i++ {
+		w, err := zw.Create(strings.Repeat("x", i+1) + ".txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(bytes.Repeat([]byte("a"), 64<<10))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	before := runtime.NumGoroutine()
+	for i := 0; i < 20; i++ {
+		_, err := NormalizeArchive(bytes.NewReader(archive.Bytes()), "application/zip", ContextLimitsFromEnv())
+		var contextErr *ContextError
+		if !errors.As(err, &contextErr) || contextErr.Reason != "unsupported_entry_type" {
+			t.Fatalf("NormalizeArchive() error = %v, want unsupported_entry_type", err)
+		}
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines left running after rejected archives, want %d", runtime.NumGoroutine(), before)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// compressArchive packs name with contents into format.
+func compressArchive(t *testing.T, format ArchiveFormat, name string, contents []byte) []byte {
+	t.Helper()
+	var archive bytes.Buffer
+	if format == ArchiveZip {
+		zw := zip.NewWriter(&archive)
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(contents)
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return archive.Bytes()
+	}
+
+	var w io.WriteCloser
+	switch format {
+	case ArchiveTarGzip:
+		w = gzip.NewWriter(&archive)
+	case ArchiveTarZstd:
+		zw, err := zstd.NewWriter(&archive)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w = zw
+	default:
+		t.Fatalf("unexpected format %s", format)
+	}
+	tw := tar.NewWriter(w)
+	if err := tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0o644, Size: int64(len(contents))}); err != nil {
+		t.Fatal(err)
+	}
+	tw.Write(contents)
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return archive.Bytes()
+}
+
+func TestNormalizeArchiveExpansionRatio(t *testing.T) {
+	zeros := make([]byte, 4<<20)
+	random := make([]byte, 2<<20)
+	rand.Read(random)
+	for _, format := range []ArchiveFormat{ArchiveTarGzip, ArchiveTarZstd, ArchiveZip} {
+		bomb := compressArchive(t, format, "zeros", zeros)
+		_, err := NormalizeArchive(bytes.NewReader(bomb), "", ContextLimitsFromEnv())
+		var contextErr *ContextError
+		if !errors.As(err, &contextErr) || contextErr.Reason != "expansion_ratio" {
+			t.Errorf("%s: NormalizeArchive(%d compressed bytes of zeros) error = %v, want expansion_ratio", format, len(bomb), err)
+		}
+
+		incompressible := compressArchive(t, format, "random", random)
+		if _, err := NormalizeArchive(bytes.NewReader(incompressible), "", ContextLimitsFromEnv()); err != nil {
+			t.Errorf("%s: NormalizeArchive(random) error = %v", format, err)
+		}
+	}
+}
+
+func TestNormalizeArchiveExpandedSizeLimit(t *testing.T) {
+	limits := ContextLimits{MaxTotalBytes: 64 << 10, MaxFileBytes: 1 << 20, MaxEntries: 10}
+	archive := compressArchive(t, ArchiveTarGzip, "zeros", make([]byte, 512<<10))
+	_, err := NormalizeArchive(bytes.NewReader(archive), "application/gzip", limits)
+	var contextErr *ContextError
+	if !errors.As(err, &contextErr) || !contextErr.TooLarge() {
+		t.Errorf("NormalizeArchive() error = %v, want a size rejection", err)
+	}
+}
@@ -0,0 +1,191 @@
+package mcptransport
+
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBuildCacheTTL        = 30 * time.Minute
+	defaultBuildCacheMaxEntries = 256
+)
+
+// contextEntry is the normalized form of one build context entry used to
+// derive the cache key.
+type contextEntry struct {
+	name     string
+	typeflag byte
+	mode     int64
+	digest   string
+}
+
+// buildContextKey hashes the sorted entries together with the Dockerfile so
+// identical submissions map to the same key regardless of tar ordering.
+func buildContextKey(dockerfile string, entries []contextEntry) string {
+	sorted := append([]contextEntry(nil), entries...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].name < sorted[j].name })
+
+	hash := sha256.New()
+	for _, entry := range sorted {
+		fmt.Fprintf(hash, "%s\x00%c\x00%o\x00%s\n", entry.name, entry.typeflag, entry.mode, entry.digest)
+	}
+	dockerfileDigest := sha256.Sum256([]byte(dockerfile))
+	fmt.Fprintf(hash, "Dockerfile\x00%s\n", hex.EncodeToString(dockerfileDigest[:]))
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+type buildCacheEntry struct {
+	key       string
+	imageRef  string
+	buildLogs string
+	expires   time.Time
+}
+
+// buildCache remembers pushed images by build context key. Entries expire
+// after ttl and the least recently used entry is evicted once maxEntries is
+// reached. Evicting an entry leaves its image alone: sandboxes may still
+// run it, and the reaper deletes it once nothing does.
+type buildCache struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	entries    map[string]*list.Element
+	lru        *list.List
+	now        func() time.Time
+}
+
+var defaultBuildCache = newBuildCacheFromEnv()
+
+func newBuildCacheFromEnv() *buildCache {
+	ttl := defaultBuildCacheTTL
+	if raw := strings.TrimSpace(os.Getenv("MCP_BUILD_CACHE_TTL")); raw != "" {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil {
+			log.Printf("buildcache: ignoring invalid MCP_BUILD_CACHE_TTL %q: %v", raw, err)
+		} else {
+			ttl = parsed
+		}
+	}
+	maxEntries := defaultBuildCacheMaxEntries
+	if raw := strings.TrimSpace(os.Getenv("MCP_BUILD_CACHE_MAX_ENTRIES")); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 0 {
+			log.Printf("buildcache: ignoring invalid MCP_BUILD_CACHE_MAX_ENTRIES %q", raw)
+		} else {
+			maxEntries = parsed
+		}
+	}
+	return newBuildCache(ttl, maxEntries)
+}
+
+func newBuildCache(ttl time.Duration, maxEntries int) *buildCache {
+	return &buildCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    map[string]*list.Element{},
+		lru:        list.New(),
+		now:        time.Now,
+	}
+}
+
+func (c *buildCache) enabled() bool {
+	return c.ttl > 0 && c.maxEntries > 0
+}
+
+// get returns the cached entry for key, refreshing its recency.
+func (c *buildCache) get(key string) (buildCacheEntry, bool) {
+	if !c.enabled() {
+		return buildCacheEntry{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return buildCacheEntry{}, false
+	}
+	entry := elem.Value.(*buildCacheEntry)
+	if c.now().After(entry.expires) {
+		c.removeLocked(elem)
+		return buildCacheEntry{}, false
+	}
+	c.lru.MoveToFront(elem)
+	return *entry, true
+}
+
+// put caches imageRef under key. A live entry already there is kept, so
+// the image two identical builds raced to push stays the one every later
+// hit gets, and the other is left for the reaper.
+func (c *buildCache) put(key, imageRef, buildLogs string) {
+	if !c.enabled() {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		if !c.now().After(elem.Value.(*buildCacheEntry).expires) {
+			return
+		}
+		c.removeLocked(elem)
+	}
+	c.entries[key] = c.lru.PushFront(&buildCacheEntry{
+		key:       key,
+		imageRef:  imageRef,
+		buildLogs: buildLogs,
+		expires:   c.now().Add(c.ttl),
+	})
+	c.pruneLocked()
+}
+
+// holds reports whether imageRef backs a live cache entry and so must not be
+// deleted by callers cleaning up after a deploy.
+func (c *buildCache) holds(imageRef string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	for elem := c.lru.Front(); elem != nil; elem = elem.Next() {
+		entry := elem.Value.(*buildCacheEntry)
+		if entry.imageRef == imageRef && !now.After(entry.expires) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *buildCache) pruneLocked() {
+	now := c.now()
+	for elem := c.lru.Back(); elem != nil; {
+		prev := elem.Prev()
+		if now.After(elem.Value.(*buildCacheEntry).expires) || c.lru.Len() > c.maxEntries {
+			c.removeLocked(elem)
+		}
+		elem = prev
+	}
+}
+
+func (c *buildCache) removeLocked(elem *list.Element) {
+	entry := c.lru.Remove(elem).(*buildCacheEntry)
+	delete(c.entries, entry.key)
+}
@@ -0,0 +1,49 @@
+package mcptransport
+
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBuildCachePut(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	cache := newBuildCache(time.Minute, 2)
+	cache.now = func() time.Time { return now }
+
+	cache.put("a", "registry/mcp-image-1", "")
+	cache.put("a", "registry/mcp-image-2", "")
+	if entry, ok := cache.get("a"); !ok || entry.imageRef != "registry/mcp-image-1" {
+		t.Errorf("after a racing put, get(a) = %+v, %v, want the first image", entry, ok)
+	}
+
+	now = now.Add(2 * time.Minute)
+	cache.put("a", "registry/mcp-image-3", "")
+	if entry, ok := cache.get("a"); !ok || entry.imageRef != "registry/mcp-image-3" {
+		t.Errorf("after expiry, get(a) = %+v, %v, want the new image", entry, ok)
+	}
+
+	cache.put("b", "registry/mcp-image-4", "")
+	cache.put("c", "registry/mcp-image-5", "")
+	if _, ok := cache.get("a"); ok {
+		t.Errorf("least recently used entry a was not evicted")
+	}
+	for _, image := range []string{"registry/mcp-image-4", "registry/mcp-image-5"} {
+		if !cache.holds(image) {
+			t.Errorf("holds(%s) = false, want true", image)
+		}
+	}
+}
@@ -0,0 +1,422 @@
+package mcptransport
+
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultContextMaxBytes     = 50 << 20
+	defaultContextMaxFileBytes = 10 << 20
+	defaultContextMaxEntries   = 5000
+	defaultMaxExpansionRatio   = 100
+)
+
+// ContextLimits bound what a submitted build context may contain.
+type ContextLimits struct {
+	MaxTotalBytes int64
+	MaxFileBytes  int64
+	MaxEntries    int
+	// MaxExpansionRatio caps decompressed size relative to compressed
+	// size for compressed uploads.
+	MaxExpansionRatio int64
+}
+
+// ContextLimitsFromEnv reads MCP_CONTEXT_MAX_BYTES, MCP_CONTEXT_MAX_FILE_BYTES,
+// MCP_CONTEXT_MAX_ENTRIES and MCP_CONTEXT_MAX_EXPANSION_RATIO, keeping the
+// defaults for unset or invalid values.
+func ContextLimitsFromEnv() ContextLimits {
+	return ContextLimits{
+		MaxTotalBytes:     envInt64("MCP_CONTEXT_MAX_BYTES", defaultContextMaxBytes),
+		MaxFileBytes:      envInt64("MCP_CONTEXT_MAX_FILE_BYTES", defaultContextMaxFileBytes),
+		MaxEntries:        int(envInt64("MCP_CONTEXT_MAX_ENTRIES", defaultContextMaxEntries)),
+		MaxExpansionRatio: envInt64("MCP_CONTEXT_MAX_EXPANSION_RATIO", defaultMaxExpansionRatio),
+	}
+}
+
+// MaxArchiveBytes is the largest tar stream that can satisfy the limits,
+// allowing a header and padding block per entry plus the end-of-archive
+// marker.
+func (l ContextLimits) MaxArchiveBytes() int64 {
+	return l.MaxTotalBytes + int64(l.MaxEntries+1)*2*512 + 1024
+}
+
+// MaxEncodedBytes is the base64 length of the largest accepted archive.
+func (l ContextLimits) MaxEncodedBytes() int64 {
+	return int64(base64.RawURLEncoding.EncodedLen(int(l.MaxArchiveBytes())))
+}
+
+func envInt64(name string, fallback int64) int64 {
+	raw := strings.TrimSpace(os.Getenv(name))
+	if raw == "" {
+		return fallback
+	}
+	value, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || value <= 0 {
+		log.Printf("ignoring invalid %s %q", name, raw)
+		return fallback
+	}
+	return value
+}
+
+func envDuration(name string, fallback time.Duration) time.Duration {
+	raw := strings.TrimSpace(os.Getenv(name))
+	if raw == "" {
+		return fallback
+	}
+	value, err := time.ParseDuration(raw)
+	if err != nil || value < 0 {
+		log.Printf("ignoring invalid %s %q", name, raw)
+		return fallback
+	}
+	return value
+}
+
+// ContextError explains why a build context was rejected. Reason is a stable
+// machine-readable code.
+type ContextError struct {
+	Reason string `json:"reason"`
+	Entry  string `json:"entry,omitempty"`
+	Detail string `json:"detail"`
+}
+
+func (e *ContextError) Error() string {
+	if e.Entry != "" {
+		return fmt.Sprintf("build context rejected (%s): %s: %s", e.Reason, e.Entry, e.Detail)
+	}
+	return fmt.Sprintf("build context rejected (%s): %s", e.Reason, e.Detail)
+}
+
+// TooLarge reports whether the rejection was about size rather than shape.
+func (e *ContextError) TooLarge() bool {
+	switch e.Reason {
+	case "context_too_large", "file_too_large", "too_many_entries", "expansion_ratio":
+		return true
+	}
+	return false
+}
+
+// normalizeBuildContext copies the regular files and directories of the tar
+// in r into tw with cleaned paths, normalized ownership and modes, and
+// returns the entries for cache keying. Entries are placed as layout says.
+// A Dockerfile at the top is dropped because DeployContainer always writes
+// its own, and so is anything shadowed by the layout's overrides, which the
+// caller writes afterwards.
+func normalizeBuildContext(r io.Reader, tw *tar.Writer, limits ContextLimits, layout contextLayout) ([]contextEntry, error) {
+	tr := tar.NewReader(r)
+	var entries []contextEntry
+	var totalBytes int64
+	dir := layout.dir
+	if dir != "" {
+		if err := tw.WriteHeader(&tar.Header{Name: dir + "/", Typeflag: tar.TypeDir, Mode: 0o755}); err != nil {
+			return nil, fmt.Errorf("write tar header: %w", err)
+		}
+		entries = append(entries, contextEntry{name: dir, typeflag: tar.TypeDir, mode: 0o755})
+	}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, asContextError(err, "")
+		}
+		if header.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+
+		name, err := cleanContextPath(header.Name)
+		if err != nil {
+			return nil, err
+		}
+		if name == "" || name == "Dockerfile" || layout.drops(name, header.Typeflag) {
+			continue
+		}
+		name = path.Join(dir, name)
+		if layout.overrides.shadows(name, header.Typeflag) {
+			continue
+		}
+		if len(entries) >= limits.MaxEntries {
+			return nil, &ContextError{
+				Reason: "too_many_entries",
+				Detail: fmt.Sprintf("more than %d entries", limits.MaxEntries),
+			}
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := tw.WriteHeader(&tar.Header{
+				Name:     name + "/",
+				Typeflag: tar.TypeDir,
+				Mode:     0o755,
+				ModTime:  header.ModTime,
+			}); err != nil {
+				return nil, fmt.Errorf("write tar header: %w", err)
+			}
+			entries = append(entries, contextEntry{name: name, typeflag: tar.TypeDir, mode: 0o755})
+		case tar.TypeReg, tar.TypeRegA:
+			if header.Size > limits.MaxFileBytes {
+				return nil, &ContextError{
+					Reason: "file_too_large",
+					Entry:  name,
+					Detail: fmt.Sprintf("%d bytes exceeds the %d byte file limit", header.Size, limits.MaxFileBytes),
+				}
+			}
+			if totalBytes+header.Size > limits.MaxTotalBytes {
+				return nil, &ContextError{
+					Reason: "context_too_large",
+					Detail: fmt.Sprintf("contents exceed the %d byte limit", limits.MaxTotalBytes),
+				}
+			}
+			mode := int64(0o644)
+			if header.Mode&0o111 != 0 {
+				mode = 0o755
+			}
+			if err := tw.WriteHeader(&tar.Header{
+				Name:     name,
+				Typeflag: tar.TypeReg,
+				Mode:     mode,
+				Size:     header.Size,
+				ModTime:  header.ModTime,
+			}); err != nil {
+				return nil, fmt.Errorf("write tar header: %w", err)
+			}
+			contentHash := sha256.New()
+			// Never trust the header size alone; copy at most what was
+			// declared and fail if the entry is short.
+			copied, err := io.Copy(io.MultiWriter(tw, contentHash), io.LimitReader(tr, header.Size))
+			if err != nil {
+				return nil, asContextError(err, name)
+			}
+			if copied != header.Size {
+				return nil, &ContextError{Reason: "invalid_archive", Entry: name, Detail: "truncated entry"}
+			}
+			totalBytes += copied
+			entries = append(entries, contextEntry{
+				name:     name,
+				typeflag: tar.TypeReg,
+				mode:     mode,
+				digest:   hex.EncodeToString(contentHash.Sum(nil)),
+			})
+		default:
+			return nil, &ContextError{
+				Reason: "unsupported_entry_type",
+				Entry:  name,
+				Detail: fmt.Sprintf("%s entries are not allowed", tarTypeName(header.Typeflag)),
+			}
+		}
+	}
+}
+
+// contextLayout says where a learner's files go in a build context: under
+// dir, or at the root when dir is empty, without the files matching a
+// dropped pattern relative to dir, and under the server's overrides.
+type contextLayout struct {
+	dir       string
+	overrides contextOverrides
+	dropped   []string
+}
+
+// drops reports whether the learner's entry name, relative to the layout's
+// dir, is left out.
+func (l contextLayout) drops(name string, typeflag byte) bool {
+	if typeflag == tar.TypeDir {
+		return false
+	}
+	for _, pattern := range l.dropped {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// contextOverrides are server-side files written over the learner's build
+// context, keyed by path, such as a problem's hidden tests and protected
+// files. They never pass through the client.
+type contextOverrides map[string][]byte
+
+// shadows reports whether a learner entry must be dropped for the
+// overrides: it is an overridden path, or something other than a directory
+// where an override needs its parent directory.
+func (o contextOverrides) shadows(name string, typeflag byte) bool {
+	if _, ok := o[name]; ok {
+		return true
+	}
+	if typeflag == tar.TypeDir {
+		return false
+	}
+	for override := range o {
+		if strings.HasPrefix(override, name+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// write appends the overrides to tw in a stable order, with any parent
+// directories not already in entries, and returns entries extended for
+// cache keying.
+func (o contextOverrides) write(tw *tar.Writer, entries []contextEntry) ([]contextEntry, error) {
+	dirs := map[string]bool{}
+	for _, entry := range entries {
+		if entry.typeflag == tar.TypeDir {
+			dirs[entry.name] = true
+		}
+	}
+	names := make([]string, 0, len(o))
+	for name := range o {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		var missing []string
+		for dir := path.Dir(name); dir != "." && !dirs[dir]; dir = path.Dir(dir) {
+			missing = append(missing, dir)
+		}
+		// Parents go first.
+		for i := len(missing) - 1; i >= 0; i-- {
+			dir := missing[i]
+			dirs[dir] = true
+			if err := tw.WriteHeader(&tar.Header{Name: dir + "/", Typeflag: tar.TypeDir, Mode: 0o755}); err != nil {
+				return nil, fmt.Errorf("write tar header: %w", err)
+			}
+			entries = append(entries, contextEntry{name: dir, typeflag: tar.TypeDir, mode: 0o755})
+		}
+		contents := o[name]
+		if err := tw.WriteHeader(&tar.Header{
+			Name:     name,
+			Typeflag: tar.TypeReg,
+			Mode:     0o644,
+			Size:     int64(len(contents)),
+		}); err != nil {
+			return nil, fmt.Errorf("write tar header: %w", err)
+		}
+		if _, err := tw.Write(contents); err != nil {
+			return nil, fmt.Errorf("write %s: %w", name, err)
+		}
+		digest := sha256.Sum256(contents)
+		entries = append(entries, contextEntry{
+			name:     name,
+			typeflag: tar.TypeReg,
+			mode:     0o644,
+			digest:   hex.EncodeToString(digest[:]),
+		})
+	}
+	return entries, nil
+}
+
+// newContextOverrides validates and merges file sets, later sets winning.
+func newContextOverrides(sets ...map[string][]byte) (contextOverrides, error) {
+	overrides := contextOverrides{}
+	for _, files := range sets {
+		for name, contents := range files {
+			cleaned, err := cleanContextPath(name)
+			if err != nil {
+				return nil, err
+			}
+			if cleaned == "" || cleaned == "Dockerfile" {
+				return nil, &ContextError{Reason: "invalid_path", Entry: name, Detail: "cannot override the context root or Dockerfile"}
+			}
+			overrides[cleaned] = contents
+		}
+	}
+	for name := range overrides {
+		for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+			if _, ok := overrides[dir]; ok {
+				return nil, &ContextError{Reason: "invalid_path", Entry: dir, Detail: "is both a file and a directory"}
+			}
+		}
+	}
+	return overrides, nil
+}
+
+// underDir moves a file set under dir, rejecting paths that would leave it.
+func underDir(dir string, files map[string][]byte) (map[string][]byte, error) {
+	moved := make(map[string][]byte, len(files))
+	for name, contents := range files {
+		cleaned, err := cleanContextPath(name)
+		if err != nil {
+			return nil, err
+		}
+		if cleaned == "" {
+			return nil, &ContextError{Reason: "invalid_path", Entry: name, Detail: "cannot override the context root"}
+		}
+		moved[path.Join(dir, cleaned)] = contents
+	}
+	return moved, nil
+}
+
+// asContextError passes through rejections raised while reading, such as
+// those from decompression guards, and reports anything else as a malformed
+// archive.
+func asContextError(err error, entry string) error {
+	var contextErr *ContextError
+	if errors.As(err, &contextErr) {
+		return contextErr
+	}
+	return &ContextError{Reason: "invalid_archive", Entry: entry, Detail: err.Error()}
+}
+
+// cleanContextPath returns name relative to the context root, or "" for the
+// root itself.
+func cleanContextPath(name string) (string, error) {
+	if strings.ContainsRune(name, 0) || strings.Contains(name, `\`) {
+		return "", &ContextError{Reason: "invalid_path", Entry: name, Detail: "path contains invalid characters"}
+	}
+	if path.IsAbs(name) {
+		return "", &ContextError{Reason: "invalid_path", Entry: name, Detail: "absolute paths are not allowed"}
+	}
+	cleaned := path.Clean(name)
+	if cleaned == "." {
+		return "", nil
+	}
+	if cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", &ContextError{Reason: "invalid_path", Entry: name, Detail: "path escapes the build context"}
+	}
+	return cleaned, nil
+}
+
+func tarTypeName(typeflag byte) string {
+	switch typeflag {
+	case tar.TypeSymlink:
+		return "symlink"
+	case tar.TypeLink:
+		return "hardlink"
+	case tar.TypeChar, tar.TypeBlock:
+		return "device"
+	case tar.TypeFifo:
+		return "fifo"
+	case tar.TypeGNUSparse:
+		return "sparse file"
+	default:
+		return fmt.Sprintf("type %q", typeflag)
+	}
+}
@@ -0,0 +1,212 @@
+package mcptransport
+
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+)
+
+func TestCleanContextPath(t *testing.T) {
+	tests := []struct {
+		name   string
+		want   string
+		reason string
+	}{
+		{"main.py", "main.py", ""},
+		{"./src/main.py", "src/main.py", ""},
+		{"src//lib/../main.py", "src/main.py", ""},
+		{"src/", "src", ""},
+		{".", "", ""},
+		{"./", "", ""},
+		{"a/../..", "", "invalid_path"},
+		{"../etc/passwd", "", "invalid_path"},
+		{"src/../../etc/passwd", "", "invalid_path"},
+		{"/etc/passwd", "", "invalid_path"},
+		{`src\..\..\evil`, "", "invalid_path"},
+		{"bad\x00name", "", "invalid_path"},
+	}
+	for _, tt := range tests {
+		got, err := cleanContextPath(tt.name)
+		if tt.reason == "" {
+			if err != nil || got != tt.want {
+				t.Errorf("cleanContextPath(%q) = %q, %v, want %q", tt.name, got, err, tt.want)
+			}
+			continue
+		}
+		var contextErr *ContextError
+		if !errors.As(err, &contextErr) || contextErr.Reason != tt.reason {
+			t.Errorf("cleanContextPath(%q) error = %v, want %s", tt.name, err, tt.reason)
+		}
+	}
+}
+
+// tarEntry is one header of a test archive; regular files carry body.
+type tarEntry struct {
+	name     string
+	typeflag byte
+	body     string
+	linkname string
+}
+
+func writeTestTar(t *testing.T, entries ...tarEntry) []byte {
+	t.Helper()
+	var archive bytes.Buffer
+	tw := tar.NewWriter(&archive)
+	for _, entry := range entries {
+		header := &tar.Header{Name: entry.name, Typeflag: entry.typeflag, Mode: 0o644, Linkname: entry.linkname}
+		if entry.typeflag == tar.TypeReg {
+			header.Size = int64(len(entry.body))
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(tw, entry.body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return archive.Bytes()
+}
+
+func TestNormalizeBuildContext(t *testing.T) {
+	limits := ContextLimits{MaxTotalBytes: 16, MaxFileBytes: 10, MaxEntries: 3}
+	tests := []struct {
+		name    string
+		entries []tarEntry
+		want    []string
+		reason  string
+	}{
+		{
+			name: "files and directories",
+			entries: []tarEntry{
+				{name: "./src/", typeflag: tar.TypeDir},
+				{name: "./src/main.py", typeflag: tar.TypeReg, body: "print(1)"},
+			},
+			want: []string{"src", "src/main.py"},
+		},
+		{
+			name: "dockerfile and root dropped",
+			entries: []tarEntry{
+				{name: "./", typeflag: tar.TypeDir},
+				{name: "Dockerfile", typeflag: tar.TypeReg, body: "FROM x"},
+				{name: "main.py", typeflag: tar.TypeReg, body: "print(1)"},
+			},
+			want: []string{"main.py"},
+		},
+		{
+			name:    "symlink",
+			entries: []tarEntry{{name: "link", typeflag: tar.TypeSymlink, linkname: "/etc/passwd"}},
+			reason:  "unsupported_entry_type",
+		},
+		{
+			name: "hardlink",
+			entries: []tarEntry{
+				{name: "a", typeflag: tar.TypeReg, body: "a"},
+				{name: "b", typeflag: tar.TypeLink, linkname: "a"},
+			},
+			reason: "unsupported_entry_type",
+		},
+		{
+			name:    "character device",
+			entries: []tarEntry{{name: "null", typeflag: tar.TypeChar}},
+			reason:  "unsupported_entry_type",
+		},
+		{
+			name:    "block device",
+			entries: []tarEntry{{name: "sda", typeflag: tar.TypeBlock}},
+			reason:  "unsupported_entry_type",
+		},
+		{
+			name:    "fifo",
+			entries: []tarEntry{{name: "pipe", typeflag: tar.TypeFifo}},
+			reason:  "unsupported_entry_type",
+		},
+		{
+			name:    "parent traversal",
+			entries: []tarEntry{{name: "../escape", typeflag: tar.TypeReg, body: "x"}},
+			reason:  "invalid_path",
+		},
+		{
+			name:    "absolute path",
+			entries: []tarEntry{{name: "/etc/cron.d/x", typeflag: tar.TypeReg, body: "x"}},
+			reason:  "invalid_path",
+		},
+		{
+			name:    "file over the per-file limit",
+			entries: []tarEntry{{name: "big", typeflag: tar.TypeReg, body: "0123456789a"}},
+			reason:  "file_too_large",
+		},
+		{
+			name: "files over the total limit",
+			entries: []tarEntry{
+				{name: "a", typeflag: tar.TypeReg, body: "0123456789"},
+				{name: "b", typeflag: tar.TypeReg, body: "0123456789"},
+			},
+			reason: "context_too_large",
+		},
+		{
+			name: "too many entries",
+			entries: []tarEntry{
+				{name: "a", typeflag: tar.TypeDir},
+				{name: "b", typeflag: tar.TypeDir},
+				{name: "c", typeflag: tar.TypeDir},
+				{name: "d", typeflag: tar.TypeDir},
+			},
+			reason: "too_many_entries",
+		},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		tw := tar.NewWriter(&out)
+		entries, err := normalizeBuildContext(bytes.NewReader(writeTestTar(t, tt.entries...)), tw, limits, contextLayout{})
+		if tt.reason != "" {
+			var contextErr *ContextError
+			if !errors.As(err, &contextErr) || contextErr.Reason != tt.reason {
+				t.Errorf("%s: normalizeBuildContext() error = %v, want %s", tt.name, err, tt.reason)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: normalizeBuildContext() error = %v", tt.name, err)
+			continue
+		}
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.name)
+		}
+		if !slices.Equal(names, tt.want) {
+			t.Errorf("%s: entries = %v, want %v", tt.name, names, tt.want)
+		}
+	}
+}
+
+func TestNormalizeBuildContextRejectsTruncatedEntries(t *testing.T) {
+	archive := writeTestTar(t, tarEntry{name: "main.py", typeflag: tar.TypeReg, body: "print(1)"})
+	// Cut the archive inside the file's contents.
+	truncated := archive[:512+4]
+	var out bytes.Buffer
+	_, err := normalizeBuildContext(bytes.NewReader(truncated), tar.NewWriter(&out), ContextLimitsFromEnv(), contextLayout{})
+	var contextErr *ContextError
+	if !errors.As(err, &contextErr) || contextErr.Reason != "invalid_archive" {
+		t.Errorf("normalizeBuildContext() error = %v, want invalid_archive", err)
+	}
+}
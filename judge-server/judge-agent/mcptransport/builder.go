@@ -0,0 +1,142 @@
+package mcptransport
+
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// BuildRequest is a build context, including its Dockerfile, to turn into
+// an image named ImageName.
+type BuildRequest struct {
+	ImageName string
+	Context   *bytes.Buffer
+}
+
+// BuildResult is what a Builder produced. Logs is set even when the build
+// fails.
+type BuildResult struct {
+	ImageRef string
+	Logs     string
+	Stderr   string
+}
+
+// Builder turns a build context into an image reference the Runtime can
+// start. Implementations stream progress as it happens through the
+// BuildEvent sink and stage reporter carried by ctx.
+type Builder interface {
+	Build(ctx context.Context, req BuildRequest) (BuildResult, error)
+}
+
+var (
+	builderMu  sync.Mutex
+	builder    Builder
+	builderErr error
+)
+
+// NewBuilderFromEnv picks the builder named by MCP_BUILDER: "buildkit" (the
+// default), "docker" or "fake".
+func NewBuilderFromEnv() (Builder, error) {
+	switch kind := strings.ToLower(strings.TrimSpace(os.Getenv("MCP_BUILDER"))); kind {
+	case "", "buildkit":
+		return newBuildkitBuilderFromEnv()
+	case "docker":
+		return newDockerBuilderFromEnv()
+	case "fake":
+		return &FakeBuilder{}, nil
+	default:
+		return nil, fmt.Errorf("unknown MCP_BUILDER %q", kind)
+	}
+}
+
+// UseBuilder replaces the builder DeployContainer uses.
+func UseBuilder(b Builder) {
+	builderMu.Lock()
+	defer builderMu.Unlock()
+	builder, builderErr = b, nil
+}
+
+func currentBuilder() (Builder, error) {
+	builderMu.Lock()
+	defer builderMu.Unlock()
+	if builder == nil && builderErr == nil {
+		builder, builderErr = NewBuilderFromEnv()
+	}
+	return builder, builderErr
+}
+
+// FakeBuildStep is one scripted action of a FakeBuilder: emit Log as a
+// build log line, or fail the build with Err.
+type FakeBuildStep struct {
+	Log string
+	Err error
+}
+
+// FakeBuilder is an in-memory Builder that plays back Script and records
+// the requests it receives. Registry is prepended to image names and
+// defaults to "fake.local".
+type FakeBuilder struct {
+	Script   []FakeBuildStep
+	Registry string
+
+	mu       sync.Mutex
+	requests []BuildRequest
+}
+
+func (f *FakeBuilder) Build(ctx context.Context, req BuildRequest) (BuildResult, error) {
+	f.mu.Lock()
+	recorded := req
+	if req.Context != nil {
+		recorded.Context = bytes.NewBuffer(bytes.Clone(req.Context.Bytes()))
+	}
+	f.requests = append(f.requests, recorded)
+	f.mu.Unlock()
+
+	var logs strings.Builder
+	for _, step := range f.Script {
+		if err := ctx.Err(); err != nil {
+			return BuildResult{Logs: logs.String()}, err
+		}
+		if step.Log != "" {
+			fmt.Fprintf(&logs, "[fake][stream:1] %s\n", step.Log)
+			emitBuildEvent(ctx, BuildEvent{Type: BuildEventLog, Name: "fake", Stream: 1, Data: step.Log})
+		}
+		if step.Err != nil {
+			return BuildResult{Logs: logs.String()}, step.Err
+		}
+	}
+	reportStage(ctx, StagePushing)
+
+	registry := f.Registry
+	if registry == "" {
+		registry = "fake.local"
+	}
+	return BuildResult{
+		ImageRef: registry + "/" + req.ImageName,
+		Logs:     logs.String(),
+	}, nil
+}
+
+// Requests returns the build requests received so far.
+func (f *FakeBuilder) Requests() []BuildRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]BuildRequest(nil), f.requests...)
+}
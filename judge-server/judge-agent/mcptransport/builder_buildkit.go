@@ -0,0 +1,243 @@
+package mcptransport
+
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/moby/buildkit/client"
+)
+
+// buildkitBuilder solves Dockerfiles on a remote buildkitd and pushes the
+// result to the cluster registry.
+type buildkitBuilder struct {
+	addr          string
+	registry      string
+	insecure      bool
+	tlsCert       string
+	tlsKey        string
+	tlsCA         string
+	tlsServerName string
+}
+
+// newBuildkitBuilderFromEnv reads the BUILDKIT_* and MCP_IMAGE_REGISTRY*
+// variables.
+func newBuildkitBuilderFromEnv() (*buildkitBuilder, error) {
+	b := &buildkitBuilder{
+		addr:          strings.TrimSpace(os.Getenv("BUILDKIT_ADDR")),
+		registry:      strings.TrimSpace(os.Getenv("MCP_IMAGE_REGISTRY")),
+		insecure:      strings.EqualFold(strings.TrimSpace(os.Getenv("MCP_IMAGE_REGISTRY_INSECURE")), "true"),
+		tlsCert:       strings.TrimSpace(os.Getenv("BUILDKIT_TLS_CERT")),
+		tlsKey:        strings.TrimSpace(os.Getenv("BUILDKIT_TLS_KEY")),
+		tlsCA:         strings.TrimSpace(os.Getenv("BUILDKIT_TLS_CA")),
+		tlsServerName: strings.TrimSpace(os.Getenv("BUILDKIT_TLS_SERVER_NAME")),
+	}
+	if b.registry == "" {
+		return nil, fmt.Errorf("MCP_IMAGE_REGISTRY is required to push images from the cluster")
+	}
+	if b.addr == "" {
+		return nil, fmt.Errorf("BUILDKIT_ADDR is required to connect to buildkitd")
+	}
+	if b.tlsCert != "" && b.tlsKey == "" {
+		return nil, fmt.Errorf("BUILDKIT_TLS_KEY must be set when BUILDKIT_TLS_CERT is provided")
+	}
+	return b, nil
+}
+
+func (b *buildkitBuilder) Build(ctx context.Context, req BuildRequest) (BuildResult, error) {
+	tempDir, err := os.MkdirTemp("", "mcp-buildkit-")
+	if err != nil {
+		return BuildResult{}, fmt.Errorf("create build context dir: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	if err := extractTarToDir(bytes.NewReader(req.Context.Bytes()), tempDir); err != nil {
+		return BuildResult{}, fmt.Errorf("extract build context: %w", err)
+	}
+
+	imageRef := fmt.Sprintf("%s/%s", strings.TrimSuffix(b.registry, "/"), req.ImageName)
+
+	var opts []client.ClientOpt
+	if b.tlsCert != "" {
+		opts = append(opts, client.WithCredentials(b.tlsCert, b.tlsKey))
+	}
+	if b.tlsCA != "" {
+		opts = append(opts, client.WithServerConfig(b.tlsServerName, b.tlsCA))
+	}
+	bkClient, err := client.New(ctx, b.addr, opts...)
+	if err != nil {
+		return BuildResult{}, fmt.Errorf("connect to buildkit: %w", err)
+	}
+	defer bkClient.Close()
+
+	statusCh := make(chan *client.SolveStatus)
+	var buildLogs bytes.Buffer
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		vertexNames := map[string]string{}
+		started := map[string]bool{}
+		completed := map[string]bool{}
+		pushing := false
+		for status := range statusCh {
+			for _, vertex := range status.Vertexes {
+				digest := vertex.Digest.String()
+				if vertex.Name != "" {
+					vertexNames[digest] = vertex.Name
+				}
+				if vertex.Started != nil && !started[digest] {
+					started[digest] = true
+					emitBuildEvent(ctx, BuildEvent{
+						Type:   BuildEventVertexStarted,
+						Vertex: digest,
+						Name:   vertex.Name,
+						Time:   *vertex.Started,
+					})
+				}
+				if vertex.Completed != nil && !completed[digest] {
+					completed[digest] = true
+					emitBuildEvent(ctx, BuildEvent{
+						Type:   BuildEventVertexCompleted,
+						Vertex: digest,
+						Name:   vertex.Name,
+						Cached: vertex.Cached,
+						Error:  vertex.Error,
+						Time:   *vertex.Completed,
+					})
+				}
+				if !pushing && vertex.Started != nil && strings.HasPrefix(vertex.Name, "exporting to image") {
+					pushing = true
+					reportStage(ctx, StagePushing)
+				}
+			}
+			for _, entry := range status.Logs {
+				if len(entry.Data) == 0 {
+					continue
+				}
+				vertexName := vertexNames[entry.Vertex.String()]
+				if vertexName == "" {
+					vertexName = entry.Vertex.String()
+				}
+				line := strings.TrimRight(string(entry.Data), "\n")
+				if line == "" {
+					continue
+				}
+				fmt.Fprintf(&buildLogs, "[%s][stream:%d] %s\n", vertexName, entry.Stream, line)
+				emitBuildEvent(ctx, BuildEvent{
+					Type:   BuildEventLog,
+					Vertex: entry.Vertex.String(),
+					Name:   vertexNames[entry.Vertex.String()],
+					Stream: entry.Stream,
+					Data:   line,
+					Time:   entry.Timestamp,
+				})
+				log.Printf("buildkit: [%s][stream:%d] %s", vertexName, entry.Stream, line)
+			}
+		}
+	}()
+	closeStatusCh := func() {
+		defer func() {
+			if recover() != nil {
+				// BuildKit may close the channel internally on error.
+			}
+		}()
+		close(statusCh)
+	}
+
+	solveOpt := client.SolveOpt{
+		Frontend: "dockerfile.v0",
+		FrontendAttrs: map[string]string{
+			"filename": "Dockerfile",
+		},
+		LocalDirs: map[string]string{
+			"context":    tempDir,
+			"dockerfile": tempDir,
+		},
+		Exports: []client.ExportEntry{
+			{
+				Type: client.ExporterImage,
+				Attrs: map[string]string{
+					"name": imageRef,
+					"push": "true",
+				},
+			},
+		},
+	}
+	if b.insecure {
+		solveOpt.Exports[0].Attrs["registry.insecure"] = "true"
+		solveOpt.Exports[0].Attrs["registry.plainhttp"] = "true"
+	}
+	if _, err := bkClient.Solve(ctx, nil, solveOpt, statusCh); err != nil {
+		closeStatusCh()
+		<-done
+		return BuildResult{Logs: buildLogs.String()}, fmt.Errorf("buildkit build failed: %w", err)
+	}
+	closeStatusCh()
+	<-done
+
+	return BuildResult{ImageRef: imageRef, Logs: buildLogs.String()}, nil
+}
+
+func extractTarToDir(r io.Reader, dest string) error {
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		cleanName, err := cleanContextPath(header.Name)
+		if err != nil {
+			return err
+		}
+		if cleanName == "" {
+			continue
+		}
+		targetPath := filepath.Join(dest, filepath.FromSlash(cleanName))
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(targetPath, 0o755); err != nil {
+				return err
+			}
+		case tar.TypeReg, tar.TypeRegA:
+			if err := os.MkdirAll(filepath.Dir(targetPath), 0o755); err != nil {
+				return err
+			}
+			file, err := os.OpenFile(targetPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(header.Mode)&0o755)
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(file, tr); err != nil {
+				file.Close()
+				return err
+			}
+			if err := file.Close(); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unsupported tar entry %s", header.Name)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package mcptransport
+
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const defaultDockerHost = "unix:///var/run/docker.sock"
+
+// dockerBuilder builds through a local Docker Engine API, for running the
+// judge on a laptop without buildkitd. Images are pushed only when
+// MCP_IMAGE_REGISTRY is set; otherwise they stay in the local engine.
+type dockerBuilder struct {
+	client   *http.Client
+	baseURL  string
+	registry string
+}
+
+func newDockerBuilderFromEnv() (*dockerBuilder, error) {
+	client, baseURL, err := newDockerEngineClient(os.Getenv("DOCKER_HOST"))
+	if err != nil {
+		return nil, err
+	}
+	return &dockerBuilder{
+		client:   client,
+		baseURL:  baseURL,
+		registry: strings.TrimSuffix(strings.TrimSpace(os.Getenv("MCP_IMAGE_REGISTRY")), "/"),
+	}, nil
+}
+
+// newDockerEngineClient returns an HTTP client and base URL for a DOCKER_HOST
+// value such as unix:///var/run/docker.sock or tcp://127.0.0.1:2375.
+func newDockerEngineClient(host string) (*http.Client, string, error) {
+	host = strings.TrimSpace(host)
+	if host == "" {
+		host = defaultDockerHost
+	}
+	parsed, err := url.Parse(host)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid DOCKER_HOST %q: %w", host, err)
+	}
+	switch parsed.Scheme {
+	case "unix":
+		socketPath := parsed.Path
+		transport := &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", socketPath)
+			},
+		}
+		return &http.Client{Transport: transport}, "http://docker", nil
+	case "tcp", "http":
+		return &http.Client{}, "http://" + parsed.Host, nil
+	default:
+		return nil, "", fmt.Errorf("unsupported DOCKER_HOST scheme %q", parsed.Scheme)
+	}
+}
+
+// dockerMessage is one line of the Engine API's JSON progress stream.
+type dockerMessage struct {
+	Stream string `json:"stream"`
+	Status string `json:"status"`
+	Error  string `json:"error"`
+	Aux    *struct {
+		ID string `json:"ID"`
+	} `json:"aux"`
+}
+
+func (b *dockerBuilder) Build(ctx context.Context, req BuildRequest) (BuildResult, error) {
+	imageRef := req.ImageName
+	if b.registry != "" {
+		imageRef = b.registry + "/" + req.ImageName
+	}
+
+	query := url.Values{"t": {imageRef}, "rm": {"1"}, "forcerm": {"1"}}
+	buildReq, err := http.NewRequestWithContext(ctx, http.MethodPost, b.baseURL+"/build?"+query.Encode(), bytes.NewReader(req.Context.Bytes()))
+	if err != nil {
+		return BuildResult{}, fmt.Errorf("create docker build request: %w", err)
+	}
+	buildReq.Header.Set("Content-Type", "application/x-tar")
+
+	var buildLogs bytes.Buffer
+	if err := b.stream(ctx, buildReq, "build", &buildLogs); err != nil {
+		return BuildResult{Logs: buildLogs.String()}, fmt.Errorf("docker build failed: %w", err)
+	}
+
+	if b.registry != "" {
+		reportStage(ctx, StagePushing)
+		pushURL := fmt.Sprintf("%s/images/%s/push?tag=latest", b.baseURL, url.PathEscape(imageRef))
+		pushReq, err := http.NewRequestWithContext(ctx, http.MethodPost, pushURL, nil)
+		if err != nil {
+			return BuildResult{Logs: buildLogs.String()}, fmt.Errorf("create docker push request: %w", err)
+		}
+		// The Engine API requires an auth header even for anonymous pushes.
+		pushReq.Header.Set("X-Registry-Auth", base64.URLEncoding.EncodeToString([]byte("{}")))
+		if err := b.stream(ctx, pushReq, "push", &buildLogs); err != nil {
+			return BuildResult{Logs: buildLogs.String()}, fmt.Errorf("docker push failed: %w", err)
+		}
+	}
+
+	return BuildResult{ImageRef: imageRef, Logs: buildLogs.String()}, nil
+}
+
+// stream sends req and relays the Engine API progress messages as build
+// events, returning the first error message the engine reports.
+func (b *dockerBuilder) stream(ctx context.Context, req *http.Request, vertex string, logs *bytes.Buffer) error {
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("docker engine returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	emitBuildEvent(ctx, BuildEvent{Type: BuildEventVertexStarted, Vertex: vertex, Name: vertex, Time: time.Now()})
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var message dockerMessage
+		if err := decoder.Decode(&message); err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("read docker %s stream: %w", vertex, err)
+		}
+		if message.Error != "" {
+			emitBuildEvent(ctx, BuildEvent{Type: BuildEventVertexCompleted, Vertex: vertex, Name: vertex, Error: message.Error, Time: time.Now()})
+			return fmt.Errorf("%s", message.Error)
+		}
+		line := strings.TrimRight(message.Stream+message.Status, "\n")
+		if line == "" {
+			continue
+		}
+		fmt.Fprintf(logs, "[%s][stream:1] %s\n", vertex, line)
+		log.Printf("docker: [%s] %s", vertex, line)
+		emitBuildEvent(ctx, BuildEvent{Type: BuildEventLog, Vertex: vertex, Name: vertex, Stream: 1, Data: line, Time: time.Now()})
+	}
+	emitBuildEvent(ctx, BuildEvent{Type: BuildEventVertexCompleted, Vertex: vertex, Name: vertex, Time: time.Now()})
+	return nil
+}
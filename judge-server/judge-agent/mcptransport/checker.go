@@ -0,0 +1,209 @@
+package mcptransport
+
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"main/judge-agent/dockerpolicy"
+	"main/judge-agent/judging"
+	"main/judge-agent/profiles"
+	"main/judge-agent/sandboxpolicy"
+)
+
+const defaultCheckerTimeout = 30 * time.Second
+
+// checkerWrapper unpacks the tar on stdin into scratch space and calls the
+// checker given as its arguments the testlib way: input, the program's
+// output, then the expected answer.
+const checkerWrapper = `
+dir=$(mktemp -d) || exit 125
+tar -xf - -C "$dir" || exit 125
+"$@" "$dir/input.txt" "$dir/output.txt" "$dir/answer.txt" </dev/null
+code=$?
+rm -rf "$dir"
+exit $code
+`
+
+// testlib exit codes.
+const (
+	testlibOK                = 0
+	testlibWrongAnswer       = 1
+	testlibPresentationError = 2
+)
+
+// outputChecker judges the output a program printed for test.
+type outputChecker func(ctx context.Context, test judging.TestCase, actual string) (judging.Comparison, error)
+
+// newOutputChecker returns the checker spec selects and a function that
+// releases what it started. Program checkers are built and started in a
+// sandbox of their own, apart from the submission they judge.
+func newOutputChecker(ctx context.Context, sandbox Runtime, spec judging.CheckerSpec) (outputChecker, func(), error) {
+	if spec.Type != judging.CheckerProgram {
+		comparator, err := judging.NewComparator(spec)
+		if err != nil {
+			return nil, nil, err
+		}
+		check := func(ctx context.Context, test judging.TestCase, actual string) (judging.Comparison, error) {
+			return comparator.Compare(test.ExpectedOutput, actual), nil
+		}
+		return check, func() {}, nil
+	}
+	if err := spec.Validate(); err != nil {
+		return nil, nil, err
+	}
+	return startCheckerProgram(ctx, sandbox, *spec.Program)
+}
+
+func startCheckerProgram(ctx context.Context, sandbox Runtime, source judging.CheckerSource) (outputChecker, func(), error) {
+	dockerfile := source.DockerFile
+	var profile *profiles.Profile
+	if source.Profile != "" {
+		found, err := profiles.Lookup(source.Profile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("checker: %w", err)
+		}
+		profile = &found
+		dockerfile = profile.Dockerfile()
+	}
+	policy, err := dockerpolicy.Active()
+	if err != nil {
+		return nil, nil, fmt.Errorf("checker: %w", err)
+	}
+	if err := policy.Validate(dockerfile); err != nil {
+		return nil, nil, fmt.Errorf("checker: %w", err)
+	}
+	var command []string
+	if profile != nil {
+		command = profile.RunCommand
+	} else {
+		var err error
+		if command, err = programCommand(dockerfile); err != nil {
+			return nil, nil, fmt.Errorf("checker: find program command: %w", err)
+		}
+	}
+
+	contents, err := tarFiles(source.Files)
+	if err != nil {
+		return nil, nil, fmt.Errorf("checker: %w", err)
+	}
+	// The checker is problem material; keep its build out of the event
+	// stream the submitter sees.
+	image, err := buildImage(WithBuildEventSink(ctx, nil), dockerfile, contents, contextLayout{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("build checker: %w: %s", err, strings.TrimSpace(image.logs))
+	}
+
+	sandboxPolicy, err := sandboxpolicy.Active()
+	if err != nil {
+		return nil, nil, fmt.Errorf("checker: %w", err)
+	}
+	name := podNamePrefix + uuid.NewString()
+	release := func() { cleanupPartialPod(context.WithoutCancel(ctx), sandbox, name) }
+	if _, err := sandbox.Start(ctx, ContainerSpec{
+		Name:    name,
+		Image:   image.ref,
+		Labels:  map[string]string{"mcp.role": "checker"},
+		Command: idleCommand,
+		Sandbox: sandboxPolicy.ForProfile(profile),
+	}); err != nil {
+		release()
+		return nil, nil, fmt.Errorf("start checker: %w", err)
+	}
+	readyCtx, cancelReady := context.WithTimeout(ctx, envDuration("MCP_STARTUP_TIMEOUT", defaultStartupTimeout))
+	_, err = sandbox.WaitReady(readyCtx, name)
+	cancelReady()
+	if err != nil {
+		release()
+		return nil, nil, fmt.Errorf("start checker: %w", err)
+	}
+
+	timeout := envDuration("MCP_CHECKER_TIMEOUT", defaultCheckerTimeout)
+	check := func(ctx context.Context, test judging.TestCase, actual string) (judging.Comparison, error) {
+		files, err := tarFiles(map[string]string{
+			"input.txt":  test.Input,
+			"output.txt": actual,
+			"answer.txt": test.ExpectedOutput,
+		})
+		if err != nil {
+			return judging.Comparison{}, err
+		}
+		execCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		args := append([]string{"/bin/sh", "-c", checkerWrapper, "checker"}, command...)
+		result, err := sandbox.Exec(execCtx, name, args, bytes.NewReader(files))
+		if err != nil {
+			return judging.Comparison{}, fmt.Errorf("run checker on %s: %w", test.Name, err)
+		}
+		message := strings.TrimSpace(result.Stderr)
+		if message == "" {
+			message = strings.TrimSpace(result.Stdout)
+		}
+		message = capReportOutput(message)
+		switch result.ExitCode {
+		case testlibOK:
+			return judging.Comparison{OK: true, CheckerVerdict: "ok", Message: message}, nil
+		case testlibWrongAnswer:
+			return judging.Comparison{CheckerVerdict: "wrong answer", Message: message}, nil
+		case testlibPresentationError:
+			return judging.Comparison{CheckerVerdict: "presentation error", Message: message}, nil
+		default:
+			// Anything else, including testlib's FAIL, is a broken checker
+			// or problem rather than a wrong submission.
+			log.Printf("checker %s failed on %s with exit %d: %s", name, test.Name, result.ExitCode, message)
+			return judging.Comparison{}, fmt.Errorf("checker failed on %s with exit code %d: %s", test.Name, result.ExitCode, message)
+		}
+	}
+	return check, release, nil
+}
+
+// tarFiles packs files, keyed by slash-separated path, into a tar in a
+// stable order.
+func tarFiles(files map[string]string) ([]byte, error) {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, name := range names {
+		contents := files[name]
+		if err := tw.WriteHeader(&tar.Header{
+			Name: name,
+			Mode: 0o644,
+			Size: int64(len(contents)),
+		}); err != nil {
+			return nil, fmt.Errorf("write %s: %w", name, err)
+		}
+		if _, err := tw.Write([]byte(contents)); err != nil {
+			return nil, fmt.Errorf("write %s: %w", name, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -18,124 +18,494 @@ import (
 	"archive/tar"
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
-	corev1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/rest"
 
-	"github.com/moby/buildkit/client"
+	"main/judge-agent/dockerpolicy"
+	"main/judge-agent/judging"
+	"main/judge-agent/problems"
+	"main/judge-agent/profiles"
+	"main/judge-agent/sandboxpolicy"
+	"main/judge-agent/testreport"
 )
 
 type Input struct {
-	DockerFile    string `json:"docker_file" jsonschema:"raw Dockerfile contents for deployment"`
+	DockerFile string `json:"docker_file,omitempty" jsonschema:"raw Dockerfile contents for deployment, when the server allows them; prefer profile"`
+	Profile    string `json:"profile,omitempty" jsonschema:"language profile to build with instead of a Dockerfile (react, python, cpp)"`
+	// ProblemID names a problem package whose profile, tests, limits and
+	// checker replace any given here. A docker_file is refused with it.
+	ProblemID string `json:"problem_id,omitempty" jsonschema:"problem package to judge against; builds with the problem's profile and refuses docker_file"`
+	// Tests switch the deploy to judging: the program is run once per
+	// test instead of being left running.
+	Tests   []judging.TestCase  `json:"tests,omitempty" jsonschema:"stdin/stdout test cases to judge the program against"`
+	Limits  judging.Limits      `json:"limits,omitempty" jsonschema:"default time and memory limits for each test"`
+	Checker judging.CheckerSpec `json:"checker,omitempty" jsonschema:"how test output is judged; compares lines by default"`
+	// RunTestSuite runs the profile's test command once the program is
+	// up, so success means the suite passed rather than only the build.
+	RunTestSuite  bool `json:"run_test_suite,omitempty" jsonschema:"run the profile's test suite (Jest for react) after the app starts"`
 	BuildContents bytes.Buffer
 }
 
 type Output struct {
-	Stdout        string `json:"standard_out" jsonschema:"container standard output"`
-	Stderr        string `json:"standard_error" jsonschema:"container error output"`
+	Stdout        string `json:"standard_out" jsonschema:"container standard output at startup or exit, capped"`
+	Stderr        string `json:"standard_error" jsonschema:"container error output at startup or exit, capped; empty when the runtime interleaves it into standard_out"`
 	BuildLogs     string `json:"build_logs" jsonschema:"build output logs"`
 	BuildFailed   bool   `json:"build_failed" jsonschema:"whether the build failed"`
-	ContainerName string `json:"container_name" jsonschema:"container name"`
-	ContainerID   string `json:"container_id" jsonschema:"container ID"`
+	ContainerName string `json:"container_name" jsonschema:"name of the running container; empty when none was left running, as after judging tests"`
+	ContainerID   string `json:"container_id" jsonschema:"ID of the running container; empty when none was left running"`
 	ImageName     string `json:"image_name" jsonschema:"image name"`
 	ImageID       string `json:"image_id" jsonschema:"image ID"`
+	CacheHit      bool   `json:"cache_hit" jsonschema:"true when a previously built image was reused"`
+	Endpoint      string `json:"endpoint,omitempty" jsonschema:"host:port of the service in front of the container's exposed port"`
+	// StartupLatencyMs runs from pod creation until it was ready or gave up.
+	StartupLatencyMs int64               `json:"startup_latency_ms" jsonschema:"milliseconds from pod creation until ready or terminal"`
+	TerminalReason   string              `json:"terminal_reason,omitempty" jsonschema:"why the container stopped, e.g. Completed, OOMKilled, ImagePullBackOff or Timeout"`
+	LogsTruncated    bool                `json:"logs_truncated" jsonschema:"true when the container output was cut to the size limit"`
+	ExitCode         *int                `json:"exit_code,omitempty" jsonschema:"container exit code, once it has exited"`
+	RestartCount     int                 `json:"restart_count" jsonschema:"times the container was restarted"`
+	TestReport       *judging.TestReport `json:"test_report,omitempty" jsonschema:"per-test verdicts when tests were given"`
+	SuiteReport      *testreport.Report  `json:"suite_report,omitempty" jsonschema:"per-test results of the profile's test suite when it was run"`
+	// TestsPassed is set whenever tests ran.
+	TestsPassed *bool `json:"tests_passed,omitempty" jsonschema:"whether every test passed, when tests were run"`
 }
 
+const defaultStartupTimeout = 3 * time.Minute
+
+// ErrDockerfileNotAllowed is returned for a raw docker_file while the server
+// only builds with profiles.
+var ErrDockerfileNotAllowed = errors.New("docker_file is disabled on this server; build with a profile")
+
+// DockerfilesAllowed reports whether deploys may bring their own Dockerfile,
+// which MCP_ALLOW_DOCKERFILE=true turns on. Otherwise the only way to build
+// is a server-side profile.
+func DockerfilesAllowed() bool {
+	return strings.EqualFold(strings.TrimSpace(os.Getenv("MCP_ALLOW_DOCKERFILE")), "true")
+}
+
+// CheckClientChecker refuses a checker program with its own Dockerfile
+// from a client while DockerfilesAllowed is off; it would otherwise build
+// and run the Dockerfile the server refuses for submissions. Checkers from
+// problem packages are trusted and never pass through here.
+func CheckClientChecker(spec judging.CheckerSpec) error {
+	if spec.Program != nil && strings.TrimSpace(spec.Program.DockerFile) != "" && !DockerfilesAllowed() {
+		return fmt.Errorf("checker: %w", ErrDockerfileNotAllowed)
+	}
+	return nil
+}
+
+// ErrProblemBuildSpec is returned for a deploy of a problem that asks for a
+// Dockerfile, or a profile other than the problem's own.
+var ErrProblemBuildSpec = errors.New("problems build with their own profile")
+
+// ProblemProfile returns the profile submissions to problem build with.
+// Hidden files must only meet a Dockerfile the server wrote, so a client's
+// docker_file, or a profile other than the problem's, is refused.
+func ProblemProfile(problem *problems.Package, dockerFile, profile string) (string, error) {
+	if strings.TrimSpace(dockerFile) != "" {
+		return "", fmt.Errorf("%w: docker_file is not allowed with problem_id", ErrProblemBuildSpec)
+	}
+	if strings.TrimSpace(profile) != "" {
+		found, err := profiles.Lookup(profile)
+		if err != nil {
+			return "", err
+		}
+		if found.Name != problem.Manifest.Profile {
+			return "", fmt.Errorf("%w: problem %s builds with profile %q, not %q", ErrProblemBuildSpec, problem.Manifest.ID, problem.Manifest.Profile, found.Name)
+		}
+	}
+	return problem.Manifest.Profile, nil
+}
+
+// Problem build contexts keep the submission, with the protected files
+// over it, apart from the hidden files, which only the final stage of the
+// profile's HiddenDockerfile copies in.
+const (
+	submissionDir = "submission"
+	hiddenDir     = "hidden"
+)
+
 func DeployContainer(ctx context.Context, req *mcp.CallToolRequest, input Input) (*mcp.CallToolResult, Output, error) {
-	ctx = context.Background()
+	var layout contextLayout
+	if err := CheckClientChecker(input.Checker); err != nil {
+		return nil, Output{}, err
+	}
+	if id := strings.TrimSpace(input.ProblemID); id != "" {
+		problem, err := problems.Active().Get(id)
+		if err != nil {
+			return nil, Output{}, err
+		}
+		if input.Profile, err = ProblemProfile(problem, input.DockerFile, input.Profile); err != nil {
+			return nil, Output{}, err
+		}
+		input = withProblem(input, problem)
+		protected, err := underDir(submissionDir, problem.ProtectedFiles)
+		if err != nil {
+			return nil, Output{}, fmt.Errorf("problem %s: %w", id, err)
+		}
+		hidden, err := underDir(hiddenDir, problem.HiddenFiles)
+		if err != nil {
+			return nil, Output{}, fmt.Errorf("problem %s: %w", id, err)
+		}
+		if layout.overrides, err = newContextOverrides(protected, hidden); err != nil {
+			return nil, Output{}, fmt.Errorf("problem %s: %w", id, err)
+		}
+		layout.dir = submissionDir
+	}
+	if strings.TrimSpace(input.DockerFile) != "" && !DockerfilesAllowed() {
+		return nil, Output{}, ErrDockerfileNotAllowed
+	}
+	var profile *profiles.Profile
+	if strings.TrimSpace(input.Profile) != "" {
+		if strings.TrimSpace(input.DockerFile) != "" {
+			return nil, Output{}, fmt.Errorf("docker_file and profile are mutually exclusive")
+		}
+		found, err := profiles.Lookup(input.Profile)
+		if err != nil {
+			return nil, Output{}, err
+		}
+		profile = &found
+		if layout.dir != "" {
+			input.DockerFile = profile.HiddenDockerfile(layout.dir, hiddenDir)
+			if input.RunTestSuite {
+				// The problem ships its own dependency files, and with
+				// them the test command; lock files of the learner's
+				// must not pick other packages for it either.
+				layout.dropped = profile.DependencyFiles
+			}
+		} else {
+			input.DockerFile = profile.Dockerfile()
+		}
+	}
 	if strings.TrimSpace(input.DockerFile) == "" {
-		return nil, Output{}, fmt.Errorf("docker_file is required")
+		return nil, Output{}, fmt.Errorf("docker_file or profile is required")
+	}
+	policy, err := dockerpolicy.Active()
+	if err != nil {
+		return nil, Output{}, err
+	}
+	if err := policy.Validate(input.DockerFile); err != nil {
+		return nil, Output{BuildFailed: true, BuildLogs: err.Error()}, err
+	}
+	if err := input.Checker.Validate(); err != nil {
+		return nil, Output{}, err
+	}
+	if input.RunTestSuite {
+		if len(input.Tests) > 0 {
+			return nil, Output{}, fmt.Errorf("tests and run_test_suite are mutually exclusive")
+		}
+		if profile == nil || len(profile.TestCommand) == 0 {
+			return nil, Output{}, fmt.Errorf("run_test_suite needs a profile with a test command")
+		}
 	}
 
+	reportStage(ctx, StageBuilding)
+	image, err := buildImage(ctx, input.DockerFile, input.BuildContents.Bytes(), layout)
+	if err != nil {
+		output := Output{BuildLogs: image.logs, BuildFailed: true}
+		if len(input.Tests) > 0 {
+			output.TestReport = judging.CompileErrorReport(input.Tests, image.logs)
+		}
+		return nil, output, err
+	}
+	imageRef := image.ref
+
+	output := Output{
+		BuildLogs: image.logs,
+		ImageName: imageRef,
+		ImageID:   imageRef,
+		CacheHit:  image.cacheHit,
+	}
+	fail := func(err error) (*mcp.CallToolResult, Output, error) {
+		output.BuildFailed = true
+		return nil, output, err
+	}
+
+	reportStage(ctx, StageScheduling)
+	sandbox, err := currentRuntime()
+	if err != nil {
+		return fail(err)
+	}
+	if len(input.Tests) > 0 {
+		return judgeTests(ctx, sandbox, input, profile, imageRef, output)
+	}
+	port := 0
+	if profile != nil {
+		port = profile.Port
+	} else if port, err = exposedPort(input.DockerFile); err != nil {
+		log.Printf("DeployContainer: not exposing a port: %v", err)
+	}
+	sandboxPolicy, err := sandboxpolicy.Active()
+	if err != nil {
+		return fail(err)
+	}
+	podName := podNamePrefix + uuid.NewString()
+	started := time.Now()
+	info, err := sandbox.Start(ctx, ContainerSpec{
+		Name:        podName,
+		Image:       imageRef,
+		Annotations: map[string]string{"mcp.dockerfile": input.DockerFile},
+		Port:        port,
+		Sandbox:     sandboxPolicy.ForProfile(profile),
+	})
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		log.Printf("DeployContainer: failed to create pod: %v", err)
+		if ctx.Err() != nil {
+			cleanupPartialPod(context.WithoutCancel(ctx), sandbox, podName)
+		}
+		return fail(err)
+	}
+	output.ContainerName, output.ContainerID = podName, info.ID
+	// discard removes the sandbox after a failure, and with it any mention
+	// a client could act on.
+	discard := func(err error) (*mcp.CallToolResult, Output, error) {
+		cleanupPartialPod(context.WithoutCancel(ctx), sandbox, podName)
+		output.ContainerName, output.ContainerID, output.Endpoint = "", "", ""
+		return fail(err)
+	}
+
+	timeout := envDuration("MCP_STARTUP_TIMEOUT", defaultStartupTimeout)
+	readyCtx, cancelReady := context.WithTimeout(ctx, timeout)
+	_, err = sandbox.WaitReady(readyCtx, podName)
+	cancelReady()
+	output.StartupLatencyMs = time.Since(started).Milliseconds()
+	var startupErr *StartupError
+	switch {
+	case err == nil:
+	case ctx.Err() != nil:
+		return discard(ctx.Err())
+	case errors.As(err, &startupErr) && port == 0 && (startupErr.Reason == "Completed" || startupErr.Reason == "Error"):
+		// Without a port the program runs to completion, so exiting is
+		// its outcome rather than a failure to start.
+		output.TerminalReason = startupErr.Reason
+		err = nil
+	case errors.As(err, &startupErr):
+		output.TerminalReason = startupErr.Reason
+	case errors.Is(err, context.DeadlineExceeded):
+		output.TerminalReason = "Timeout"
+		err = &StartupError{Name: podName, Reason: "Timeout", Message: fmt.Sprintf("not ready after %s", timeout)}
+	}
+	// Capture what the program printed, and how it exited if it did,
+	// before a failed sandbox is cleaned up below.
+	captureRuntimeState(ctx, sandbox, podName, &output)
+	if err != nil {
+		log.Printf("DeployContainer: pod %s failed to start: %v", podName, err)
+		return discard(err)
+	}
+
+	if port > 0 && output.TerminalReason == "" {
+		endpoint, err := sandbox.Expose(ctx, podName, port)
+		if err != nil {
+			log.Printf("DeployContainer: failed to expose pod %s: %v", podName, err)
+			return discard(err)
+		}
+		output.Endpoint = endpoint
+	}
+
+	if input.RunTestSuite {
+		reportStage(ctx, StageTesting)
+		report, err := runTestSuite(ctx, sandbox, podName, *profile)
+		if err != nil {
+			log.Printf("DeployContainer: test suite failed to run in pod %s: %v", podName, err)
+			return discard(err)
+		}
+		output.SuiteReport = report
+		output.TestsPassed = &report.Success
+	}
+
+	return nil, output, nil
+}
+
+// withProblem applies a problem package to input. The package's profile,
+// tests, limits, checker and test suite always win so a client cannot
+// weaken them.
+func withProblem(input Input, problem *problems.Package) Input {
+	input.DockerFile = ""
+	input.Profile = problem.Manifest.Profile
+	input.Tests = problem.Tests
+	input.Limits = problem.Manifest.Limits
+	input.Checker = problem.CheckerSpec()
+	// A package without stdin tests is judged by the test files it hides
+	// in the image, run by the profile's test command.
+	input.RunTestSuite = len(problem.Tests) == 0 && len(problem.HiddenFiles) > 0
+	return input
+}
+
+// builtImage is an image built, or found in the build cache, by buildImage.
+type builtImage struct {
+	ref      string
+	logs     string
+	cacheHit bool
+}
+
+// buildImage builds dockerfile over the given build context tar, laid out
+// as layout says, reusing the image of an identical earlier build. The
+// layout's overrides are merged in after the learner's files, the same way
+// the Dockerfile is. A failed build still returns what it printed in logs.
+func buildImage(ctx context.Context, dockerfile string, contents []byte, layout contextLayout) (builtImage, error) {
 	var buildContext bytes.Buffer
+	var contextEntries []contextEntry
 	tarWriter := tar.NewWriter(&buildContext)
-	if input.BuildContents.Len() > 0 {
-		tarReader := tar.NewReader(bytes.NewReader(input.BuildContents.Bytes()))
-		for {
-			header, err := tarReader.Next()
-			if err == io.EOF {
-				break
-			}
-			if err != nil {
-				log.Printf("DeployContainer: failed to read build context: %v", err)
-				return nil, Output{BuildFailed: true}, fmt.Errorf("read build context: %w", err)
-			}
-			if header.Name == "Dockerfile" {
-				continue
-			}
-			if err := tarWriter.WriteHeader(header); err != nil {
-				log.Printf("DeployContainer: failed to write tar header %s: %v", header.Name, err)
-				return nil, Output{BuildFailed: true}, fmt.Errorf("write tar header: %w", err)
-			}
-			if header.Typeflag == tar.TypeReg || header.Typeflag == tar.TypeRegA {
-				if _, err := io.Copy(tarWriter, tarReader); err != nil {
-					log.Printf("DeployContainer: failed to copy build context file %s: %v", header.Name, err)
-					return nil, Output{BuildFailed: true}, fmt.Errorf("copy build context file: %w", err)
-				}
-			}
+	if len(contents) > 0 || layout.dir != "" {
+		entries, err := normalizeBuildContext(bytes.NewReader(contents), tarWriter, ContextLimitsFromEnv(), layout)
+		if err != nil {
+			log.Printf("buildImage: failed to read build context: %v", err)
+			return builtImage{logs: err.Error()}, fmt.Errorf("read build context: %w", err)
 		}
+		contextEntries = entries
+	}
+	contextEntries, err := layout.overrides.write(tarWriter, contextEntries)
+	if err != nil {
+		log.Printf("buildImage: failed to write server files: %v", err)
+		return builtImage{}, err
 	}
-	dockerfileContents := []byte(input.DockerFile)
+	dockerfileContents := []byte(dockerfile)
 	if err := tarWriter.WriteHeader(&tar.Header{
 		Name: "Dockerfile",
 		Mode: 0o644,
 		Size: int64(len(dockerfileContents)),
 	}); err != nil {
-		log.Printf("DeployContainer: failed to write Dockerfile header: %v", err)
-		return nil, Output{BuildFailed: true}, fmt.Errorf("write Dockerfile header: %w", err)
+		log.Printf("buildImage: failed to write Dockerfile header: %v", err)
+		return builtImage{}, fmt.Errorf("write Dockerfile header: %w", err)
 	}
 	if _, err := tarWriter.Write(dockerfileContents); err != nil {
-		log.Printf("DeployContainer: failed to write Dockerfile contents: %v", err)
-		return nil, Output{BuildFailed: true}, fmt.Errorf("write Dockerfile contents: %w", err)
+		log.Printf("buildImage: failed to write Dockerfile contents: %v", err)
+		return builtImage{}, fmt.Errorf("write Dockerfile contents: %w", err)
 	}
 	if err := tarWriter.Close(); err != nil {
-		log.Printf("DeployContainer: failed to close tar writer: %v", err)
-		return nil, Output{BuildFailed: true}, fmt.Errorf("close tar writer: %w", err)
+		log.Printf("buildImage: failed to close tar writer: %v", err)
+		return builtImage{}, fmt.Errorf("close tar writer: %w", err)
+	}
+
+	cacheKey := buildContextKey(dockerfile, contextEntries)
+	if cached, ok := defaultBuildCache.get(cacheKey); ok {
+		log.Printf("buildImage: build cache hit %s -> %s", cacheKey, cached.imageRef)
+		emitBuildEvent(ctx, BuildEvent{
+			Type: BuildEventLog,
+			Name: "build cache",
+			Data: "reusing cached image " + cached.imageRef,
+			Time: time.Now(),
+		})
+		return builtImage{ref: cached.imageRef, logs: cached.buildLogs, cacheHit: true}, nil
 	}
+	imageBuilder, err := currentBuilder()
+	if err != nil {
+		return builtImage{}, err
+	}
+	result, err := imageBuilder.Build(ctx, BuildRequest{
+		ImageName: imageNamePrefix + uuid.NewString(),
+		Context:   &buildContext,
+	})
+	image := builtImage{ref: result.ImageRef, logs: result.Logs + result.Stderr}
+	if err != nil {
+		return image, err
+	}
+	defaultBuildCache.put(cacheKey, image.ref, image.logs)
+	return image, nil
+}
 
-	imageName := "mcp-image-" + uuid.NewString()
-	imageRef, buildStdout, buildStderr, err := buildImageWithBuildkit(ctx, imageName, &buildContext)
+// judgeTests starts an idle sandbox from the built image, runs the program
+// once per test inside it and removes it again.
+func judgeTests(ctx context.Context, sandbox Runtime, input Input, profile *profiles.Profile, imageRef string, output Output) (*mcp.CallToolResult, Output, error) {
+	fail := func(err error) (*mcp.CallToolResult, Output, error) {
+		output.BuildFailed = true
+		return nil, output, err
+	}
+	var command []string
+	if profile != nil {
+		command = profile.RunCommand
+	} else {
+		var err error
+		if command, err = programCommand(input.DockerFile); err != nil {
+			return fail(fmt.Errorf("find program command: %w", err))
+		}
+	}
+	check, releaseChecker, err := newOutputChecker(ctx, sandbox, input.Checker)
 	if err != nil {
-		return nil, Output{
-			Stdout:      buildStdout,
-			Stderr:      buildStderr,
-			BuildLogs:   buildStdout,
-			BuildFailed: true,
-		}, err
+		return fail(err)
 	}
+	defer releaseChecker()
 
-	podName := "mcp-pod-" + uuid.NewString()
-	podUID, err := createKubernetesPod(ctx, podName, imageRef, input.DockerFile)
+	sandboxPolicy, err := sandboxpolicy.Active()
 	if err != nil {
-		log.Printf("DeployContainer: failed to create pod: %v", err)
-		return nil, Output{
-			Stdout:      buildStdout,
-			Stderr:      buildStderr,
-			BuildLogs:   buildStdout,
-			BuildFailed: true,
-		}, err
-	}
-
-	return nil, Output{
-		Stdout:        buildStdout,
-		Stderr:        buildStderr,
-		BuildLogs:     buildStdout,
-		BuildFailed:   false,
-		ContainerName: podName,
-		ContainerID:   podUID,
-		ImageName:     imageRef,
-		ImageID:       imageRef,
-	}, nil
+		return fail(err)
+	}
+	podName := podNamePrefix + uuid.NewString()
+	started := time.Now()
+	if _, err := sandbox.Start(ctx, ContainerSpec{
+		Name:        podName,
+		Image:       imageRef,
+		Annotations: map[string]string{"mcp.dockerfile": input.DockerFile},
+		Command:     idleCommand,
+		Sandbox:     sandboxPolicy.ForProfile(profile),
+	}); err != nil {
+		log.Printf("DeployContainer: failed to create test pod: %v", err)
+		cleanupPartialPod(context.WithoutCancel(ctx), sandbox, podName)
+		return fail(err)
+	}
+	// The sandbox is gone once judging ends, so the output never names it;
+	// clients could only act on a missing container.
+	defer cleanupPartialPod(context.WithoutCancel(ctx), sandbox, podName)
+
+	timeout := envDuration("MCP_STARTUP_TIMEOUT", defaultStartupTimeout)
+	readyCtx, cancelReady := context.WithTimeout(ctx, timeout)
+	_, err = sandbox.WaitReady(readyCtx, podName)
+	cancelReady()
+	output.StartupLatencyMs = time.Since(started).Milliseconds()
+	if err != nil {
+		var startupErr *StartupError
+		if errors.As(err, &startupErr) {
+			output.TerminalReason = startupErr.Reason
+		}
+		captureRuntimeState(ctx, sandbox, podName, &output)
+		return fail(err)
+	}
+
+	reportStage(ctx, StageTesting)
+	report, err := runTests(ctx, sandbox, podName, command, input.Tests, input.Limits, check)
+	if err != nil {
+		return fail(err)
+	}
+	output.TestReport = report
+	passed := report.Verdict == judging.Accepted
+	output.TestsPassed = &passed
+	return nil, output, nil
+}
+
+// captureRuntimeState fills the container output, exit code and restart
+// count into output. Failures are only logged; a missing log should not
+// fail a deploy.
+func captureRuntimeState(ctx context.Context, sandbox Runtime, name string, output *Output) {
+	logs, err := collectLogs(ctx, sandbox, name)
+	if err != nil {
+		log.Printf("DeployContainer: failed to collect logs for %s: %v", name, err)
+	}
+	output.Stdout, output.Stderr, output.LogsTruncated = logs.stdout, logs.stderr, logs.truncated
+
+	info, err := sandbox.Inspect(ctx, name)
+	if err != nil {
+		log.Printf("DeployContainer: failed to inspect %s: %v", name, err)
+		return
+	}
+	output.RestartCount = info.RestartCount
+	if info.Terminated {
+		exitCode := info.ExitCode
+		output.ExitCode = &exitCode
+		if info.Reason != "" {
+			output.TerminalReason = info.Reason
+		}
+	}
 }
 
 func addDirectoryToTar(tw *tar.Writer, sourceDir, tarPrefix string) error {
@@ -190,241 +560,83 @@ func addDirectoryToTar(tw *tar.Writer, sourceDir, tarPrefix string) error {
 	})
 }
 
-func buildImageWithBuildkit(ctx context.Context, imageName string, buildContext *bytes.Buffer) (string, string, string, error) {
-	registry := strings.TrimSpace(os.Getenv("MCP_IMAGE_REGISTRY"))
-	if registry == "" {
-		return "", "", "", fmt.Errorf("MCP_IMAGE_REGISTRY is required to push images from the cluster")
+// cleanupPartialPod deletes a pod whose deploy was cancelled while it was
+// being created. Failures are only logged because the caller is already
+// returning an error.
+func cleanupPartialPod(ctx context.Context, sandbox Runtime, podName string) {
+	if err := sandbox.Stop(ctx, podName); err != nil && !errors.Is(err, ErrContainerNotFound) {
+		log.Printf("DeployContainer: failed to clean up pod %s: %v", podName, err)
 	}
-	buildkitAddr := strings.TrimSpace(os.Getenv("BUILDKIT_ADDR"))
-	if buildkitAddr == "" {
-		return "", "", "", fmt.Errorf("BUILDKIT_ADDR is required to connect to buildkitd")
-	}
-	tempDir, err := os.MkdirTemp("", "mcp-buildkit-")
-	if err != nil {
-		return "", "", "", fmt.Errorf("create build context dir: %w", err)
-	}
-	defer os.RemoveAll(tempDir)
-
-	if err := extractTarToDir(bytes.NewReader(buildContext.Bytes()), tempDir); err != nil {
-		return "", "", "", fmt.Errorf("extract build context: %w", err)
-	}
-
-	imageRef := fmt.Sprintf("%s/%s", strings.TrimSuffix(registry, "/"), imageName)
+}
 
-	var opts []client.ClientOpt
-	if certPath := strings.TrimSpace(os.Getenv("BUILDKIT_TLS_CERT")); certPath != "" {
-		keyPath := strings.TrimSpace(os.Getenv("BUILDKIT_TLS_KEY"))
-		if keyPath == "" {
-			return "", "", "", fmt.Errorf("BUILDKIT_TLS_KEY must be set when BUILDKIT_TLS_CERT is provided")
-		}
-		opts = append(opts, client.WithCredentials(certPath, keyPath))
-	}
-	if caPath := strings.TrimSpace(os.Getenv("BUILDKIT_TLS_CA")); caPath != "" {
-		serverName := strings.TrimSpace(os.Getenv("BUILDKIT_TLS_SERVER_NAME"))
-		opts = append(opts, client.WithServerConfig(serverName, caPath))
-	}
-	bkClient, err := client.New(ctx, buildkitAddr, opts...)
-	if err != nil {
-		return "", "", "", fmt.Errorf("connect to buildkit: %w", err)
-	}
-	defer bkClient.Close()
-
-	statusCh := make(chan *client.SolveStatus)
-	var buildLogs bytes.Buffer
-	done := make(chan struct{})
-	go func() {
-		defer close(done)
-		vertexNames := map[string]string{}
-		for status := range statusCh {
-			for _, vertex := range status.Vertexes {
-				if vertex.Name != "" {
-					vertexNames[vertex.Digest.String()] = vertex.Name
-				}
-			}
-			for _, entry := range status.Logs {
-				if len(entry.Data) == 0 {
-					continue
-				}
-				vertexName := vertexNames[entry.Vertex.String()]
-				if vertexName == "" {
-					vertexName = entry.Vertex.String()
-				}
-				line := strings.TrimRight(string(entry.Data), "\n")
-				if line == "" {
-					continue
-				}
-				fmt.Fprintf(&buildLogs, "[%s][stream:%d] %s\n", vertexName, entry.Stream, line)
-				log.Printf("buildkit: [%s][stream:%d] %s", vertexName, entry.Stream, line)
-			}
-		}
-	}()
-	closeStatusCh := func() {
-		defer func() {
-			if recover() != nil {
-				// BuildKit may close the channel internally on error.
-			}
-		}()
-		close(statusCh)
-	}
-
-	solveOpt := client.SolveOpt{
-		Frontend: "dockerfile.v0",
-		FrontendAttrs: map[string]string{
-			"filename": "Dockerfile",
-		},
-		LocalDirs: map[string]string{
-			"context":    tempDir,
-			"dockerfile": tempDir,
-		},
-		Exports: []client.ExportEntry{
-			{
-				Type: client.ExporterImage,
-				Attrs: map[string]string{
-					"name": imageRef,
-					"push": "true",
-				},
-			},
-		},
-	}
-	if strings.EqualFold(strings.TrimSpace(os.Getenv("MCP_IMAGE_REGISTRY_INSECURE")), "true") {
-		solveOpt.Exports[0].Attrs["registry.insecure"] = "true"
-		solveOpt.Exports[0].Attrs["registry.plainhttp"] = "true"
-	}
-	if _, err := bkClient.Solve(ctx, nil, solveOpt, statusCh); err != nil {
-		closeStatusCh()
-		<-done
-		return "", buildLogs.String(), "", fmt.Errorf("buildkit build failed: %w", err)
-	}
-	closeStatusCh()
-	<-done
-
-	return imageRef, buildLogs.String(), "", nil
+type ShutdownInput struct {
+	ContainerID string `json:"container_id" jsonschema:"container ID or name to shut down"`
+	DeleteImage bool   `json:"delete_image,omitempty" jsonschema:"also delete the pushed image from the registry"`
+	ImageName   string `json:"image_name,omitempty" jsonschema:"judge image in the configured registry to delete; defaults to the pod's image"`
 }
 
-func extractTarToDir(r io.Reader, dest string) error {
-	tr := tar.NewReader(r)
-	for {
-		header, err := tr.Next()
-		if err == io.EOF {
-			return nil
-		}
-		if err != nil {
-			return err
-		}
-		cleanName := filepath.Clean(header.Name)
-		if filepath.IsAbs(cleanName) || strings.HasPrefix(cleanName, "..") {
-			return fmt.Errorf("invalid tar entry: %s", header.Name)
-		}
-		targetPath := filepath.Join(dest, cleanName)
-		switch header.Typeflag {
-		case tar.TypeDir:
-			if err := os.MkdirAll(targetPath, os.FileMode(header.Mode)); err != nil {
-				return err
-			}
-		case tar.TypeReg, tar.TypeRegA:
-			if err := os.MkdirAll(filepath.Dir(targetPath), 0o755); err != nil {
-				return err
-			}
-			file, err := os.OpenFile(targetPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(header.Mode))
-			if err != nil {
-				return err
-			}
-			if _, err := io.Copy(file, tr); err != nil {
-				file.Close()
-				return err
-			}
-			if err := file.Close(); err != nil {
-				return err
-			}
-		}
-	}
+type ShutdownOutput struct {
+	Message        string `json:"message" jsonschema:"shutdown result message"`
+	AlreadyDeleted bool   `json:"already_deleted" jsonschema:"true when the pod no longer existed"`
+	ImageDeleted   bool   `json:"image_deleted" jsonschema:"true when the image manifest was removed from the registry"`
 }
 
-func createKubernetesPod(ctx context.Context, podName, imageName, dockerfile string) (string, error) {
-	namespace, err := resolveKubernetesNamespace()
-	if err != nil {
-		return "", err
+func ShutdownContainer(ctx context.Context, req *mcp.CallToolRequest, input ShutdownInput) (*mcp.CallToolResult, ShutdownOutput, error) {
+	podName := strings.TrimSpace(input.ContainerID)
+	if podName == "" {
+		return nil, ShutdownOutput{}, fmt.Errorf("container_id is required")
 	}
-
-	config, err := rest.InClusterConfig()
+	sandbox, err := currentRuntime()
 	if err != nil {
-		return "", fmt.Errorf("create in-cluster config: %w", err)
+		return nil, ShutdownOutput{}, err
 	}
-	clientset, err := kubernetes.NewForConfig(config)
-	if err != nil {
-		return "", fmt.Errorf("create kubernetes client: %w", err)
-	}
-
-	pod := &corev1.Pod{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      podName,
-			Namespace: namespace,
-			Annotations: map[string]string{
-				"mcp.dockerfile": dockerfile,
-			},
-		},
-		Spec: corev1.PodSpec{
-			RestartPolicy: corev1.RestartPolicyNever,
-			Containers: []corev1.Container{
-				{
-					Name:            "mcp",
-					Image:           imageName,
-					ImagePullPolicy: corev1.PullIfNotPresent,
-				},
-			},
-		},
-	}
-
-	result, err := clientset.CoreV1().Pods(namespace).Create(ctx, pod, metav1.CreateOptions{})
-	if err != nil {
-		return "", fmt.Errorf("create pod: %w", err)
+
+	// Only sandboxes the judge started may be shut down; others are
+	// reported as not found, as ContainerLogs does.
+	info, err := sandbox.Inspect(ctx, podName)
+	missing := errors.Is(err, ErrContainerNotFound)
+	if err != nil && !missing {
+		return nil, ShutdownOutput{}, err
 	}
-	if result.UID == "" {
-		return "", fmt.Errorf("pod created but UID missing in response")
+	if !missing && info.Labels[ManagedByLabel] != ManagedByValue {
+		return nil, ShutdownOutput{}, fmt.Errorf("%w: %s", ErrContainerNotFound, podName)
 	}
-	return string(result.UID), nil
-}
 
-func resolveKubernetesNamespace() (string, error) {
-	if namespace := strings.TrimSpace(os.Getenv("MCP_K8S_NAMESPACE")); namespace != "" {
-		return namespace, nil
-	}
-	namespacePath := "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
-	if contents, err := os.ReadFile(namespacePath); err == nil {
-		if namespace := strings.TrimSpace(string(contents)); namespace != "" {
-			return namespace, nil
+	imageRef := strings.TrimSpace(input.ImageName)
+	imageNote := ""
+	if input.DeleteImage && imageRef != "" {
+		if err := checkJudgeImage(imageRef); err != nil {
+			return nil, ShutdownOutput{}, err
+		}
+	} else if input.DeleteImage {
+		imageRef = info.Image
+		if imageRef != "" && checkJudgeImage(imageRef) != nil {
+			imageRef, imageNote = "", "; image not in the judge registry"
 		}
 	}
-	return "default", nil
-}
-
-type ShutdownInput struct {
-	ContainerID string `json:"container_id" jsonschema:"container ID or name to shut down"`
-}
-
-type ShutdownOutput struct {
-	Message string `json:"message" jsonschema:"shutdown result message"`
-}
 
-func ShutdownContainer(ctx context.Context, req *mcp.CallToolRequest, input ShutdownInput) (*mcp.CallToolResult, ShutdownOutput, error) {
-	ctx = context.Background()
-	config, err := rest.InClusterConfig()
-	if err != nil {
-		panic(err)
-	}
-	clientset, err := kubernetes.NewForConfig(config)
-	if err != nil {
-		panic(err)
-	}
-	namespace, err := resolveKubernetesNamespace()
-	if err != nil {
-		panic(err)
+	output := ShutdownOutput{Message: "pod deleted"}
+	if missing {
+		output.Message = "pod already deleted"
+		output.AlreadyDeleted = true
+	} else if err := sandbox.Stop(ctx, podName); err != nil {
+		if !errors.Is(err, ErrContainerNotFound) {
+			return nil, ShutdownOutput{}, err
+		}
+		output.Message = "pod already deleted"
+		output.AlreadyDeleted = true
 	}
-	if err := clientset.CoreV1().Pods(namespace).Delete(ctx, input.ContainerID, metav1.DeleteOptions{}); err != nil {
-		panic(err)
+	output.Message += imageNote
+
+	if input.DeleteImage && imageRef != "" && defaultBuildCache.holds(imageRef) {
+		output.Message += "; image retained by build cache"
+	} else if input.DeleteImage && imageRef != "" {
+		deleted, err := deleteRegistryImage(ctx, imageRef)
+		if err != nil {
+			return nil, output, fmt.Errorf("delete image %s: %w", imageRef, err)
+		}
+		output.ImageDeleted = deleted
 	}
 
-	return nil, ShutdownOutput{
-		Message: "pod deleted",
-	}, nil
+	return nil, output, nil
 }
@@ -0,0 +1,295 @@
+package mcptransport
+
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"main/judge-agent/judging"
+	"main/judge-agent/problems"
+	"main/judge-agent/profiles"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestShutdownContainerOnlyDeletesJudgeSandboxes(t *testing.T) {
+	ctx := context.Background()
+	runtime := NewFakeRuntime(&corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "kube-dns", Namespace: "default"},
+	})
+	UseRuntime(runtime)
+	defer UseRuntime(nil)
+	if _, err := runtime.Start(ctx, ContainerSpec{Name: "mcp-pod-1", Image: "registry:5000/mcp-image-1:latest"}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err := ShutdownContainer(ctx, nil, ShutdownInput{ContainerID: "kube-dns"})
+	if !errors.Is(err, ErrContainerNotFound) {
+		t.Fatalf("unmanaged pod: err = %v, want ErrContainerNotFound", err)
+	}
+	if _, err := runtime.Inspect(ctx, "kube-dns"); err != nil {
+		t.Fatalf("unmanaged pod was deleted: %v", err)
+	}
+
+	_, output, err := ShutdownContainer(ctx, nil, ShutdownInput{ContainerID: "mcp-pod-1"})
+	if err != nil || output.AlreadyDeleted {
+		t.Fatalf("managed pod: output = %+v, err = %v", output, err)
+	}
+	_, output, err = ShutdownContainer(ctx, nil, ShutdownInput{ContainerID: "mcp-pod-1"})
+	if err != nil || !output.AlreadyDeleted {
+		t.Fatalf("deleted pod: output = %+v, err = %v", output, err)
+	}
+}
+
+func TestCheckJudgeImage(t *testing.T) {
+	t.Setenv("MCP_IMAGE_REGISTRY", "registry:5000/")
+	tests := []struct {
+		imageRef string
+		allowed  bool
+	}{
+		{"registry:5000/mcp-image-0f3c:latest", true},
+		{"REGISTRY:5000/mcp-image-0f3c", true},
+		{"registry:5000/mcp-image-0f3c@sha256:" + "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", true},
+		{"evil.example:80/mcp-image-0f3c:latest", false},
+		{"169.254.169.254/mcp-image-0f3c:latest", false},
+		{"registry:5000/library/postgres:16", false},
+		{"registry:5000/mcp-image-x/../../v2/_catalog", false},
+		{"registry:5000/mcp-image-x:latest?next=1", false},
+		{"mcp-image-0f3c:latest", false},
+	}
+	for _, tt := range tests {
+		err := checkJudgeImage(tt.imageRef)
+		if (err == nil) != tt.allowed {
+			t.Errorf("checkJudgeImage(%q) = %v, want allowed %v", tt.imageRef, err, tt.allowed)
+		}
+		if err != nil && !errors.Is(err, ErrImageNotAllowed) {
+			t.Errorf("checkJudgeImage(%q) = %v, want ErrImageNotAllowed", tt.imageRef, err)
+		}
+	}
+
+	t.Setenv("MCP_IMAGE_REGISTRY", "")
+	if err := checkJudgeImage("registry:5000/mcp-image-0f3c:latest"); !errors.Is(err, ErrImageNotAllowed) {
+		t.Errorf("no registry configured: err = %v, want ErrImageNotAllowed", err)
+	}
+}
+
+func TestProblemProfile(t *testing.T) {
+	problem := &problems.Package{Manifest: problems.Manifest{ID: "sum", Profile: "cpp"}}
+	tests := []struct {
+		dockerFile string
+		profile    string
+		want       string
+		rejected   bool
+	}{
+		{"", "", "cpp", false},
+		{"", "cpp", "cpp", false},
+		{"", "C++", "cpp", false},
+		{"", "python", "", true},
+		{"FROM gcc:13\nRUN cat hidden/*\n", "", "", true},
+		{"FROM gcc:13\n", "cpp", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ProblemProfile(problem, tt.dockerFile, tt.profile)
+		if tt.rejected {
+			if !errors.Is(err, ErrProblemBuildSpec) {
+				t.Errorf("ProblemProfile(%q, %q) = %q, %v, want ErrProblemBuildSpec", tt.dockerFile, tt.profile, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ProblemProfile(%q, %q) = %q, %v, want %q", tt.dockerFile, tt.profile, got, err, tt.want)
+		}
+	}
+}
+
+func TestBuildImageKeepsHiddenFilesFromTheSubmission(t *testing.T) {
+	builder := &FakeBuilder{}
+	UseBuilder(builder)
+	defer UseBuilder(nil)
+
+	var submission bytes.Buffer
+	tw := tar.NewWriter(&submission)
+	for name, contents := range map[string]string{
+		"src/App.js":         "export default 1",
+		"hidden/App.test.js": "learner copy",
+		"package.json":       "{}",
+		"package-lock.json":  "{}",
+	} {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0o644, Size: int64(len(contents))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(contents)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	protected, err := underDir(submissionDir, map[string][]byte{"package.json": []byte(`{"scripts":{}}`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hidden, err := underDir(hiddenDir, map[string][]byte{"src/App.test.js": []byte("secret")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	overrides, err := newContextOverrides(protected, hidden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	react, err := profiles.Lookup("react")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dockerfile := react.HiddenDockerfile(submissionDir, hiddenDir)
+	layout := contextLayout{dir: submissionDir, overrides: overrides, dropped: react.DependencyFiles}
+	if _, err := buildImage(context.Background(), dockerfile, submission.Bytes(), layout); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := builder.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("got %d builds, want 1", len(requests))
+	}
+	files := map[string]string{}
+	tr := tar.NewReader(requests[0].Context)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[header.Name] = string(contents)
+	}
+	want := map[string]string{
+		"submission/":                   "",
+		"submission/src/App.js":         "export default 1",
+		"submission/hidden/App.test.js": "learner copy",
+		"submission/package.json":       `{"scripts":{}}`,
+		"hidden/":                       "",
+		"hidden/src/":                   "",
+		"hidden/src/App.test.js":        "secret",
+		"Dockerfile":                    dockerfile,
+	}
+	for name, contents := range want {
+		if got, ok := files[name]; !ok || got != contents {
+			t.Errorf("context %s = %q (present %v), want %q", name, got, ok, contents)
+		}
+	}
+	if len(files) != len(want) {
+		t.Errorf("context has %d entries, want %d: %v", len(files), len(want), files)
+	}
+}
+
+func TestDeployContainerRefusesDockerfilesByDefault(t *testing.T) {
+	builder := &FakeBuilder{}
+	UseBuilder(builder)
+	defer UseBuilder(nil)
+	t.Setenv("MCP_ALLOW_DOCKERFILE", "")
+
+	tests := []struct {
+		name  string
+		input Input
+	}{
+		{"submission", Input{DockerFile: "FROM python:3.12-slim\nCMD [\"python\", \"main.py\"]\n"}},
+		{"checker", Input{
+			Profile: "python",
+			Tests:   []judging.TestCase{{Input: "1 2\n", ExpectedOutput: "3\n"}},
+			Checker: judging.CheckerSpec{Type: judging.CheckerProgram, Program: &judging.CheckerSource{
+				DockerFile: "FROM python:3.12-slim\nCMD [\"python\", \"check.py\"]\n",
+				Files:      map[string]string{"check.py": "import sys"},
+			}},
+		}},
+	}
+	for _, tt := range tests {
+		if _, _, err := DeployContainer(context.Background(), nil, tt.input); !errors.Is(err, ErrDockerfileNotAllowed) {
+			t.Errorf("%s: DeployContainer() error = %v, want ErrDockerfileNotAllowed", tt.name, err)
+		}
+	}
+	if requests := builder.Requests(); len(requests) != 0 {
+		t.Errorf("refused deploys started %d builds", len(requests))
+	}
+}
+
+func TestCheckClientChecker(t *testing.T) {
+	dockerfileChecker := judging.CheckerSpec{Type: judging.CheckerProgram, Program: &judging.CheckerSource{
+		DockerFile: "FROM gcc:13\n",
+		Files:      map[string]string{"check.cpp": "int main() {}"},
+	}}
+	profileChecker := judging.CheckerSpec{Type: judging.CheckerProgram, Program: &judging.CheckerSource{
+		Profile: "cpp",
+		Files:   map[string]string{"check.cpp": "int main() {}"},
+	}}
+
+	t.Setenv("MCP_ALLOW_DOCKERFILE", "")
+	if err := CheckClientChecker(dockerfileChecker); !errors.Is(err, ErrDockerfileNotAllowed) {
+		t.Errorf("Dockerfile checker: CheckClientChecker() = %v, want ErrDockerfileNotAllowed", err)
+	}
+	for _, spec := range []judging.CheckerSpec{profileChecker, {Type: judging.CheckerTokens}} {
+		if err := CheckClientChecker(spec); err != nil {
+			t.Errorf("%s checker: CheckClientChecker() = %v, want nil", spec.Type, err)
+		}
+	}
+
+	t.Setenv("MCP_ALLOW_DOCKERFILE", "true")
+	if err := CheckClientChecker(dockerfileChecker); err != nil {
+		t.Errorf("Dockerfiles allowed: CheckClientChecker() = %v, want nil", err)
+	}
+}
+
+func TestDeployContainerDoesNotReportJudgedSandbox(t *testing.T) {
+	UseBuilder(&FakeBuilder{})
+	defer UseBuilder(nil)
+	runtime := NewFakeRuntime()
+	runtime.ExecFunc = func(context.Context, string, []string, io.Reader) (ExecResult, error) {
+		return ExecResult{Stdout: "3\n", Stderr: "\n" + metricsMarker + " exit=0 out_bytes=2\n"}, nil
+	}
+	UseRuntime(runtime)
+	defer UseRuntime(nil)
+
+	input := Input{
+		Profile: "python",
+		Tests:   []judging.TestCase{{Input: "1 2\n", ExpectedOutput: "3\n"}},
+	}
+	_, output, err := DeployContainer(context.Background(), nil, input)
+	if err != nil {
+		t.Fatalf("DeployContainer() error = %v", err)
+	}
+	if output.TestReport == nil {
+		t.Fatalf("DeployContainer() returned no test report")
+	}
+	if output.ContainerName != "" || output.ContainerID != "" {
+		t.Errorf("judged deploy reported removed container %q (%q)", output.ContainerName, output.ContainerID)
+	}
+	pods, err := runtime.Clientset.CoreV1().Pods("default").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pods.Items) != 0 {
+		t.Errorf("%d sandboxes left running after judging", len(pods.Items))
+	}
+}
@@ -0,0 +1,101 @@
+package mcptransport
+
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+)
+
+// exposedPort returns the first TCP port EXPOSEd by the final stage of
+// dockerfile, or 0 when it exposes none. Ports given through build
+// arguments cannot be resolved here and are skipped.
+func exposedPort(dockerfile string) (int, error) {
+	result, err := parser.Parse(strings.NewReader(dockerfile))
+	if err != nil {
+		return 0, fmt.Errorf("parse Dockerfile: %w", err)
+	}
+	port := 0
+	for _, node := range result.AST.Children {
+		switch strings.ToLower(node.Value) {
+		case "from":
+			port = 0
+		case "expose":
+			if port != 0 {
+				continue
+			}
+			for arg := node.Next; arg != nil; arg = arg.Next {
+				spec, protocol, _ := strings.Cut(arg.Value, "/")
+				if protocol != "" && !strings.EqualFold(protocol, "tcp") {
+					continue
+				}
+				// A range such as 8000-8010 exposes its first port.
+				first, _, _ := strings.Cut(spec, "-")
+				if value, err := strconv.Atoi(first); err == nil && value > 0 && value <= 65535 {
+					port = value
+					break
+				}
+			}
+		}
+	}
+	return port, nil
+}
+
+// programCommand returns the command the final stage of dockerfile runs,
+// its ENTRYPOINT followed by its CMD, with shell forms wrapped in
+// /bin/sh -c as Docker does. Instructions inherited from the base image
+// cannot be seen here, so a final stage that sets neither is an error.
+func programCommand(dockerfile string) ([]string, error) {
+	result, err := parser.Parse(strings.NewReader(dockerfile))
+	if err != nil {
+		return nil, fmt.Errorf("parse Dockerfile: %w", err)
+	}
+	var entrypoint, cmd []string
+	var entrypointShell bool
+	for _, node := range result.AST.Children {
+		switch strings.ToLower(node.Value) {
+		case "from":
+			entrypoint, cmd, entrypointShell = nil, nil, false
+		case "entrypoint":
+			entrypoint = instructionArgs(node)
+			entrypointShell = !node.Attributes["json"]
+		case "cmd":
+			cmd = instructionArgs(node)
+		}
+	}
+	if entrypointShell {
+		// A shell-form ENTRYPOINT ignores CMD.
+		return entrypoint, nil
+	}
+	command := append(entrypoint, cmd...)
+	if len(command) == 0 {
+		return nil, fmt.Errorf("the final stage sets neither ENTRYPOINT nor CMD")
+	}
+	return command, nil
+}
+
+func instructionArgs(node *parser.Node) []string {
+	var args []string
+	for arg := node.Next; arg != nil; arg = arg.Next {
+		args = append(args, arg.Value)
+	}
+	if !node.Attributes["json"] && len(args) > 0 {
+		return []string{"/bin/sh", "-c", strings.Join(args, " ")}
+	}
+	return args
+}
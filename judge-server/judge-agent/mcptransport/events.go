@@ -0,0 +1,55 @@
+package mcptransport
+
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import (
+	"context"
+	"time"
+)
+
+// BuildEventType identifies what a BuildEvent describes.
+type BuildEventType string
+
+const (
+	BuildEventVertexStarted   BuildEventType = "vertex_started"
+	BuildEventVertexCompleted BuildEventType = "vertex_completed"
+	BuildEventLog             BuildEventType = "log"
+)
+
+// BuildEvent is a single progress update emitted while an image builds.
+type BuildEvent struct {
+	Type   BuildEventType `json:"type"`
+	Vertex string         `json:"vertex"`
+	Name   string         `json:"name,omitempty"`
+	Stream int            `json:"stream,omitempty"`
+	Data   string         `json:"data,omitempty"`
+	Cached bool           `json:"cached,omitempty"`
+	Error  string         `json:"error,omitempty"`
+	Time   time.Time      `json:"time"`
+}
+
+type buildEventSinkKey struct{}
+
+// WithBuildEventSink returns a context that makes DeployContainer pass every
+// build progress update to sink as it happens.
+func WithBuildEventSink(ctx context.Context, sink func(BuildEvent)) context.Context {
+	return context.WithValue(ctx, buildEventSinkKey{}, sink)
+}
+
+func emitBuildEvent(ctx context.Context, event BuildEvent) {
+	if sink, ok := ctx.Value(buildEventSinkKey{}).(func(BuildEvent)); ok && sink != nil {
+		sink(event)
+	}
+}
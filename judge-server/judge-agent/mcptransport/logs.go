@@ -0,0 +1,146 @@
+package mcptransport
+
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	defaultLogMaxBytes = 64 << 10
+	logCollectTimeout  = 10 * time.Second
+)
+
+// ContainerLogs streams the output of a judge-managed container. Containers
+// the judge did not start are reported as not found.
+func ContainerLogs(ctx context.Context, name string, opts LogOptions) (io.ReadCloser, error) {
+	sandbox, err := currentRuntime()
+	if err != nil {
+		return nil, err
+	}
+	info, err := sandbox.Inspect(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if info.Labels[ManagedByLabel] != ManagedByValue {
+		return nil, ErrContainerNotFound
+	}
+	return sandbox.Logs(ctx, name, opts)
+}
+
+// runtimeLogs is what a sandbox printed, capped to MCP_LOG_MAX_BYTES per
+// stream.
+type runtimeLogs struct {
+	stdout    string
+	stderr    string
+	truncated bool
+}
+
+// collectLogs reads everything the container has written so far. Runtimes
+// that cannot separate the streams return the interleaved log as stdout.
+func collectLogs(ctx context.Context, sandbox Runtime, name string) (runtimeLogs, error) {
+	ctx, cancel := context.WithTimeout(ctx, logCollectTimeout)
+	defer cancel()
+	maxBytes := int(envInt64("MCP_LOG_MAX_BYTES", defaultLogMaxBytes))
+
+	stdout, stdoutTruncated, err := readCappedLog(ctx, sandbox, name, LogStreamStdout, maxBytes)
+	if errors.Is(err, ErrLogStreamsCombined) {
+		combined, truncated, err := readCappedLog(ctx, sandbox, name, "", maxBytes)
+		return runtimeLogs{stdout: combined, truncated: truncated}, err
+	}
+	if err != nil {
+		return runtimeLogs{}, err
+	}
+	stderr, stderrTruncated, err := readCappedLog(ctx, sandbox, name, LogStreamStderr, maxBytes)
+	return runtimeLogs{
+		stdout:    stdout,
+		stderr:    stderr,
+		truncated: stdoutTruncated || stderrTruncated,
+	}, err
+}
+
+func readCappedLog(ctx context.Context, sandbox Runtime, name, stream string, maxBytes int) (string, bool, error) {
+	reader, err := sandbox.Logs(ctx, name, LogOptions{Stream: stream})
+	if err != nil {
+		return "", false, err
+	}
+	defer reader.Close()
+	capped := newCappedBuffer(maxBytes)
+	if _, err := io.Copy(capped, reader); err != nil {
+		return "", false, fmt.Errorf("read logs for %s: %w", name, err)
+	}
+	return capped.String(), capped.Truncated(), nil
+}
+
+// cappedBuffer keeps the first and last halves of at most max bytes of
+// output, so both the startup banner and the final error survive a chatty
+// program, without ever holding more than max bytes.
+type cappedBuffer struct {
+	half  int
+	head  []byte
+	tail  []byte
+	total int64
+}
+
+func newCappedBuffer(max int) *cappedBuffer {
+	return &cappedBuffer{half: max / 2}
+}
+
+func (c *cappedBuffer) Write(p []byte) (int, error) {
+	c.total += int64(len(p))
+	rest := p
+	if room := c.half - len(c.head); room > 0 {
+		n := min(room, len(rest))
+		c.head = append(c.head, rest[:n]...)
+		rest = rest[n:]
+	}
+	if len(rest) > 0 {
+		c.tail = append(c.tail, rest...)
+		if len(c.tail) > 2*c.half {
+			c.tail = append(c.tail[:0], c.tail[len(c.tail)-c.half:]...)
+		}
+	}
+	return len(p), nil
+}
+
+func (c *cappedBuffer) Truncated() bool {
+	return c.total > int64(len(c.head)+len(c.tail)) || len(c.tail) > c.half
+}
+
+// String joins head and tail with a marker counting the dropped bytes,
+// cutting only on UTF-8 boundaries.
+func (c *cappedBuffer) String() string {
+	if !c.Truncated() {
+		return string(c.head) + string(c.tail)
+	}
+	head := c.head
+	for len(head) > 0 && !utf8.Valid(head) && len(c.head)-len(head) < utf8.UTFMax {
+		head = head[:len(head)-1]
+	}
+	tail := c.tail
+	if len(tail) > c.half {
+		tail = tail[len(tail)-c.half:]
+	}
+	for len(tail) > 0 && !utf8.RuneStart(tail[0]) {
+		tail = tail[1:]
+	}
+	dropped := c.total - int64(len(head)+len(tail))
+	return fmt.Sprintf("%s\n... [%d bytes truncated] ...\n%s", head, dropped, tail)
+}
@@ -0,0 +1,234 @@
+package mcptransport
+
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var reaperNow = time.Unix(1700000000, 0)
+
+// judgePod is a managed sandbox pod as the API server would return it.
+func judgePod(name, image string, age time.Duration, phase corev1.PodPhase) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         "default",
+			Labels:            managedLabels(name, nil),
+			CreationTimestamp: metav1.NewTime(reaperNow.Add(-age)),
+		},
+		Spec:   corev1.PodSpec{Containers: []corev1.Container{{Name: sandboxContainerName, Image: image}}},
+		Status: corev1.PodStatus{Phase: phase},
+	}
+}
+
+func remainingPods(t *testing.T, runtime *FakeRuntime) []string {
+	t.Helper()
+	infos, err := runtime.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, info := range infos {
+		names = append(names, info.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestReaperDeletesExpiredSandboxes(t *testing.T) {
+	runtime := NewFakeRuntime(
+		judgePod("mcp-pod-fresh", "", 10*time.Minute, corev1.PodRunning),
+		judgePod("mcp-pod-old", "", 2*time.Hour, corev1.PodRunning),
+		judgePod("mcp-pod-just-finished", "", time.Minute, corev1.PodSucceeded),
+		judgePod("mcp-pod-finished", "", 10*time.Minute, corev1.PodFailed),
+		judgePod("mcp-pod-pending", "", 30*time.Minute, corev1.PodPending),
+	)
+	UseRuntime(runtime)
+	defer UseRuntime(nil)
+
+	reaper := NewReaper(ReaperConfig{PodTTL: time.Hour, TerminalGrace: 5 * time.Minute})
+	reaper.now = func() time.Time { return reaperNow }
+	if err := reaper.Sweep(context.Background()); err != nil {
+		t.Fatalf("Sweep() error = %v", err)
+	}
+
+	want := []string{"mcp-pod-fresh", "mcp-pod-just-finished", "mcp-pod-pending"}
+	if got := remainingPods(t, runtime); !slices.Equal(got, want) {
+		t.Errorf("remaining sandboxes = %v, want %v", got, want)
+	}
+	if stats := reaper.Stats(); stats.Sweeps != 1 || stats.PodsDeleted != 2 || stats.Errors != 0 {
+		t.Errorf("Stats() = %+v, want one sweep deleting two sandboxes", stats)
+	}
+}
+
+// fakeRegistry serves the parts of the registry v2 API the reaper uses.
+type fakeRegistry struct {
+	mu      sync.Mutex
+	tags    map[string][]string
+	deleted []string
+}
+
+func manifestDigest(repository, tag string) string {
+	sum := sha256.Sum256([]byte(repository + ":" + tag))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func (f *fakeRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.URL.Path == "/v2/_catalog" {
+		var repositories []string
+		for repository := range f.tags {
+			repositories = append(repositories, repository)
+		}
+		sort.Strings(repositories)
+		json.NewEncoder(w).Encode(map[string][]string{"repositories": repositories})
+		return
+	}
+	rest := strings.TrimPrefix(r.URL.Path, "/v2/")
+	if repository, ok := strings.CutSuffix(rest, "/tags/list"); ok {
+		json.NewEncoder(w).Encode(map[string][]string{"tags": f.tags[repository]})
+		return
+	}
+	repository, reference, ok := strings.Cut(rest, "/manifests/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	for i, tag := range f.tags[repository] {
+		switch {
+		case r.Method == http.MethodHead && reference == tag:
+			w.Header().Set("Docker-Content-Digest", manifestDigest(repository, tag))
+			return
+		case r.Method == http.MethodDelete && reference == manifestDigest(repository, tag):
+			f.tags[repository] = slices.Delete(f.tags[repository], i, i+1)
+			f.deleted = append(f.deleted, repository+":"+tag)
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+	}
+	http.NotFound(w, r)
+}
+
+func (f *fakeRegistry) takeDeleted() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	deleted := f.deleted
+	f.deleted = nil
+	sort.Strings(deleted)
+	return deleted
+}
+
+func TestReaperCollectsImages(t *testing.T) {
+	registry := &fakeRegistry{tags: map[string][]string{
+		"mcp-image-running":  {"latest"},
+		"mcp-image-orphan":   {"latest"},
+		"mcp-image-cached":   {"latest"},
+		"mcp-image-released": {"latest"},
+		"base-python":        {"3.12"},
+	}}
+	server := httptest.NewServer(registry)
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+	t.Setenv("MCP_IMAGE_REGISTRY", host)
+	t.Setenv("MCP_IMAGE_REGISTRY_INSECURE", "true")
+
+	cache := newBuildCache(time.Hour, 10)
+	cache.put("cached", host+"/mcp-image-cached:latest", "")
+	previousCache := defaultBuildCache
+	defaultBuildCache = cache
+	defer func() { defaultBuildCache = previousCache }()
+
+	runtime := NewFakeRuntime(
+		judgePod("mcp-pod-running", host+"/mcp-image-running:latest", time.Minute, corev1.PodRunning),
+		judgePod("mcp-pod-finished", host+"/mcp-image-released:latest", time.Hour, corev1.PodSucceeded),
+	)
+	UseRuntime(runtime)
+	defer UseRuntime(nil)
+
+	reaper := NewReaper(ReaperConfig{PodTTL: 2 * time.Hour, TerminalGrace: 5 * time.Minute, Registry: host})
+	reaper.now = func() time.Time { return reaperNow }
+	sweeps := []struct {
+		name    string
+		deleted []string
+	}{
+		// The finished sandbox's image goes with it; the orphan is only
+		// suspected, since it may belong to a sandbox about to start.
+		{"first sweep", []string{"mcp-image-released:latest"}},
+		{"second sweep", []string{"mcp-image-orphan:latest"}},
+		{"third sweep", nil},
+	}
+	for _, sweep := range sweeps {
+		if err := reaper.Sweep(context.Background()); err != nil {
+			t.Fatalf("%s: Sweep() error = %v", sweep.name, err)
+		}
+		if got := registry.takeDeleted(); !slices.Equal(got, sweep.deleted) {
+			t.Errorf("%s: deleted images = %v, want %v", sweep.name, got, sweep.deleted)
+		}
+	}
+
+	if stats := reaper.Stats(); stats.PodsDeleted != 1 || stats.ImagesDeleted != 2 || stats.Errors != 0 {
+		t.Errorf("Stats() = %+v, want one sandbox and two images deleted", stats)
+	}
+	for _, repository := range []string{"mcp-image-running", "mcp-image-cached", "base-python"} {
+		if len(registry.tags[repository]) == 0 {
+			t.Errorf("%s was deleted, want it kept", repository)
+		}
+	}
+}
+
+func TestReaperSuspectsOnlyImagesUnreferencedTwice(t *testing.T) {
+	registry := &fakeRegistry{tags: map[string][]string{"mcp-image-late": {"latest"}}}
+	server := httptest.NewServer(registry)
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+	t.Setenv("MCP_IMAGE_REGISTRY", host)
+	t.Setenv("MCP_IMAGE_REGISTRY_INSECURE", "true")
+
+	runtime := NewFakeRuntime()
+	UseRuntime(runtime)
+	defer UseRuntime(nil)
+	reaper := NewReaper(ReaperConfig{PodTTL: time.Hour, Registry: host})
+	reaper.now = func() time.Time { return reaperNow }
+
+	if err := reaper.Sweep(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// The image's sandbox starts between sweeps, clearing the suspicion.
+	if _, err := runtime.Start(context.Background(), ContainerSpec{Name: "mcp-pod-late", Image: host + "/mcp-image-late:latest"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := reaper.Sweep(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := registry.takeDeleted(); len(got) != 0 {
+		t.Errorf("deleted images = %v, want none while a sandbox uses them", got)
+	}
+}
@@ -0,0 +1,215 @@
+package mcptransport
+
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var manifestAcceptTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// ErrImageNotAllowed rejects image references other than the judge's own
+// images in MCP_IMAGE_REGISTRY.
+var ErrImageNotAllowed = errors.New("image is not a judge image")
+
+var (
+	judgeRepositoryPattern = regexp.MustCompile(`^[a-z0-9]+(?:[._-][a-z0-9]+)*$`)
+	imageReferencePattern  = regexp.MustCompile(`^(?:[A-Za-z0-9_][A-Za-z0-9_.-]{0,127}|sha256:[a-f0-9]{64})$`)
+)
+
+// checkJudgeImage accepts only images the judge pushed: hosted by
+// MCP_IMAGE_REGISTRY, in a repository named with the judge's image prefix.
+// Anything else could point registry requests at an arbitrary host.
+func checkJudgeImage(imageRef string) error {
+	registry := strings.TrimSuffix(strings.TrimSpace(os.Getenv("MCP_IMAGE_REGISTRY")), "/")
+	if registry == "" {
+		return fmt.Errorf("%w: no image registry is configured", ErrImageNotAllowed)
+	}
+	host, repository, reference, err := splitImageRef(imageRef)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrImageNotAllowed, err)
+	}
+	if !strings.EqualFold(host, registry) || !strings.HasPrefix(repository, imageNamePrefix) ||
+		!judgeRepositoryPattern.MatchString(repository) || !imageReferencePattern.MatchString(reference) {
+		return fmt.Errorf("%w: %s", ErrImageNotAllowed, imageRef)
+	}
+	return nil
+}
+
+// deleteRegistryImage removes the manifest behind imageRef through the
+// registry v2 API. It reports false without error when the manifest is
+// already gone. Only judge images, as checkJudgeImage defines them, are
+// deleted.
+func deleteRegistryImage(ctx context.Context, imageRef string) (bool, error) {
+	if err := checkJudgeImage(imageRef); err != nil {
+		return false, err
+	}
+	host, repository, reference, err := splitImageRef(imageRef)
+	if err != nil {
+		return false, err
+	}
+	manifestURL := fmt.Sprintf("%s://%s/v2/%s/manifests/", registryScheme(), host, repository)
+
+	digest := reference
+	if !strings.HasPrefix(reference, "sha256:") {
+		headReq, err := http.NewRequestWithContext(ctx, http.MethodHead, manifestURL+reference, nil)
+		if err != nil {
+			return false, fmt.Errorf("build manifest request: %w", err)
+		}
+		headReq.Header.Set("Accept", strings.Join(manifestAcceptTypes, ", "))
+		resp, err := http.DefaultClient.Do(headReq)
+		if err != nil {
+			return false, fmt.Errorf("resolve manifest %s: %w", imageRef, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return false, nil
+		}
+		if resp.StatusCode != http.StatusOK {
+			return false, fmt.Errorf("resolve manifest %s: registry returned %s", imageRef, resp.Status)
+		}
+		digest = resp.Header.Get("Docker-Content-Digest")
+		if digest == "" {
+			return false, fmt.Errorf("resolve manifest %s: registry did not return a digest", imageRef)
+		}
+	}
+
+	deleteReq, err := http.NewRequestWithContext(ctx, http.MethodDelete, manifestURL+digest, nil)
+	if err != nil {
+		return false, fmt.Errorf("build manifest delete request: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(deleteReq)
+	if err != nil {
+		return false, fmt.Errorf("delete manifest %s: %w", imageRef, err)
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusAccepted, http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("delete manifest %s: registry returned %s", imageRef, resp.Status)
+	}
+}
+
+// listRegistryImages returns every tagged image in the registry at host
+// whose repository starts with prefix, following catalog pagination.
+func listRegistryImages(ctx context.Context, host, prefix string) ([]string, error) {
+	baseURL := fmt.Sprintf("%s://%s", registryScheme(), host)
+	var repositories []string
+	next := "/v2/_catalog?n=1000"
+	for next != "" {
+		var page struct {
+			Repositories []string `json:"repositories"`
+		}
+		link, err := getRegistryJSON(ctx, baseURL+next, &page)
+		if err != nil {
+			return nil, fmt.Errorf("list registry catalog: %w", err)
+		}
+		for _, repository := range page.Repositories {
+			if strings.HasPrefix(repository, prefix) {
+				repositories = append(repositories, repository)
+			}
+		}
+		next = nextCatalogPage(link)
+	}
+
+	var images []string
+	for _, repository := range repositories {
+		var tags struct {
+			Tags []string `json:"tags"`
+		}
+		if _, err := getRegistryJSON(ctx, fmt.Sprintf("%s/v2/%s/tags/list", baseURL, repository), &tags); err != nil {
+			return nil, fmt.Errorf("list tags for %s: %w", repository, err)
+		}
+		// Repositories whose manifests were all deleted stay in the
+		// catalog with no tags.
+		for _, tag := range tags.Tags {
+			images = append(images, fmt.Sprintf("%s/%s:%s", host, repository, tag))
+		}
+	}
+	return images, nil
+}
+
+// getRegistryJSON decodes a registry API response into out and returns its
+// Link header. A 404 leaves out empty.
+func getRegistryJSON(ctx context.Context, url string, out any) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return "", nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("registry returned %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return "", fmt.Errorf("decode registry response: %w", err)
+	}
+	return resp.Header.Get("Link"), nil
+}
+
+// nextCatalogPage extracts the path from a Link header such as
+// `</v2/_catalog?last=b&n=1000>; rel="next"`.
+func nextCatalogPage(link string) string {
+	target, params, ok := strings.Cut(link, ";")
+	if !ok || !strings.Contains(params, `rel="next"`) {
+		return ""
+	}
+	return strings.Trim(strings.TrimSpace(target), "<>")
+}
+
+func registryScheme() string {
+	if strings.EqualFold(strings.TrimSpace(os.Getenv("MCP_IMAGE_REGISTRY_INSECURE")), "true") {
+		return "http"
+	}
+	return "https"
+}
+
+// splitImageRef breaks host/repository[:tag|@digest] into its parts,
+// defaulting the reference to "latest".
+func splitImageRef(imageRef string) (string, string, string, error) {
+	host, rest, ok := strings.Cut(strings.TrimSpace(imageRef), "/")
+	if !ok || host == "" || rest == "" {
+		return "", "", "", fmt.Errorf("invalid image reference %q", imageRef)
+	}
+	if repository, digest, ok := strings.Cut(rest, "@"); ok {
+		return host, repository, digest, nil
+	}
+	repository, reference := rest, "latest"
+	if idx := strings.LastIndex(rest, ":"); idx > strings.LastIndex(rest, "/") {
+		repository, reference = rest[:idx], rest[idx+1:]
+	}
+	return host, repository, reference, nil
+}
@@ -0,0 +1,183 @@
+package mcptransport
+
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"main/judge-agent/sandboxpolicy"
+)
+
+const (
+	// ManagedByLabel marks every sandbox the judge starts so it can find
+	// them again, including after a restart.
+	ManagedByLabel = "app.kubernetes.io/managed-by"
+	ManagedByValue = "promptly-judge"
+	// SandboxLabel carries the sandbox name so a Service can select it.
+	SandboxLabel = "mcp.sandbox"
+)
+
+// ErrContainerNotFound is returned when a runtime has no container with the
+// requested name.
+var ErrContainerNotFound = errors.New("container not found")
+
+// ContainerSpec describes a sandbox container to start.
+type ContainerSpec struct {
+	Name        string
+	Image       string
+	Labels      map[string]string
+	Annotations map[string]string
+	// Port is the port the program listens on, or 0 for programs that
+	// run to completion.
+	Port int
+	// Command replaces the image's entrypoint and command when set.
+	Command []string
+	// Sandbox is the hardening and resources to apply, already resolved
+	// against the submission's language profile.
+	Sandbox sandboxpolicy.Policy
+}
+
+// ContainerInfo is a runtime-neutral view of a sandbox container.
+type ContainerInfo struct {
+	Name    string
+	ID      string
+	Image   string
+	Phase   string
+	Ready   bool
+	Reason  string
+	Message string
+	// Terminated is set once the container has exited, and ExitCode is
+	// only meaningful then.
+	Terminated   bool
+	ExitCode     int
+	RestartCount int
+	Labels       map[string]string
+	CreatedAt    time.Time
+}
+
+// Log streams selectable through LogOptions.Stream.
+const (
+	LogStreamStdout = "stdout"
+	LogStreamStderr = "stderr"
+)
+
+// ErrLogStreamsCombined is returned by runtimes that cannot separate a
+// container's stdout from its stderr when LogOptions.Stream is set.
+var ErrLogStreamsCombined = errors.New("runtime only records combined output")
+
+// LogOptions selects which container output Logs returns.
+type LogOptions struct {
+	Follow     bool
+	TailLines  int64
+	LimitBytes int64
+	// Stream is LogStreamStdout or LogStreamStderr, or empty for both
+	// interleaved.
+	Stream string
+}
+
+// ExecResult is the outcome of a command run inside a container.
+type ExecResult struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+}
+
+// Runtime starts and manages sandbox containers.
+type Runtime interface {
+	Start(ctx context.Context, spec ContainerSpec) (ContainerInfo, error)
+	// WaitReady blocks until the container is running and ready or ctx is
+	// done. A container that fails to start or exits first yields a
+	// *StartupError.
+	WaitReady(ctx context.Context, name string) (ContainerInfo, error)
+	Inspect(ctx context.Context, name string) (ContainerInfo, error)
+	Logs(ctx context.Context, name string, opts LogOptions) (io.ReadCloser, error)
+	Exec(ctx context.Context, name string, command []string, stdin io.Reader) (ExecResult, error)
+	// Stop removes the container, returning ErrContainerNotFound when it
+	// is already gone.
+	Stop(ctx context.Context, name string) error
+	// List returns the containers labelled as managed by the judge.
+	List(ctx context.Context) ([]ContainerInfo, error)
+	// Expose makes port reachable from the judge and returns its
+	// host:port address.
+	Expose(ctx context.Context, name string, port int) (string, error)
+}
+
+// StartupError reports a container that never became ready. Reason is the
+// runtime's terminal or waiting reason, such as ImagePullBackOff,
+// OOMKilled, Error or Completed.
+type StartupError struct {
+	Name    string
+	Reason  string
+	Message string
+}
+
+func (e *StartupError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("container %s did not become ready: %s: %s", e.Name, e.Reason, e.Message)
+	}
+	return fmt.Sprintf("container %s did not become ready: %s", e.Name, e.Reason)
+}
+
+var (
+	runtimeMu     sync.Mutex
+	activeRuntime Runtime
+	runtimeErr    error
+)
+
+// NewRuntimeFromEnv picks the runtime named by MCP_RUNTIME: "kubernetes"
+// (the default), "docker" or "fake".
+func NewRuntimeFromEnv() (Runtime, error) {
+	switch kind := strings.ToLower(strings.TrimSpace(os.Getenv("MCP_RUNTIME"))); kind {
+	case "", "kubernetes":
+		return newKubernetesRuntimeFromEnv()
+	case "docker":
+		return newDockerRuntimeFromEnv()
+	case "fake":
+		return NewFakeRuntime(), nil
+	default:
+		return nil, fmt.Errorf("unknown MCP_RUNTIME %q", kind)
+	}
+}
+
+// UseRuntime replaces the runtime DeployContainer and ShutdownContainer use.
+func UseRuntime(r Runtime) {
+	runtimeMu.Lock()
+	defer runtimeMu.Unlock()
+	activeRuntime, runtimeErr = r, nil
+}
+
+func currentRuntime() (Runtime, error) {
+	runtimeMu.Lock()
+	defer runtimeMu.Unlock()
+	if activeRuntime == nil && runtimeErr == nil {
+		activeRuntime, runtimeErr = NewRuntimeFromEnv()
+	}
+	return activeRuntime, runtimeErr
+}
+
+func managedLabels(name string, extra map[string]string) map[string]string {
+	labels := map[string]string{ManagedByLabel: ManagedByValue, SandboxLabel: name}
+	for key, value := range extra {
+		labels[key] = value
+	}
+	return labels
+}
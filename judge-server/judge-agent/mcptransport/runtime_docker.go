@@ -0,0 +1,545 @@
+package mcptransport
+
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"main/judge-agent/sandboxpolicy"
+)
+
+// dockerRuntime runs sandboxes as containers on a local Docker Engine, so the
+// judge can run end to end on a laptop without a cluster.
+type dockerRuntime struct {
+	client  *http.Client
+	baseURL string
+}
+
+func newDockerRuntimeFromEnv() (*dockerRuntime, error) {
+	client, baseURL, err := newDockerEngineClient(os.Getenv("DOCKER_HOST"))
+	if err != nil {
+		return nil, err
+	}
+	return &dockerRuntime{client: client, baseURL: baseURL}, nil
+}
+
+// dockerContainer is the subset of the Engine API's container inspect
+// response the runtime reads.
+type dockerContainer struct {
+	ID      string `json:"Id"`
+	Name    string `json:"Name"`
+	Created string `json:"Created"`
+	Config  struct {
+		Image  string            `json:"Image"`
+		Labels map[string]string `json:"Labels"`
+	} `json:"Config"`
+	RestartCount int `json:"RestartCount"`
+	State        struct {
+		Status    string `json:"Status"`
+		Running   bool   `json:"Running"`
+		OOMKilled bool   `json:"OOMKilled"`
+		ExitCode  int    `json:"ExitCode"`
+		Error     string `json:"Error"`
+	} `json:"State"`
+	NetworkSettings struct {
+		IPAddress string `json:"IPAddress"`
+		Networks  map[string]struct {
+			IPAddress string `json:"IPAddress"`
+		} `json:"Networks"`
+	} `json:"NetworkSettings"`
+}
+
+func (d *dockerRuntime) Start(ctx context.Context, spec ContainerSpec) (ContainerInfo, error) {
+	hostConfig, err := dockerHostConfig(spec.Sandbox)
+	if err != nil {
+		return ContainerInfo{}, err
+	}
+	// Docker has no annotations, so they ride along as labels.
+	containerLabels := managedLabels(spec.Name, spec.Labels)
+	for key, value := range spec.Annotations {
+		containerLabels[key] = value
+	}
+	config := map[string]any{
+		"Image":      spec.Image,
+		"User":       fmt.Sprintf("%d:%d", spec.Sandbox.RunAsUser, spec.Sandbox.RunAsGroup),
+		"Labels":     containerLabels,
+		"HostConfig": hostConfig,
+	}
+	if len(spec.Command) > 0 {
+		config["Entrypoint"] = spec.Command[:1]
+		config["Cmd"] = spec.Command[1:]
+	}
+	body, err := json.Marshal(config)
+	if err != nil {
+		return ContainerInfo{}, err
+	}
+
+	createPath := "/containers/create?" + url.Values{"name": {spec.Name}}.Encode()
+	status, respBody, err := d.do(ctx, http.MethodPost, createPath, "application/json", bytes.NewReader(body))
+	if err == nil && status == http.StatusNotFound {
+		// The image only exists in a registry; pull it and try once more.
+		if err = d.pull(ctx, spec.Image); err == nil {
+			status, respBody, err = d.do(ctx, http.MethodPost, createPath, "application/json", bytes.NewReader(body))
+		}
+	}
+	if err != nil {
+		return ContainerInfo{}, fmt.Errorf("create container: %w", err)
+	}
+	if status != http.StatusCreated {
+		return ContainerInfo{}, fmt.Errorf("create container: docker engine returned %d: %s", status, strings.TrimSpace(string(respBody)))
+	}
+
+	status, respBody, err = d.do(ctx, http.MethodPost, "/containers/"+url.PathEscape(spec.Name)+"/start", "", nil)
+	if err != nil {
+		return ContainerInfo{}, fmt.Errorf("start container: %w", err)
+	}
+	if status != http.StatusNoContent && status != http.StatusNotModified {
+		return ContainerInfo{}, fmt.Errorf("start container: docker engine returned %d: %s", status, strings.TrimSpace(string(respBody)))
+	}
+	return d.Inspect(ctx, spec.Name)
+}
+
+// dockerHostConfig applies the sandbox policy with Docker's equivalents.
+// Docker has no pod deadline, so ActiveDeadlineSeconds is left to the
+// caller, and localhost seccomp profiles fall back to Docker's default.
+func dockerHostConfig(policy sandboxpolicy.Policy) (map[string]any, error) {
+	hostConfig := map[string]any{
+		"ReadonlyRootfs": policy.ReadOnlyRootFilesystem,
+		"CapDrop":        policy.DropCapabilities,
+		"SecurityOpt":    []string{"no-new-privileges"},
+	}
+	if policy.SeccompProfile == sandboxpolicy.SeccompUnconfined {
+		hostConfig["SecurityOpt"] = []string{"no-new-privileges", "seccomp=unconfined"}
+	}
+	if policy.RuntimeClassName != "" {
+		hostConfig["Runtime"] = policy.RuntimeClassName
+	}
+	if policy.Resources.MemoryLimit != "" {
+		quantity, err := resource.ParseQuantity(policy.Resources.MemoryLimit)
+		if err != nil {
+			return nil, fmt.Errorf("invalid memory quantity %q: %w", policy.Resources.MemoryLimit, err)
+		}
+		hostConfig["Memory"] = quantity.Value()
+	}
+	if policy.Resources.CPULimit != "" {
+		quantity, err := resource.ParseQuantity(policy.Resources.CPULimit)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cpu quantity %q: %w", policy.Resources.CPULimit, err)
+		}
+		hostConfig["NanoCpus"] = quantity.MilliValue() * 1_000_000
+	}
+	tmpfsOptions := "rw,nosuid,nodev,noexec"
+	if policy.ScratchSizeLimit != "" {
+		quantity, err := resource.ParseQuantity(policy.ScratchSizeLimit)
+		if err != nil {
+			return nil, fmt.Errorf("invalid scratch size limit %q: %w", policy.ScratchSizeLimit, err)
+		}
+		tmpfsOptions += fmt.Sprintf(",size=%d", quantity.Value())
+	}
+	tmpfs := map[string]string{}
+	for _, scratch := range policy.ScratchPaths {
+		tmpfs[scratch] = tmpfsOptions
+	}
+	hostConfig["Tmpfs"] = tmpfs
+	return hostConfig, nil
+}
+
+// pull fetches image. The engine answers 200 as soon as the pull starts
+// and reports failures, such as a missing tag, as messages in the progress
+// stream, so the stream is read to the end.
+func (d *dockerRuntime) pull(ctx context.Context, image string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.baseURL+"/images/create?"+url.Values{"fromImage": {image}}.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("pull %s: %w", image, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("pull %s: docker engine returned %d: %s", image, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var message dockerMessage
+		if err := decoder.Decode(&message); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("pull %s: read progress: %w", image, err)
+		}
+		if message.Error != "" {
+			return fmt.Errorf("pull %s: %s", image, message.Error)
+		}
+	}
+}
+
+func (d *dockerRuntime) WaitReady(ctx context.Context, name string) (ContainerInfo, error) {
+	var info ContainerInfo
+	err := wait.PollUntilContextCancel(ctx, readyPollInterval, true, func(ctx context.Context) (bool, error) {
+		var err error
+		info, err = d.Inspect(ctx, name)
+		if err != nil {
+			return false, err
+		}
+		switch info.Phase {
+		case "Failed", "Succeeded":
+			return false, &StartupError{Name: name, Reason: info.Reason, Message: info.Message}
+		}
+		return info.Ready, nil
+	})
+	return info, err
+}
+
+func (d *dockerRuntime) Inspect(ctx context.Context, name string) (ContainerInfo, error) {
+	status, body, err := d.do(ctx, http.MethodGet, "/containers/"+url.PathEscape(name)+"/json", "", nil)
+	if err != nil {
+		return ContainerInfo{}, fmt.Errorf("inspect container %s: %w", name, err)
+	}
+	if status == http.StatusNotFound {
+		return ContainerInfo{}, ErrContainerNotFound
+	}
+	if status != http.StatusOK {
+		return ContainerInfo{}, fmt.Errorf("inspect container %s: docker engine returned %d", name, status)
+	}
+	var container dockerContainer
+	if err := json.Unmarshal(body, &container); err != nil {
+		return ContainerInfo{}, fmt.Errorf("decode container %s: %w", name, err)
+	}
+	return container.info(), nil
+}
+
+// info maps Docker's container states onto pod phases so callers can treat
+// both runtimes alike.
+func (c dockerContainer) info() ContainerInfo {
+	info := ContainerInfo{
+		Name:         strings.TrimPrefix(c.Name, "/"),
+		ID:           c.ID,
+		Image:        c.Config.Image,
+		Labels:       c.Config.Labels,
+		Ready:        c.State.Running,
+		Message:      c.State.Error,
+		RestartCount: c.RestartCount,
+	}
+	info.CreatedAt, _ = time.Parse(time.RFC3339Nano, c.Created)
+	switch c.State.Status {
+	case "created":
+		info.Phase = "Pending"
+	case "running", "paused", "restarting":
+		info.Phase = "Running"
+	case "exited", "dead":
+		info.Terminated = true
+		info.ExitCode = c.State.ExitCode
+		info.Phase = "Failed"
+		info.Reason = "Error"
+		if c.State.ExitCode == 0 {
+			info.Phase = "Succeeded"
+			info.Reason = "Completed"
+		}
+	default:
+		info.Phase = "Unknown"
+	}
+	if c.State.OOMKilled {
+		info.Reason = "OOMKilled"
+	}
+	return info
+}
+
+// Expose returns the container's address on its Docker network, which the
+// judge can reach directly when both run on the same host.
+func (d *dockerRuntime) Expose(ctx context.Context, name string, port int) (string, error) {
+	status, body, err := d.do(ctx, http.MethodGet, "/containers/"+url.PathEscape(name)+"/json", "", nil)
+	if err != nil {
+		return "", fmt.Errorf("inspect container %s: %w", name, err)
+	}
+	if status == http.StatusNotFound {
+		return "", ErrContainerNotFound
+	}
+	if status != http.StatusOK {
+		return "", fmt.Errorf("inspect container %s: docker engine returned %d", name, status)
+	}
+	var container dockerContainer
+	if err := json.Unmarshal(body, &container); err != nil {
+		return "", fmt.Errorf("decode container %s: %w", name, err)
+	}
+	address := container.NetworkSettings.IPAddress
+	for _, network := range container.NetworkSettings.Networks {
+		if address == "" {
+			address = network.IPAddress
+		}
+	}
+	if address == "" {
+		return "", fmt.Errorf("container %s has no network address", name)
+	}
+	return net.JoinHostPort(address, strconv.Itoa(port)), nil
+}
+
+func (d *dockerRuntime) Logs(ctx context.Context, name string, opts LogOptions) (io.ReadCloser, error) {
+	query := url.Values{
+		"stdout": {strconv.FormatBool(opts.Stream != LogStreamStderr)},
+		"stderr": {strconv.FormatBool(opts.Stream != LogStreamStdout)},
+	}
+	if opts.Follow {
+		query.Set("follow", "1")
+	}
+	if opts.TailLines > 0 {
+		query.Set("tail", strconv.FormatInt(opts.TailLines, 10))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.baseURL+"/containers/"+url.PathEscape(name)+"/logs?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("stream logs for container %s: %w", name, err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, ErrContainerNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("stream logs for container %s: docker engine returned %s", name, resp.Status)
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		defer resp.Body.Close()
+		pw.CloseWithError(demuxDockerStream(resp.Body, pw, pw))
+	}()
+	if opts.LimitBytes > 0 {
+		return struct {
+			io.Reader
+			io.Closer
+		}{io.LimitReader(pr, opts.LimitBytes), pr}, nil
+	}
+	return pr, nil
+}
+
+// Exec runs command through the Engine API exec endpoints. The start call
+// is hijacked onto a raw connection so stdin can be streamed and then
+// half-closed, which is how the process sees end of input.
+func (d *dockerRuntime) Exec(ctx context.Context, name string, command []string, stdin io.Reader) (ExecResult, error) {
+	body, _ := json.Marshal(map[string]any{
+		"AttachStdin":  stdin != nil,
+		"AttachStdout": true,
+		"AttachStderr": true,
+		"Cmd":          command,
+	})
+	status, respBody, err := d.do(ctx, http.MethodPost, "/containers/"+url.PathEscape(name)+"/exec", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return ExecResult{}, fmt.Errorf("create exec in container %s: %w", name, err)
+	}
+	if status == http.StatusNotFound {
+		return ExecResult{}, ErrContainerNotFound
+	}
+	if status != http.StatusCreated {
+		return ExecResult{}, fmt.Errorf("create exec in container %s: docker engine returned %d: %s", name, status, strings.TrimSpace(string(respBody)))
+	}
+	var created struct {
+		ID string `json:"Id"`
+	}
+	if err := json.Unmarshal(respBody, &created); err != nil {
+		return ExecResult{}, fmt.Errorf("decode exec: %w", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if err := d.startExec(ctx, created.ID, stdin, &stdout, &stderr); err != nil {
+		return ExecResult{Stdout: stdout.String(), Stderr: stderr.String()}, fmt.Errorf("exec in container %s: %w", name, err)
+	}
+
+	status, respBody, err = d.do(ctx, http.MethodGet, "/exec/"+created.ID+"/json", "", nil)
+	if err != nil {
+		return ExecResult{}, fmt.Errorf("inspect exec: %w", err)
+	}
+	var inspected struct {
+		ExitCode int `json:"ExitCode"`
+	}
+	if status != http.StatusOK {
+		return ExecResult{}, fmt.Errorf("inspect exec: docker engine returned %d", status)
+	}
+	if err := json.Unmarshal(respBody, &inspected); err != nil {
+		return ExecResult{}, fmt.Errorf("decode exec result: %w", err)
+	}
+	return ExecResult{Stdout: stdout.String(), Stderr: stderr.String(), ExitCode: inspected.ExitCode}, nil
+}
+
+func (d *dockerRuntime) startExec(ctx context.Context, id string, stdin io.Reader, stdout, stderr io.Writer) error {
+	conn, err := d.dial(ctx)
+	if err != nil {
+		return fmt.Errorf("dial docker engine: %w", err)
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	req, err := http.NewRequest(http.MethodPost, d.baseURL+"/exec/"+id+"/start", strings.NewReader(`{"Detach":false,"Tty":false}`))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "tcp")
+	if err := req.Write(conn); err != nil {
+		return fmt.Errorf("start exec: %w", err)
+	}
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		return fmt.Errorf("start exec: %w", err)
+	}
+	var output io.Reader = reader
+	switch resp.StatusCode {
+	case http.StatusSwitchingProtocols:
+	case http.StatusOK:
+		// The engine declined the upgrade; output still follows.
+		output = resp.Body
+	default:
+		return fmt.Errorf("start exec: docker engine returned %s", resp.Status)
+	}
+
+	if stdin != nil {
+		go func() {
+			io.Copy(conn, stdin)
+			if closer, ok := conn.(interface{ CloseWrite() error }); ok {
+				closer.CloseWrite()
+			}
+		}()
+	}
+	if err := demuxDockerStream(output, stdout, stderr); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("read exec output: %w", err)
+	}
+	return nil
+}
+
+// dial opens a raw connection to the engine for hijacked requests.
+func (d *dockerRuntime) dial(ctx context.Context) (net.Conn, error) {
+	if transport, ok := d.client.Transport.(*http.Transport); ok && transport.DialContext != nil {
+		return transport.DialContext(ctx, "tcp", "docker")
+	}
+	var dialer net.Dialer
+	return dialer.DialContext(ctx, "tcp", strings.TrimPrefix(d.baseURL, "http://"))
+}
+
+func (d *dockerRuntime) Stop(ctx context.Context, name string) error {
+	status, respBody, err := d.do(ctx, http.MethodDelete, "/containers/"+url.PathEscape(name)+"?force=1&v=1", "", nil)
+	if err != nil {
+		return fmt.Errorf("remove container %s: %w", name, err)
+	}
+	if status == http.StatusNotFound {
+		return ErrContainerNotFound
+	}
+	if status != http.StatusNoContent {
+		return fmt.Errorf("remove container %s: docker engine returned %d: %s", name, status, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}
+
+func (d *dockerRuntime) List(ctx context.Context) ([]ContainerInfo, error) {
+	filters, _ := json.Marshal(map[string][]string{"label": {ManagedByLabel + "=" + ManagedByValue}})
+	query := url.Values{"all": {"1"}, "filters": {string(filters)}}
+	status, body, err := d.do(ctx, http.MethodGet, "/containers/json?"+query.Encode(), "", nil)
+	if err != nil {
+		return nil, fmt.Errorf("list containers: %w", err)
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("list containers: docker engine returned %d", status)
+	}
+	var summaries []struct {
+		Names []string `json:"Names"`
+	}
+	if err := json.Unmarshal(body, &summaries); err != nil {
+		return nil, fmt.Errorf("decode containers: %w", err)
+	}
+	infos := make([]ContainerInfo, 0, len(summaries))
+	for _, summary := range summaries {
+		if len(summary.Names) == 0 {
+			continue
+		}
+		info, err := d.Inspect(ctx, strings.TrimPrefix(summary.Names[0], "/"))
+		if errors.Is(err, ErrContainerNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// do sends a small Engine API request and returns the status and body.
+func (d *dockerRuntime) do(ctx context.Context, method, path, contentType string, body io.Reader) (int, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, d.baseURL+path, body)
+	if err != nil {
+		return 0, nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return 0, nil, err
+	}
+	return resp.StatusCode, respBody, nil
+}
+
+// demuxDockerStream splits the Engine API's multiplexed stdout/stderr
+// stream, where each frame is an 8-byte header (stream type, padding,
+// big-endian length) followed by the payload.
+func demuxDockerStream(r io.Reader, stdout, stderr io.Writer) error {
+	var header [8]byte
+	for {
+		if _, err := io.ReadFull(r, header[:]); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		size := int64(binary.BigEndian.Uint32(header[4:]))
+		destination := stdout
+		if header[0] == 2 {
+			destination = stderr
+		}
+		if _, err := io.CopyN(destination, r, size); err != nil {
+			return err
+		}
+	}
+}
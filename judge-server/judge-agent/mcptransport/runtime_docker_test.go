@@ -0,0 +1,61 @@
+package mcptransport
+
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDockerRuntimePull(t *testing.T) {
+	progress := strings.Repeat(`{"status":"Downloading","progressDetail":{"current":1,"total":2},"id":"abc"}`+"\n", 20000)
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr string
+	}{
+		{"done", http.StatusOK, `{"status":"Pulling from library/python"}` + "\n" + `{"status":"Status: Downloaded newer image"}` + "\n", ""},
+		{"long progress", http.StatusOK, progress + `{"status":"Digest: sha256:0"}` + "\n", ""},
+		{"error after long progress", http.StatusOK, progress + `{"errorDetail":{"message":"unexpected EOF"},"error":"unexpected EOF"}` + "\n", "unexpected EOF"},
+		{"error message", http.StatusOK, `{"status":"Pulling"}` + "\n" + `{"error":"manifest unknown"}` + "\n", "manifest unknown"},
+		{"cut off stream", http.StatusOK, `{"status":"Pull`, "read progress"},
+		{"engine refuses", http.StatusNotFound, `{"message":"pull access denied"}`, "pull access denied"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost || r.URL.Path != "/images/create" || r.URL.Query().Get("fromImage") != "python:3.12" {
+					t.Errorf("unexpected request %s %s", r.Method, r.URL)
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			runtime := &dockerRuntime{client: server.Client(), baseURL: server.URL}
+			err := runtime.pull(context.Background(), "python:3.12")
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("pull() error = %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("pull() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
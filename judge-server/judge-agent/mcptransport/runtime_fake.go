@@ -0,0 +1,70 @@
+package mcptransport
+
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import (
+	"context"
+	"io"
+
+	"github.com/google/uuid"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// FakeRuntime is the Kubernetes runtime over client-go's fake clientset.
+// Created pods are immediately Running and Ready unless a reactor
+// prepended to Clientset says otherwise. Exec is answered by ExecFunc,
+// since the fake clientset cannot stream.
+type FakeRuntime struct {
+	*kubernetesRuntime
+	Clientset *fake.Clientset
+	ExecFunc  func(ctx context.Context, name string, command []string, stdin io.Reader) (ExecResult, error)
+}
+
+// NewFakeRuntime returns a FakeRuntime seeded with objects.
+func NewFakeRuntime(objects ...k8sruntime.Object) *FakeRuntime {
+	clientset := fake.NewClientset(objects...)
+	clientset.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, k8sruntime.Object, error) {
+		pod, ok := action.(k8stesting.CreateAction).GetObject().(*corev1.Pod)
+		if !ok {
+			return false, nil, nil
+		}
+		// Fill in what the API server and kubelet would, then let the
+		// default tracker store the pod.
+		pod.UID = types.UID(uuid.NewString())
+		pod.CreationTimestamp = metav1.Now()
+		pod.Status.Phase = corev1.PodRunning
+		pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+		return false, nil, nil
+	})
+	return &FakeRuntime{
+		kubernetesRuntime: &kubernetesRuntime{clientset: clientset, namespace: "default"},
+		Clientset:         clientset,
+	}
+}
+
+func (f *FakeRuntime) Exec(ctx context.Context, name string, command []string, stdin io.Reader) (ExecResult, error) {
+	if _, err := f.Inspect(ctx, name); err != nil {
+		return ExecResult{}, err
+	}
+	if f.ExecFunc == nil {
+		return ExecResult{}, nil
+	}
+	return f.ExecFunc(ctx, name, command, stdin)
+}
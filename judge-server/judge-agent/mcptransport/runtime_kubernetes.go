@@ -0,0 +1,483 @@
+package mcptransport
+
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/remotecommand"
+	utilexec "k8s.io/client-go/util/exec"
+	"k8s.io/utils/ptr"
+
+	"main/judge-agent/profiles"
+	"main/judge-agent/sandboxpolicy"
+)
+
+const (
+	sandboxContainerName = "mcp"
+	readyPollInterval    = 500 * time.Millisecond
+)
+
+// kubernetesRuntime runs each sandbox as a single-container pod.
+type kubernetesRuntime struct {
+	clientset kubernetes.Interface
+	// config is nil for clientsets that cannot exec, such as the fake.
+	config    *rest.Config
+	namespace string
+}
+
+// newKubernetesRuntimeFromEnv uses the in-cluster service account when
+// running in a pod and falls back to the usual kubeconfig loading rules
+// ($KUBECONFIG, then ~/.kube/config) otherwise.
+func newKubernetesRuntimeFromEnv() (*kubernetesRuntime, error) {
+	config, kubeconfigNamespace, err := loadKubernetesConfig()
+	if err != nil {
+		return nil, err
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("create kubernetes client: %w", err)
+	}
+	return &kubernetesRuntime{
+		clientset: clientset,
+		config:    config,
+		namespace: resolveKubernetesNamespace(kubeconfigNamespace),
+	}, nil
+}
+
+func loadKubernetesConfig() (*rest.Config, string, error) {
+	config, err := rest.InClusterConfig()
+	if err == nil {
+		return config, "", nil
+	}
+	if !errors.Is(err, rest.ErrNotInCluster) {
+		return nil, "", fmt.Errorf("create in-cluster config: %w", err)
+	}
+	loader := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		clientcmd.NewDefaultClientConfigLoadingRules(),
+		&clientcmd.ConfigOverrides{},
+	)
+	config, err = loader.ClientConfig()
+	if err != nil {
+		return nil, "", fmt.Errorf("load kubeconfig: %w", err)
+	}
+	namespace, _, err := loader.Namespace()
+	if err != nil {
+		namespace = ""
+	}
+	return config, namespace, nil
+}
+
+// resolveKubernetesNamespace prefers MCP_K8S_NAMESPACE, then the pod's own
+// service account namespace, then the kubeconfig context's namespace.
+func resolveKubernetesNamespace(kubeconfigNamespace string) string {
+	if namespace := strings.TrimSpace(os.Getenv("MCP_K8S_NAMESPACE")); namespace != "" {
+		return namespace
+	}
+	namespacePath := "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+	if contents, err := os.ReadFile(namespacePath); err == nil {
+		if namespace := strings.TrimSpace(string(contents)); namespace != "" {
+			return namespace
+		}
+	}
+	if kubeconfigNamespace != "" {
+		return kubeconfigNamespace
+	}
+	return "default"
+}
+
+func (k *kubernetesRuntime) pods() typedcorev1.PodInterface {
+	return k.clientset.CoreV1().Pods(k.namespace)
+}
+
+func (k *kubernetesRuntime) Start(ctx context.Context, spec ContainerSpec) (ContainerInfo, error) {
+	pod, err := k.sandboxPod(spec)
+	if err != nil {
+		return ContainerInfo{}, err
+	}
+	result, err := k.pods().Create(ctx, pod, metav1.CreateOptions{})
+	if err != nil {
+		return ContainerInfo{}, fmt.Errorf("create pod: %w", err)
+	}
+	if result.UID == "" {
+		return ContainerInfo{}, fmt.Errorf("pod created but UID missing in response")
+	}
+	return podInfo(result), nil
+}
+
+// sandboxPod renders spec as a pod locked down by spec.Sandbox: non-root,
+// no capabilities or privilege escalation, a read-only root filesystem
+// with tmpfs scratch space, no service account token and a hard deadline.
+func (k *kubernetesRuntime) sandboxPod(spec ContainerSpec) (*corev1.Pod, error) {
+	policy := spec.Sandbox
+	requirements, err := resourceRequirements(policy.Resources)
+	if err != nil {
+		return nil, err
+	}
+
+	var volumes []corev1.Volume
+	var mounts []corev1.VolumeMount
+	for i, scratch := range policy.ScratchPaths {
+		emptyDir := &corev1.EmptyDirVolumeSource{Medium: corev1.StorageMediumMemory}
+		if policy.ScratchSizeLimit != "" {
+			sizeLimit, err := resource.ParseQuantity(policy.ScratchSizeLimit)
+			if err != nil {
+				return nil, fmt.Errorf("invalid scratch size limit %q: %w", policy.ScratchSizeLimit, err)
+			}
+			emptyDir.SizeLimit = &sizeLimit
+		}
+		name := fmt.Sprintf("scratch-%d", i)
+		volumes = append(volumes, corev1.Volume{Name: name, VolumeSource: corev1.VolumeSource{EmptyDir: emptyDir}})
+		mounts = append(mounts, corev1.VolumeMount{Name: name, MountPath: scratch})
+	}
+
+	capabilities := &corev1.Capabilities{}
+	for _, capability := range policy.DropCapabilities {
+		capabilities.Drop = append(capabilities.Drop, corev1.Capability(capability))
+	}
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        spec.Name,
+			Namespace:   k.namespace,
+			Labels:      managedLabels(spec.Name, spec.Labels),
+			Annotations: spec.Annotations,
+		},
+		Spec: corev1.PodSpec{
+			RestartPolicy:                corev1.RestartPolicyNever,
+			AutomountServiceAccountToken: ptr.To(policy.AutomountServiceAccountToken),
+			EnableServiceLinks:           ptr.To(false),
+			SecurityContext: &corev1.PodSecurityContext{
+				RunAsNonRoot:   ptr.To(true),
+				RunAsUser:      ptr.To(policy.RunAsUser),
+				RunAsGroup:     ptr.To(policy.RunAsGroup),
+				SeccompProfile: seccompProfile(policy),
+			},
+			Volumes: volumes,
+			Containers: []corev1.Container{
+				{
+					Name:            sandboxContainerName,
+					Image:           spec.Image,
+					Command:         spec.Command,
+					ImagePullPolicy: corev1.PullIfNotPresent,
+					Resources:       requirements,
+					VolumeMounts:    mounts,
+					SecurityContext: &corev1.SecurityContext{
+						Privileged:               ptr.To(false),
+						AllowPrivilegeEscalation: ptr.To(false),
+						ReadOnlyRootFilesystem:   ptr.To(policy.ReadOnlyRootFilesystem),
+						Capabilities:             capabilities,
+					},
+				},
+			},
+		},
+	}
+	if spec.Port > 0 {
+		container := &pod.Spec.Containers[0]
+		container.Ports = []corev1.ContainerPort{{Name: "app", ContainerPort: int32(spec.Port)}}
+		container.ReadinessProbe = &corev1.Probe{
+			ProbeHandler: corev1.ProbeHandler{
+				TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt32(int32(spec.Port))},
+			},
+			PeriodSeconds:    2,
+			FailureThreshold: 3,
+		}
+	}
+	if policy.ActiveDeadlineSeconds > 0 {
+		pod.Spec.ActiveDeadlineSeconds = ptr.To(policy.ActiveDeadlineSeconds)
+	}
+	if policy.RuntimeClassName != "" {
+		pod.Spec.RuntimeClassName = ptr.To(policy.RuntimeClassName)
+	}
+	return pod, nil
+}
+
+func seccompProfile(policy sandboxpolicy.Policy) *corev1.SeccompProfile {
+	if profile, ok := policy.LocalhostSeccompProfile(); ok {
+		return &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeLocalhost, LocalhostProfile: ptr.To(profile)}
+	}
+	if policy.SeccompProfile == sandboxpolicy.SeccompUnconfined {
+		return &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeUnconfined}
+	}
+	return &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault}
+}
+
+// fatalWaitingReasons are container waiting reasons that will not resolve
+// on their own, so there is no point waiting out the timeout.
+var fatalWaitingReasons = map[string]bool{
+	"ImagePullBackOff":           true,
+	"ErrImageNeverPull":          true,
+	"InvalidImageName":           true,
+	"CrashLoopBackOff":           true,
+	"CreateContainerConfigError": true,
+	"CreateContainerError":       true,
+	"RunContainerError":          true,
+}
+
+func (k *kubernetesRuntime) WaitReady(ctx context.Context, name string) (ContainerInfo, error) {
+	var info ContainerInfo
+	err := wait.PollUntilContextCancel(ctx, readyPollInterval, true, func(ctx context.Context) (bool, error) {
+		pod, err := k.pods().Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return false, ErrContainerNotFound
+		}
+		if err != nil {
+			return false, fmt.Errorf("get pod %s: %w", name, err)
+		}
+		info = podInfo(pod)
+		switch {
+		case info.Ready:
+			return true, nil
+		case pod.Status.Phase == corev1.PodFailed, pod.Status.Phase == corev1.PodSucceeded:
+			reason := info.Reason
+			if reason == "" {
+				reason = string(pod.Status.Phase)
+			}
+			return false, &StartupError{Name: name, Reason: reason, Message: info.Message}
+		case fatalWaitingReasons[info.Reason]:
+			return false, &StartupError{Name: name, Reason: info.Reason, Message: info.Message}
+		}
+		return false, nil
+	})
+	return info, err
+}
+
+func (k *kubernetesRuntime) Inspect(ctx context.Context, name string) (ContainerInfo, error) {
+	pod, err := k.pods().Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return ContainerInfo{}, ErrContainerNotFound
+	}
+	if err != nil {
+		return ContainerInfo{}, fmt.Errorf("get pod %s: %w", name, err)
+	}
+	return podInfo(pod), nil
+}
+
+// Logs reads the pod log, which interleaves stdout and stderr; splitting
+// them is still an alpha feature gate, so Stream is not supported.
+func (k *kubernetesRuntime) Logs(ctx context.Context, name string, opts LogOptions) (io.ReadCloser, error) {
+	if opts.Stream != "" {
+		return nil, ErrLogStreamsCombined
+	}
+	logOptions := &corev1.PodLogOptions{Container: sandboxContainerName, Follow: opts.Follow}
+	if opts.TailLines > 0 {
+		logOptions.TailLines = &opts.TailLines
+	}
+	if opts.LimitBytes > 0 {
+		logOptions.LimitBytes = &opts.LimitBytes
+	}
+	stream, err := k.pods().GetLogs(name, logOptions).Stream(ctx)
+	if apierrors.IsNotFound(err) {
+		return nil, ErrContainerNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("stream logs for pod %s: %w", name, err)
+	}
+	return stream, nil
+}
+
+func (k *kubernetesRuntime) Exec(ctx context.Context, name string, command []string, stdin io.Reader) (ExecResult, error) {
+	if k.config == nil {
+		return ExecResult{}, fmt.Errorf("exec is not supported by this kubernetes client")
+	}
+	req := k.clientset.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(k.namespace).
+		Name(name).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: sandboxContainerName,
+			Command:   command,
+			Stdin:     stdin != nil,
+			Stdout:    true,
+			Stderr:    true,
+		}, scheme.ParameterCodec)
+	executor, err := remotecommand.NewSPDYExecutor(k.config, "POST", req.URL())
+	if err != nil {
+		return ExecResult{}, fmt.Errorf("create executor: %w", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	err = executor.StreamWithContext(ctx, remotecommand.StreamOptions{
+		Stdin:  stdin,
+		Stdout: &stdout,
+		Stderr: &stderr,
+	})
+	result := ExecResult{Stdout: stdout.String(), Stderr: stderr.String()}
+	var exitErr utilexec.ExitError
+	if errors.As(err, &exitErr) && exitErr.Exited() {
+		result.ExitCode = exitErr.ExitStatus()
+		return result, nil
+	}
+	if err != nil {
+		return result, fmt.Errorf("exec in pod %s: %w", name, err)
+	}
+	return result, nil
+}
+
+func (k *kubernetesRuntime) Stop(ctx context.Context, name string) error {
+	// The Service is owned by the pod and would be garbage collected with
+	// it; deleting it here just frees the ClusterIP sooner.
+	err := k.clientset.CoreV1().Services(k.namespace).Delete(ctx, name, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("delete service %s: %w", name, err)
+	}
+	err = k.pods().Delete(ctx, name, metav1.DeleteOptions{})
+	if apierrors.IsNotFound(err) {
+		return ErrContainerNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("delete pod %s: %w", name, err)
+	}
+	return nil
+}
+
+func (k *kubernetesRuntime) List(ctx context.Context) ([]ContainerInfo, error) {
+	selector := labels.SelectorFromSet(labels.Set{ManagedByLabel: ManagedByValue})
+	list, err := k.pods().List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, fmt.Errorf("list pods: %w", err)
+	}
+	infos := make([]ContainerInfo, 0, len(list.Items))
+	for i := range list.Items {
+		infos = append(infos, podInfo(&list.Items[i]))
+	}
+	return infos, nil
+}
+
+// Expose creates a ClusterIP Service in front of the sandbox pod and
+// returns its in-cluster DNS address.
+func (k *kubernetesRuntime) Expose(ctx context.Context, name string, port int) (string, error) {
+	pod, err := k.pods().Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return "", ErrContainerNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("get pod %s: %w", name, err)
+	}
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: k.namespace,
+			Labels:    managedLabels(name, nil),
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: "v1",
+				Kind:       "Pod",
+				Name:       pod.Name,
+				UID:        pod.UID,
+			}},
+		},
+		Spec: corev1.ServiceSpec{
+			Type:     corev1.ServiceTypeClusterIP,
+			Selector: map[string]string{SandboxLabel: name},
+			Ports: []corev1.ServicePort{{
+				Name:       "app",
+				Port:       int32(port),
+				TargetPort: intstr.FromInt32(int32(port)),
+			}},
+		},
+	}
+	_, err = k.clientset.CoreV1().Services(k.namespace).Create(ctx, service, metav1.CreateOptions{})
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		return "", fmt.Errorf("create service %s: %w", name, err)
+	}
+	return fmt.Sprintf("%s.%s.svc:%d", name, k.namespace, port), nil
+}
+
+func podInfo(pod *corev1.Pod) ContainerInfo {
+	info := ContainerInfo{
+		Name:      pod.Name,
+		ID:        string(pod.UID),
+		Phase:     string(pod.Status.Phase),
+		Reason:    pod.Status.Reason,
+		Message:   pod.Status.Message,
+		Labels:    pod.Labels,
+		CreatedAt: pod.CreationTimestamp.Time,
+	}
+	if len(pod.Spec.Containers) > 0 {
+		info.Image = pod.Spec.Containers[0].Image
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			info.Ready = condition.Status == corev1.ConditionTrue
+		}
+	}
+	for _, status := range pod.Status.ContainerStatuses {
+		if waiting := status.State.Waiting; waiting != nil && waiting.Reason != "" {
+			info.Reason, info.Message = waiting.Reason, waiting.Message
+		}
+		if terminated := status.State.Terminated; terminated != nil {
+			info.Terminated = true
+			info.ExitCode = int(terminated.ExitCode)
+			if terminated.Reason != "" {
+				info.Reason, info.Message = terminated.Reason, terminated.Message
+			}
+		}
+		info.RestartCount = int(status.RestartCount)
+	}
+	// Pod-level reasons such as DeadlineExceeded or Evicted explain the
+	// container's exit better than its own Error.
+	if pod.Status.Reason != "" {
+		info.Reason, info.Message = pod.Status.Reason, pod.Status.Message
+	}
+	return info
+}
+
+func resourceRequirements(resources profiles.Resources) (corev1.ResourceRequirements, error) {
+	requirements := corev1.ResourceRequirements{}
+	quantities := []struct {
+		list  *corev1.ResourceList
+		name  corev1.ResourceName
+		value string
+	}{
+		{&requirements.Requests, corev1.ResourceCPU, resources.CPURequest},
+		{&requirements.Limits, corev1.ResourceCPU, resources.CPULimit},
+		{&requirements.Requests, corev1.ResourceMemory, resources.MemoryRequest},
+		{&requirements.Limits, corev1.ResourceMemory, resources.MemoryLimit},
+	}
+	for _, q := range quantities {
+		if strings.TrimSpace(q.value) == "" {
+			continue
+		}
+		quantity, err := resource.ParseQuantity(q.value)
+		if err != nil {
+			return corev1.ResourceRequirements{}, fmt.Errorf("invalid %s quantity %q: %w", q.name, q.value, err)
+		}
+		if *q.list == nil {
+			*q.list = corev1.ResourceList{}
+		}
+		(*q.list)[q.name] = quantity
+	}
+	return requirements, nil
+}
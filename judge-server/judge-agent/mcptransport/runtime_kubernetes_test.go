@@ -0,0 +1,166 @@
+package mcptransport
+
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"main/judge-agent/profiles"
+	"main/judge-agent/sandboxpolicy"
+)
+
+// startedPod starts spec on a fake runtime and returns the pod as stored.
+func startedPod(t *testing.T, spec ContainerSpec) *corev1.Pod {
+	t.Helper()
+	fake := NewFakeRuntime()
+	ctx := context.Background()
+	if _, err := fake.Start(ctx, spec); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	pod, err := fake.Clientset.CoreV1().Pods("default").Get(ctx, spec.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pod
+}
+
+func TestSandboxPodHardening(t *testing.T) {
+	profile := &profiles.Profile{
+		Resources:      profiles.Resources{MemoryLimit: "512Mi"},
+		ScratchPaths:   []string{"/home/runner/.cache"},
+		TimeoutSeconds: 45,
+	}
+	pod := startedPod(t, ContainerSpec{
+		Name:    podNamePrefix + "hardening",
+		Image:   "registry.local/mcp-image-x:latest",
+		Port:    8080,
+		Sandbox: sandboxpolicy.Default().ForProfile(profile),
+	})
+
+	spec := pod.Spec
+	if spec.AutomountServiceAccountToken == nil || *spec.AutomountServiceAccountToken {
+		t.Errorf("AutomountServiceAccountToken = %v, want false", spec.AutomountServiceAccountToken)
+	}
+	if spec.EnableServiceLinks == nil || *spec.EnableServiceLinks {
+		t.Errorf("EnableServiceLinks = %v, want false", spec.EnableServiceLinks)
+	}
+	if spec.RestartPolicy != corev1.RestartPolicyNever {
+		t.Errorf("RestartPolicy = %s, want Never", spec.RestartPolicy)
+	}
+	if spec.ActiveDeadlineSeconds == nil || *spec.ActiveDeadlineSeconds != 45 {
+		t.Errorf("ActiveDeadlineSeconds = %v, want the profile timeout 45", spec.ActiveDeadlineSeconds)
+	}
+	if spec.RuntimeClassName != nil {
+		t.Errorf("RuntimeClassName = %q, want unset", *spec.RuntimeClassName)
+	}
+
+	podSecurity := spec.SecurityContext
+	if podSecurity.RunAsNonRoot == nil || !*podSecurity.RunAsNonRoot {
+		t.Errorf("RunAsNonRoot = %v, want true", podSecurity.RunAsNonRoot)
+	}
+	if podSecurity.RunAsUser == nil || *podSecurity.RunAsUser != 65532 {
+		t.Errorf("RunAsUser = %v, want 65532", podSecurity.RunAsUser)
+	}
+	if podSecurity.SeccompProfile == nil || podSecurity.SeccompProfile.Type != corev1.SeccompProfileTypeRuntimeDefault {
+		t.Errorf("SeccompProfile = %v, want RuntimeDefault", podSecurity.SeccompProfile)
+	}
+
+	if len(spec.Containers) != 1 {
+		t.Fatalf("%d containers, want 1", len(spec.Containers))
+	}
+	container := spec.Containers[0]
+	security := container.SecurityContext
+	if security.Privileged == nil || *security.Privileged {
+		t.Errorf("Privileged = %v, want false", security.Privileged)
+	}
+	if security.AllowPrivilegeEscalation == nil || *security.AllowPrivilegeEscalation {
+		t.Errorf("AllowPrivilegeEscalation = %v, want false", security.AllowPrivilegeEscalation)
+	}
+	if security.ReadOnlyRootFilesystem == nil || !*security.ReadOnlyRootFilesystem {
+		t.Errorf("ReadOnlyRootFilesystem = %v, want true", security.ReadOnlyRootFilesystem)
+	}
+	if security.Capabilities == nil || len(security.Capabilities.Drop) != 1 || security.Capabilities.Drop[0] != "ALL" {
+		t.Errorf("Capabilities = %v, want ALL dropped", security.Capabilities)
+	}
+	if len(security.Capabilities.Add) != 0 {
+		t.Errorf("Capabilities.Add = %v, want none", security.Capabilities.Add)
+	}
+
+	if got := container.Resources.Limits.Memory().String(); got != "512Mi" {
+		t.Errorf("memory limit = %s, want the profile's 512Mi", got)
+	}
+	if got := container.Resources.Limits.Cpu().String(); got != "500m" {
+		t.Errorf("cpu limit = %s, want the policy's 500m", got)
+	}
+
+	mounts := map[string]string{}
+	for _, mount := range container.VolumeMounts {
+		mounts[mount.MountPath] = mount.Name
+	}
+	for _, scratch := range []string{"/tmp", "/home/runner/.cache"} {
+		if _, ok := mounts[scratch]; !ok {
+			t.Errorf("no scratch volume mounted at %s; mounts = %v", scratch, mounts)
+		}
+	}
+	for _, volume := range spec.Volumes {
+		emptyDir := volume.EmptyDir
+		if emptyDir == nil || emptyDir.Medium != corev1.StorageMediumMemory {
+			t.Errorf("volume %s = %+v, want a memory-backed emptyDir", volume.Name, volume.VolumeSource)
+			continue
+		}
+		if emptyDir.SizeLimit == nil || emptyDir.SizeLimit.String() != "64Mi" {
+			t.Errorf("volume %s size limit = %v, want 64Mi", volume.Name, emptyDir.SizeLimit)
+		}
+	}
+
+	if container.ReadinessProbe == nil || container.ReadinessProbe.TCPSocket == nil ||
+		container.ReadinessProbe.TCPSocket.Port.IntValue() != 8080 {
+		t.Errorf("ReadinessProbe = %v, want a TCP probe on 8080", container.ReadinessProbe)
+	}
+	if pod.Labels[ManagedByLabel] != ManagedByValue || pod.Labels[SandboxLabel] != pod.Name {
+		t.Errorf("labels = %v, want the managed-by and sandbox labels", pod.Labels)
+	}
+}
+
+func TestSandboxPodPolicyOptions(t *testing.T) {
+	policy := sandboxpolicy.Default()
+	policy.RuntimeClassName = "gvisor"
+	policy.SeccompProfile = "Localhost/profiles/judge.json"
+	policy.ActiveDeadlineSeconds = 0
+	pod := startedPod(t, ContainerSpec{
+		Name:    podNamePrefix + "options",
+		Image:   "registry.local/mcp-image-x:latest",
+		Sandbox: policy.ForProfile(nil),
+	})
+
+	if pod.Spec.RuntimeClassName == nil || *pod.Spec.RuntimeClassName != "gvisor" {
+		t.Errorf("RuntimeClassName = %v, want gvisor", pod.Spec.RuntimeClassName)
+	}
+	seccomp := pod.Spec.SecurityContext.SeccompProfile
+	if seccomp == nil || seccomp.Type != corev1.SeccompProfileTypeLocalhost ||
+		seccomp.LocalhostProfile == nil || *seccomp.LocalhostProfile != "profiles/judge.json" {
+		t.Errorf("SeccompProfile = %v, want Localhost profiles/judge.json", seccomp)
+	}
+	if pod.Spec.ActiveDeadlineSeconds != nil {
+		t.Errorf("ActiveDeadlineSeconds = %d, want unset", *pod.Spec.ActiveDeadlineSeconds)
+	}
+	if pod.Spec.Containers[0].ReadinessProbe != nil {
+		t.Errorf("ReadinessProbe set for a sandbox without a port")
+	}
+}
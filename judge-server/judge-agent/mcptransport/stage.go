@@ -0,0 +1,54 @@
+package mcptransport
+
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import "context"
+
+// DeployStage is a coarse step of the deploy pipeline.
+type DeployStage string
+
+const (
+	StageQueued     DeployStage = "queued"
+	StageBuilding   DeployStage = "building"
+	StagePushing    DeployStage = "pushing"
+	StageScheduling DeployStage = "scheduling"
+	StageRunning    DeployStage = "running"
+	StageTesting    DeployStage = "testing"
+	// StageCompleted ends a deploy that judged tests instead of leaving
+	// the program running.
+	StageCompleted DeployStage = "completed"
+	StageFailed    DeployStage = "failed"
+	StageCancelled DeployStage = "cancelled"
+)
+
+// Terminal reports whether no further stage follows s.
+func (s DeployStage) Terminal() bool {
+	return s == StageRunning || s == StageCompleted || s == StageFailed || s == StageCancelled
+}
+
+type stageReporterKey struct{}
+
+// WithStageReporter returns a context that makes DeployContainer call report
+// each time the pipeline enters a new stage. Terminal stages are never
+// reported; the caller sets one from DeployContainer's result.
+func WithStageReporter(ctx context.Context, report func(DeployStage)) context.Context {
+	return context.WithValue(ctx, stageReporterKey{}, report)
+}
+
+func reportStage(ctx context.Context, stage DeployStage) {
+	if report, ok := ctx.Value(stageReporterKey{}).(func(DeployStage)); ok && report != nil {
+		report(stage)
+	}
+}
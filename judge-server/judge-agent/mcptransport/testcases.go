@@ -0,0 +1,252 @@
+package mcptransport
+
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"main/judge-agent/judging"
+)
+
+const (
+	defaultTestOutputBytes = 1 << 20
+	// reportOutputBytes caps the program output kept in the report.
+	reportOutputBytes = 4 << 10
+	// testExecGrace is how long past the time limit the judge waits for
+	// the wrapper before giving up on the exec itself.
+	testExecGrace = 10 * time.Second
+	metricsMarker = "__JUDGE_METRICS__"
+)
+
+// idleCommand keeps a test sandbox alive so tests can be exec'd into it.
+var idleCommand = []string{"/bin/sh", "-c", "while :; do sleep 3600; done"}
+
+// judgeWrapper runs the program given as its arguments under a time and
+// memory limit and reports what it used as a final stderr line. It polls
+// the peak resident set size from /proc, so a program that allocates and
+// exits between two polls can briefly exceed the limit unnoticed; the
+// sandbox's cgroup limit still bounds it. Output goes through files in
+// scratch space so it can be capped without cutting the program off.
+//
+// Arguments: time limit in ms, memory limit in KiB, output cap in bytes,
+// then the program command.
+const judgeWrapper = `
+limit_ms=$1 limit_kb=$2 max_out=$3
+shift 3
+dir=$(mktemp -d) || exit 125
+exec 3<&0
+start=$(date +%s%N)
+"$@" <&3 3<&- >"$dir/out" 2>"$dir/err" &
+pid=$!
+exec 3<&-
+(sleep "$((limit_ms / 1000)).$(printf %03d $((limit_ms % 1000)))"; kill -9 "$pid") >/dev/null 2>&1 </dev/null &
+watchdog=$!
+peak=0 killed=
+while status=$(cat "/proc/$pid/status" 2>/dev/null); do
+	state= hwm=
+	while read -r key value rest; do
+		case $key in
+		State:) state=$value ;;
+		VmHWM:) hwm=$value ;;
+		esac
+	done <<EOF
+$status
+EOF
+	[ "$state" = Z ] && break
+	if [ -n "$hwm" ]; then
+		[ "$hwm" -gt "$peak" ] && peak=$hwm
+		if [ "$hwm" -gt "$limit_kb" ]; then
+			kill -9 "$pid" 2>/dev/null
+			killed=memory
+			break
+		fi
+	fi
+	sleep 0.01
+done
+wait "$pid" 2>/dev/null
+code=$?
+end=$(date +%s%N)
+kill "$watchdog" 2>/dev/null
+head -c "$max_out" "$dir/out"
+head -c "$max_out" "$dir/err" >&2
+out_bytes=$(wc -c <"$dir/out")
+rm -rf "$dir"
+printf '\n%s start_ns=%s end_ns=%s peak_kb=%s exit=%s killed=%s out_bytes=%s\n' \
+	` + metricsMarker + ` "$start" "$end" "$peak" "$code" "$killed" "$out_bytes" >&2
+`
+
+// runMetrics is what judgeWrapper measured for one run.
+type runMetrics struct {
+	elapsed  time.Duration
+	peakKB   int64
+	exitCode int
+	killed   string
+	outBytes int64
+}
+
+// runTests executes command once per test inside the running sandbox name,
+// feeding the test input on stdin, and judges what it printed with check.
+// Tests run one at a time so they do not compete for the sandbox's CPU. An
+// error means the judge itself could not run a test, not that the program
+// failed one.
+func runTests(ctx context.Context, sandbox Runtime, name string, command []string, tests []judging.TestCase, limits judging.Limits, check outputChecker) (*judging.TestReport, error) {
+	limits = limits.Or(judging.DefaultLimits())
+	maxOutput := envInt64("MCP_TEST_OUTPUT_MAX_BYTES", defaultTestOutputBytes)
+	results := make([]judging.TestResult, 0, len(tests))
+	for i, test := range tests {
+		if test.Name == "" {
+			test.Name = fmt.Sprintf("test-%d", i+1)
+		}
+		result, err := runTestCase(ctx, sandbox, name, command, test, test.Limits.Or(limits), maxOutput, check)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("runTests: %s %s %s in %dms, %dKiB", name, test.Name, result.Verdict, result.TimeMs, result.PeakMemoryKB)
+		results = append(results, result)
+	}
+	return judging.NewReport(results), nil
+}
+
+func runTestCase(ctx context.Context, sandbox Runtime, name string, command []string, test judging.TestCase, limits judging.Limits, maxOutput int64, check outputChecker) (judging.TestResult, error) {
+	args := append([]string{
+		"/bin/sh", "-c", judgeWrapper, "judge",
+		strconv.FormatInt(limits.TimeLimitMs, 10),
+		strconv.FormatInt(limits.MemoryLimitKB, 10),
+		strconv.FormatInt(maxOutput, 10),
+	}, command...)
+	timeLimit := time.Duration(limits.TimeLimitMs) * time.Millisecond
+	execCtx, cancel := context.WithTimeout(ctx, timeLimit+testExecGrace)
+	defer cancel()
+
+	started := time.Now()
+	exec, err := sandbox.Exec(execCtx, name, args, strings.NewReader(test.Input))
+	elapsed := time.Since(started)
+	result := judging.TestResult{Name: test.Name}
+	if err != nil {
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+		if execCtx.Err() != nil {
+			result.Verdict = judging.TimeLimitExceeded
+			result.TimeMs = elapsed.Milliseconds()
+			result.Message = "the program could not be stopped at the time limit"
+			return result, nil
+		}
+		return result, fmt.Errorf("run test %s: %w", test.Name, err)
+	}
+
+	stderr, metrics, ok := parseRunMetrics(exec.Stderr)
+	result.Stdout = capReportOutput(exec.Stdout)
+	result.Stderr = capReportOutput(stderr)
+	if !ok {
+		// The wrapper itself was killed, which only the sandbox's
+		// memory limit does.
+		if exec.ExitCode == 137 {
+			result.Verdict = judging.MemoryLimitExceeded
+			result.ExitCode = exec.ExitCode
+			result.TimeMs = elapsed.Milliseconds()
+			result.Message = "killed by the sandbox memory limit"
+			return result, nil
+		}
+		return result, fmt.Errorf("run test %s: judge wrapper exited %d without metrics: %s",
+			test.Name, exec.ExitCode, strings.TrimSpace(exec.Stderr))
+	}
+	if metrics.elapsed <= 0 {
+		metrics.elapsed = elapsed
+	}
+	result.TimeMs = metrics.elapsed.Milliseconds()
+	result.PeakMemoryKB = metrics.peakKB
+	result.ExitCode = metrics.exitCode
+
+	switch {
+	case metrics.killed == "memory" || metrics.peakKB > limits.MemoryLimitKB:
+		result.Verdict = judging.MemoryLimitExceeded
+		result.Message = fmt.Sprintf("peak memory exceeded %d KiB", limits.MemoryLimitKB)
+	case metrics.elapsed >= timeLimit:
+		result.Verdict = judging.TimeLimitExceeded
+		result.Message = fmt.Sprintf("ran longer than %d ms", limits.TimeLimitMs)
+	case metrics.exitCode == 137:
+		// SIGKILL that neither limit sent comes from the cgroup OOM killer.
+		result.Verdict = judging.MemoryLimitExceeded
+		result.Message = "killed by the sandbox memory limit"
+	case metrics.exitCode > 128:
+		result.Verdict = judging.RuntimeError
+		result.Message = fmt.Sprintf("killed by signal %d", metrics.exitCode-128)
+	case metrics.exitCode != 0:
+		result.Verdict = judging.RuntimeError
+		result.Message = fmt.Sprintf("exited with code %d", metrics.exitCode)
+	case metrics.outBytes > maxOutput:
+		result.Verdict = judging.WrongAnswer
+		result.Message = fmt.Sprintf("output limit of %d bytes exceeded", maxOutput)
+	default:
+		comparison, err := check(ctx, test, exec.Stdout)
+		if err != nil {
+			return result, err
+		}
+		result.Verdict = judging.WrongAnswer
+		if comparison.OK {
+			result.Verdict = judging.Accepted
+		}
+		result.FirstDiff = comparison.Diff
+		result.Message = comparison.Message
+		result.CheckerVerdict = comparison.CheckerVerdict
+	}
+	return result, nil
+}
+
+// parseRunMetrics splits the wrapper's metrics line off the program's
+// stderr.
+func parseRunMetrics(stderr string) (string, runMetrics, bool) {
+	index := strings.LastIndex(stderr, "\n"+metricsMarker+" ")
+	if index < 0 {
+		return stderr, runMetrics{}, false
+	}
+	fields := map[string]string{}
+	for _, field := range strings.Fields(stderr[index+len(metricsMarker)+2:]) {
+		key, value, _ := strings.Cut(field, "=")
+		fields[key] = value
+	}
+	number := func(key string) int64 {
+		value, _ := strconv.ParseInt(fields[key], 10, 64)
+		return value
+	}
+	exitCode, err := strconv.Atoi(fields["exit"])
+	if err != nil {
+		return stderr[:index], runMetrics{}, false
+	}
+	metrics := runMetrics{
+		peakKB:   number("peak_kb"),
+		exitCode: exitCode,
+		killed:   fields["killed"],
+		outBytes: number("out_bytes"),
+	}
+	// date without %N support leaves the fields unparsable; the caller
+	// then falls back to its own clock.
+	if start, end := number("start_ns"), number("end_ns"); start > 0 && end > start {
+		metrics.elapsed = time.Duration(end - start)
+	}
+	return stderr[:index], metrics, true
+}
+
+func capReportOutput(output string) string {
+	capped := newCappedBuffer(reportOutputBytes)
+	capped.Write([]byte(output))
+	return capped.String()
+}
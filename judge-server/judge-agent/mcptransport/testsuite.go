@@ -0,0 +1,147 @@
+package mcptransport
+
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"main/judge-agent/profiles"
+	"main/judge-agent/testreport"
+)
+
+const (
+	defaultTestSuiteTimeout = 10 * time.Minute
+	defaultTestReportBytes  = 4 << 20
+	maxTestReportFiles      = 64
+)
+
+// reportDirTemplate is where each test run gets a fresh report directory,
+// in the scratch space every sandbox has.
+const reportDirTemplate = "/tmp/judge-report.XXXXXXXXXX"
+
+// withReportDir runs its remaining arguments with JUDGE_REPORT_DIR set to
+// its first.
+const withReportDir = `JUDGE_REPORT_DIR="$1"; export JUDGE_REPORT_DIR; shift; exec "$@"`
+
+// listReportFiles prints the report files matching its argument, which is
+// left unquoted so the shell expands it as a glob.
+const listReportFiles = `for f in $1; do [ -f "$f" ] && printf '%s\n' "$f"; done; exit 0`
+
+// runTestSuite runs the profile's test command inside the running sandbox
+// name and parses the report files it leaves in a fresh report directory,
+// in whichever format the profile declares. The command's exit code is not
+// used: runners exit non-zero when a test fails, and the report says which.
+// A run that leaves no usable report is a failed report rather than an
+// error, since the submission usually caused it.
+func runTestSuite(ctx context.Context, sandbox Runtime, name string, profile profiles.Profile) (*testreport.Report, error) {
+	timeout := envDuration("MCP_TEST_SUITE_TIMEOUT", defaultTestSuiteTimeout)
+	execCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// A directory made afresh for this run holds the only reports read
+	// back, so no file the submission left behind passes for one.
+	made, err := sandbox.Exec(ctx, name, []string{"mktemp", "-d", reportDirTemplate}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create test report directory: %w", err)
+	}
+	reportDir := strings.TrimSpace(made.Stdout)
+	if made.ExitCode != 0 || !strings.HasPrefix(reportDir, "/tmp/judge-report.") || strings.ContainsAny(reportDir, " \t\n*?[") {
+		return nil, fmt.Errorf("create test report directory: mktemp exited %d: %s", made.ExitCode, strings.TrimSpace(made.Stderr+made.Stdout))
+	}
+	reportPath := path.Join(reportDir, profile.TestReportPath)
+
+	started := time.Now()
+	command := append([]string{"/bin/sh", "-c", withReportDir, "test", reportDir}, profile.TestCommand...)
+	run, err := sandbox.Exec(execCtx, name, command, nil)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if execCtx.Err() != nil {
+			return failedTestSuite(profile.TestReportFormat, fmt.Sprintf("test suite did not finish within %s", timeout)), nil
+		}
+		return nil, fmt.Errorf("run test suite: %w", err)
+	}
+	log.Printf("runTestSuite: %s test command exited %d after %s", name, run.ExitCode, time.Since(started).Round(time.Millisecond))
+
+	list, err := sandbox.Exec(ctx, name, []string{"/bin/sh", "-c", listReportFiles, "list", reportPath}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("find test reports: %w", err)
+	}
+	var files []string
+	for _, file := range strings.Split(list.Stdout, "\n") {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+	if len(files) == 0 {
+		output := strings.TrimSpace(run.Stderr)
+		if output == "" {
+			output = strings.TrimSpace(run.Stdout)
+		}
+		message := fmt.Sprintf("test command exited %d without writing %s", run.ExitCode, profile.TestReportPath)
+		if output != "" {
+			message += ": " + capReportOutput(output)
+		}
+		return failedTestSuite(profile.TestReportFormat, message), nil
+	}
+	if len(files) > maxTestReportFiles {
+		log.Printf("runTestSuite: %s wrote %d report files; reading the first %d", name, len(files), maxTestReportFiles)
+		files = files[:maxTestReportFiles]
+	}
+
+	// Runners report absolute paths; the learner knows them relative to
+	// their project.
+	workDir := strings.TrimSuffix(profile.WorkDir, "/") + "/"
+	report := &testreport.Report{Format: profile.TestReportFormat}
+	remaining := envInt64("MCP_TEST_REPORT_MAX_BYTES", defaultTestReportBytes)
+	for _, file := range files {
+		read, err := sandbox.Exec(ctx, name, []string{"head", "-c", strconv.FormatInt(remaining+1, 10), file}, nil)
+		if err != nil {
+			return nil, fmt.Errorf("read test report %s: %w", file, err)
+		}
+		if int64(len(read.Stdout)) > remaining {
+			return failedTestSuite(profile.TestReportFormat, "test reports are too large to read"), nil
+		}
+		remaining -= int64(len(read.Stdout))
+		parsed, err := testreport.Parse(profile.TestReportFormat, []byte(read.Stdout))
+		if err != nil {
+			return failedTestSuite(profile.TestReportFormat, fmt.Sprintf("%s: %v", strings.TrimPrefix(file, reportDir+"/"), err)), nil
+		}
+		for _, suite := range parsed.Suites {
+			if suite.Name == "" {
+				suite.Name = strings.TrimPrefix(file, reportDir+"/")
+			}
+			suite.Name = strings.TrimPrefix(suite.Name, workDir)
+			report.Suites = append(report.Suites, suite)
+		}
+	}
+	report.Summarize()
+	log.Printf("runTestSuite: %s passed %d of %d tests, %d failed, %d skipped",
+		name, report.Passed, report.Total, report.Failed, report.Skipped)
+	return report, nil
+}
+
+func failedTestSuite(format, message string) *testreport.Report {
+	report := &testreport.Report{Format: format, Error: message}
+	report.Summarize()
+	return report
+}
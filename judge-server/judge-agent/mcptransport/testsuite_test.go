@@ -0,0 +1,97 @@
+package mcptransport
+
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import (
+	"context"
+	"io"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+
+	"main/judge-agent/profiles"
+)
+
+const (
+	passingJestReport = `{"testResults":[{"name":"/app/src/App.test.js","status":"passed","assertionResults":[{"title":"renders","status":"passed"}]}]}`
+	failingJestReport = `{"testResults":[{"name":"/app/src/App.test.js","status":"failed","assertionResults":[{"title":"renders","status":"failed"}]}]}`
+)
+
+// reportSandbox answers runTestSuite's commands from an in-memory /tmp. The
+// submission has already planted a passing report where the test command
+// used to write one, and the tests themselves fail.
+type reportSandbox struct {
+	mu    sync.Mutex
+	files map[string]string
+	runs  int
+}
+
+func (s *reportSandbox) exec(_ context.Context, _ string, command []string, _ io.Reader) (ExecResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case command[0] == "mktemp":
+		s.runs++
+		return ExecResult{Stdout: strings.Replace(command[2], "XXXXXXXXXX", strings.Repeat("a", s.runs), 1) + "\n"}, nil
+	case command[0] == "/bin/sh" && command[2] == withReportDir:
+		reportDir := command[4]
+		if !strings.Contains(strings.Join(command[5:], " "), "$JUDGE_REPORT_DIR/jest-results.json") {
+			return ExecResult{ExitCode: 2, Stderr: "no report path"}, nil
+		}
+		s.files[path.Join(reportDir, "jest-results.json")] = failingJestReport
+		return ExecResult{ExitCode: 1}, nil
+	case command[0] == "/bin/sh" && command[2] == listReportFiles:
+		pattern := command[4]
+		var found []string
+		for name := range s.files {
+			if matched, _ := path.Match(pattern, name); matched {
+				found = append(found, name)
+			}
+		}
+		return ExecResult{Stdout: strings.Join(found, "\n")}, nil
+	case command[0] == "head":
+		return ExecResult{Stdout: s.files[command[3]]}, nil
+	}
+	return ExecResult{ExitCode: 127}, nil
+}
+
+func TestRunTestSuiteIgnoresPlantedReports(t *testing.T) {
+	ctx := context.Background()
+	react, err := profiles.Lookup("react")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sandbox := &reportSandbox{files: map[string]string{
+		"/tmp/jest-results.json":                passingJestReport,
+		"/tmp/judge-report.b/jest-results.json": passingJestReport,
+	}}
+	runtime := NewFakeRuntime()
+	runtime.ExecFunc = sandbox.exec
+	if _, err := runtime.Start(ctx, ContainerSpec{Name: "mcp-pod-1", Image: "fake.local/mcp-image-1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := runTestSuite(ctx, runtime, "mcp-pod-1", react)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Success || report.Failed != 1 || report.Total != 1 {
+		t.Fatalf("report = %+v, want the one failing test", report)
+	}
+	if got := report.Suites[0].Name; got != "src/App.test.js" {
+		t.Errorf("suite name = %q, want src/App.test.js", got)
+	}
+}
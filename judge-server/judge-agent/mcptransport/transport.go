@@ -21,15 +21,31 @@ import (
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
-// Local configures an in-memory MCP server with the sample weather tool.
+// Local configures an in-memory MCP server with every tool, including the
+// ones that read the server's own filesystem. It is only for the local CLI;
+// agents served to remote callers use Sandboxed.
 func Local(ctx context.Context) mcp.Transport {
+	return newLocalTransport(ctx, true)
+}
+
+// Sandboxed configures an in-memory MCP server with the tools that only act
+// on sandboxes and on what the caller sends. It leaves out list_local_paths
+// and read_local_file, which would expose problem packages and secrets on
+// the server's filesystem.
+func Sandboxed(ctx context.Context) mcp.Transport {
+	return newLocalTransport(ctx, false)
+}
+
+func newLocalTransport(ctx context.Context, filesystem bool) mcp.Transport {
 	clientTransport, serverTransport := mcp.NewInMemoryTransports()
 
 	server := mcp.NewServer(&mcp.Implementation{Name: "docker_server", Version: "v1.0.0"}, nil)
 	mcp.AddTool(server, &mcp.Tool{Name: "deploy_container", Description: "Deploys lightweight container based off the given docker compose"}, DeployContainer)
 	mcp.AddTool(server, &mcp.Tool{Name: "shutdown_container", Description: "Shuts down a container by ID or name"}, ShutdownContainer)
-	mcp.AddTool(server, &mcp.Tool{Name: "list_local_paths", Description: "Lists entries in a local filesystem directory"}, ListLocalPaths)
-	mcp.AddTool(server, &mcp.Tool{Name: "read_local_file", Description: "Reads a local file path with an optional byte limit"}, ReadLocalFile)
+	if filesystem {
+		mcp.AddTool(server, &mcp.Tool{Name: "list_local_paths", Description: "Lists entries in a local filesystem directory"}, ListLocalPaths)
+		mcp.AddTool(server, &mcp.Tool{Name: "read_local_file", Description: "Reads a local file path with an optional byte limit"}, ReadLocalFile)
+	}
 	mcp.AddTool(server, &mcp.Tool{Name: "print_tar_contents", Description: "Decodes the Base64TarBytes and prints the contents of the archive"}, ReadTarArchive)
 	_, err := server.Connect(ctx, serverTransport, nil)
 	if err != nil {
@@ -0,0 +1,60 @@
+package mcptransport
+
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func toolNames(t *testing.T, transport mcp.Transport) []string {
+	t.Helper()
+	ctx := context.Background()
+	session, err := mcp.NewClient(&mcp.Implementation{Name: "test"}, nil).Connect(ctx, transport, nil)
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer session.Close()
+	result, err := session.ListTools(ctx, nil)
+	if err != nil {
+		t.Fatalf("ListTools: %v", err)
+	}
+	var names []string
+	for _, tool := range result.Tools {
+		names = append(names, tool.Name)
+	}
+	return names
+}
+
+func TestSandboxedHasNoFilesystemTools(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sandboxed := toolNames(t, Sandboxed(ctx))
+	for _, name := range []string{"list_local_paths", "read_local_file"} {
+		if slices.Contains(sandboxed, name) {
+			t.Errorf("Sandboxed exposes %s", name)
+		}
+	}
+	if !slices.Contains(sandboxed, "deploy_container") {
+		t.Errorf("Sandboxed tools = %v, want deploy_container", sandboxed)
+	}
+	if local := toolNames(t, Local(ctx)); !slices.Contains(local, "read_local_file") {
+		t.Errorf("Local tools = %v, want read_local_file", local)
+	}
+}
@@ -0,0 +1,362 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"google.golang.org/adk/model"
+	"google.golang.org/genai"
+)
+
+// ErrNotRecorded is returned by a replaying model for a request its
+// cassette has no unused recording of.
+var ErrNotRecorded = errors.New("request not recorded")
+
+// Interaction is one recorded model call: the normalized request and
+// everything the model yielded for it.
+type Interaction struct {
+	Request   json.RawMessage      `json:"request"`
+	Responses []*model.LLMResponse `json:"responses,omitempty"`
+	Error     string               `json:"error,omitempty"`
+	// Unavailable keeps whether Error would make a fallback chain move on.
+	Unavailable bool `json:"unavailable,omitempty"`
+}
+
+// Cassette is the file format of recorded interactions, in call order.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// LoadCassette reads a cassette file.
+func LoadCassette(path string) (*Cassette, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read cassette %q: %w", path, err)
+	}
+	var cassette Cassette
+	if err := json.Unmarshal(contents, &cassette); err != nil {
+		return nil, fmt.Errorf("parse cassette %q: %w", path, err)
+	}
+	for i, interaction := range cassette.Interactions {
+		// Reindent so keys match whatever formatting the file was saved in.
+		key, err := canonicalJSON(interaction.Request)
+		if err != nil {
+			return nil, fmt.Errorf("cassette %q: interaction %d: %w", path, i+1, err)
+		}
+		cassette.Interactions[i].Request = key
+	}
+	return &cassette, nil
+}
+
+// recorder is the cassette file shared by every model recording to a path.
+type recorder struct {
+	path string
+
+	mu       sync.Mutex
+	cassette Cassette
+}
+
+var (
+	recordersMu sync.Mutex
+	recorders   = map[string]*recorder{}
+)
+
+// recorderFor returns the recorder for path. The first model to record to
+// a path in a process starts the cassette over.
+func recorderFor(path string) *recorder {
+	recordersMu.Lock()
+	defer recordersMu.Unlock()
+	path = filepath.Clean(path)
+	r, ok := recorders[path]
+	if !ok {
+		r = &recorder{path: path}
+		recorders[path] = r
+	}
+	return r
+}
+
+// save appends an interaction and rewrites the file, so a run that dies
+// midway keeps what it recorded.
+func (r *recorder) save(interaction Interaction) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cassette.Interactions = append(r.cassette.Interactions, interaction)
+	contents, err := json.MarshalIndent(r.cassette, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(r.path), filepath.Base(r.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(contents, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), r.path)
+}
+
+// recordingModel passes calls through to its model and records each one.
+type recordingModel struct {
+	model.LLM
+	recorder *recorder
+}
+
+// NewRecorder wraps llm so every call is appended to the cassette at path.
+func NewRecorder(llm model.LLM, path string) model.LLM {
+	return &recordingModel{LLM: llm, recorder: recorderFor(path)}
+}
+
+func (m *recordingModel) GenerateContent(ctx context.Context, req *model.LLMRequest, stream bool) iter.Seq2[*model.LLMResponse, error] {
+	return func(yield func(*model.LLMResponse, error) bool) {
+		key, keyErr := normalizeRequest(req)
+		var interaction Interaction
+		for resp, err := range m.LLM.GenerateContent(ctx, req, stream) {
+			if err != nil {
+				interaction.Error = err.Error()
+				interaction.Unavailable = Unavailable(err)
+			} else if resp != nil {
+				interaction.Responses = append(interaction.Responses, stripCallIDs(resp))
+			}
+			if !yield(resp, err) {
+				break
+			}
+		}
+		if keyErr != nil {
+			log.Printf("models: not recording a call to %s: %v", m.Name(), keyErr)
+			return
+		}
+		interaction.Request = key
+		if err := m.recorder.save(interaction); err != nil {
+			log.Printf("models: record to %s: %v", m.recorder.path, err)
+		}
+	}
+}
+
+// replayModel answers from a cassette. Each recording is used once, in
+// order, so a conversation that repeats a request gets each recorded reply
+// in turn.
+type replayModel struct {
+	name string
+	path string
+
+	mu       sync.Mutex
+	cassette *Cassette
+	used     []bool
+}
+
+// NewReplayer returns a model named name that replays the cassette at path.
+func NewReplayer(name, path string) (model.LLM, error) {
+	cassette, err := LoadCassette(path)
+	if err != nil {
+		return nil, err
+	}
+	return &replayModel{
+		name:     name,
+		path:     path,
+		cassette: cassette,
+		used:     make([]bool, len(cassette.Interactions)),
+	}, nil
+}
+
+func newReplay(_ context.Context, spec Spec) (model.LLM, error) {
+	return NewReplayer(spec.Name, spec.Cassette)
+}
+
+func (m *replayModel) Name() string {
+	return m.name
+}
+
+func (m *replayModel) GenerateContent(_ context.Context, req *model.LLMRequest, _ bool) iter.Seq2[*model.LLMResponse, error] {
+	return func(yield func(*model.LLMResponse, error) bool) {
+		interaction, err := m.next(req)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		for _, resp := range interaction.Responses {
+			if !yield(resp, nil) {
+				return
+			}
+		}
+		if interaction.Error != "" {
+			err := errors.New(interaction.Error)
+			if interaction.Unavailable {
+				err = fmt.Errorf("%w: %s", ErrUnavailable, interaction.Error)
+			}
+			yield(nil, err)
+		}
+	}
+}
+
+func (m *replayModel) next(req *model.LLMRequest) (Interaction, error) {
+	key, err := normalizeRequest(req)
+	if err != nil {
+		return Interaction{}, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, interaction := range m.cassette.Interactions {
+		if !m.used[i] && string(interaction.Request) == string(key) {
+			m.used[i] = true
+			return cloneInteraction(interaction)
+		}
+	}
+	return Interaction{}, fmt.Errorf("%w in %s: %s", ErrNotRecorded, m.path, describeRequest(req))
+}
+
+// cloneInteraction copies the responses so callers may modify them.
+func cloneInteraction(interaction Interaction) (Interaction, error) {
+	encoded, err := json.Marshal(interaction.Responses)
+	if err != nil {
+		return Interaction{}, err
+	}
+	interaction.Responses = nil
+	if err := json.Unmarshal(encoded, &interaction.Responses); err != nil {
+		return Interaction{}, err
+	}
+	return interaction, nil
+}
+
+// normalizedRequest is what a recording is matched on. The model name is
+// left out so a cassette replays under any provider, and so are call IDs,
+// which ADK generates afresh on every run.
+type normalizedRequest struct {
+	Contents []*genai.Content             `json:"contents"`
+	Config   *genai.GenerateContentConfig `json:"config,omitempty"`
+}
+
+func normalizeRequest(req *model.LLMRequest) (json.RawMessage, error) {
+	encoded, err := json.Marshal(normalizedRequest{Contents: req.Contents, Config: req.Config})
+	if err != nil {
+		return nil, fmt.Errorf("encode request: %w", err)
+	}
+	// Work on a copy decoded from JSON, leaving the caller's request alone.
+	var normalized normalizedRequest
+	if err := json.Unmarshal(encoded, &normalized); err != nil {
+		return nil, fmt.Errorf("decode request: %w", err)
+	}
+	for _, content := range normalized.Contents {
+		if content == nil {
+			continue
+		}
+		content.Parts = slices.DeleteFunc(content.Parts, func(part *genai.Part) bool { return part == nil || part.Thought })
+		for _, part := range content.Parts {
+			part.ThoughtSignature = nil
+			if part.FunctionCall != nil {
+				part.FunctionCall.ID = ""
+			}
+			if part.FunctionResponse != nil {
+				part.FunctionResponse.ID = ""
+			}
+		}
+	}
+	if config := normalized.Config; config != nil {
+		config.HTTPOptions = nil
+		config.Labels = nil
+		for _, tool := range config.Tools {
+			if tool != nil {
+				slices.SortFunc(tool.FunctionDeclarations, func(a, b *genai.FunctionDeclaration) int {
+					return strings.Compare(a.Name, b.Name)
+				})
+			}
+		}
+	}
+	encoded, err = json.Marshal(normalized)
+	if err != nil {
+		return nil, fmt.Errorf("encode request: %w", err)
+	}
+	return canonicalJSON(encoded)
+}
+
+// canonicalJSON reencodes data with sorted keys and no insignificant space.
+func canonicalJSON(data []byte) (json.RawMessage, error) {
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+	return json.Marshal(value)
+}
+
+// stripCallIDs copies resp without function call IDs, which replay leaves
+// for ADK to generate so they never clash with IDs of the running session.
+func stripCallIDs(resp *model.LLMResponse) *model.LLMResponse {
+	if resp.Content == nil || !hasFunctionCall(resp.Content) {
+		return resp
+	}
+	stripped := *resp
+	content := *resp.Content
+	content.Parts = make([]*genai.Part, len(resp.Content.Parts))
+	for i, part := range resp.Content.Parts {
+		if part == nil || part.FunctionCall == nil {
+			content.Parts[i] = part
+			continue
+		}
+		copied := *part
+		call := *part.FunctionCall
+		call.ID = ""
+		copied.FunctionCall = &call
+		content.Parts[i] = &copied
+	}
+	stripped.Content = &content
+	return &stripped
+}
+
+func hasFunctionCall(content *genai.Content) bool {
+	return slices.ContainsFunc(content.Parts, func(part *genai.Part) bool { return part != nil && part.FunctionCall != nil })
+}
+
+// describeRequest summarizes a request for a replay miss: its last message.
+func describeRequest(req *model.LLMRequest) string {
+	if len(req.Contents) == 0 || req.Contents[len(req.Contents)-1] == nil {
+		return "empty request"
+	}
+	last := req.Contents[len(req.Contents)-1]
+	var parts []string
+	for _, part := range last.Parts {
+		switch {
+		case part == nil:
+		case part.Text != "":
+			text := part.Text
+			if len(text) > 80 {
+				text = text[:80] + "..."
+			}
+			parts = append(parts, fmt.Sprintf("%q", text))
+		case part.FunctionCall != nil:
+			parts = append(parts, "call "+part.FunctionCall.Name)
+		case part.FunctionResponse != nil:
+			parts = append(parts, "result of "+part.FunctionResponse.Name)
+		}
+	}
+	return fmt.Sprintf("%d messages, last from %s: %s", len(req.Contents), last.Role, strings.Join(parts, ", "))
+}
@@ -0,0 +1,109 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/adk/model"
+	"google.golang.org/genai"
+)
+
+func generate(llm model.LLM, req *model.LLMRequest) ([]*model.LLMResponse, error) {
+	var responses []*model.LLMResponse
+	for resp, err := range llm.GenerateContent(context.Background(), req, false) {
+		if err != nil {
+			return responses, err
+		}
+		responses = append(responses, resp)
+	}
+	return responses, nil
+}
+
+func TestCassetteRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	ask := func(callID string) []*model.LLMRequest {
+		return []*model.LLMRequest{
+			{
+				Model:    "gemini-2.5-flash",
+				Contents: []*genai.Content{genai.NewContentFromText("deploy my app", genai.RoleUser)},
+			},
+			{
+				Model: "gemini-2.5-flash",
+				Contents: []*genai.Content{
+					genai.NewContentFromText("deploy my app", genai.RoleUser),
+					{Role: genai.RoleModel, Parts: []*genai.Part{{FunctionCall: &genai.FunctionCall{ID: callID, Name: "deploy_container", Args: map[string]any{"profile": "react"}}}}},
+					{Role: genai.RoleUser, Parts: []*genai.Part{{FunctionResponse: &genai.FunctionResponse{ID: callID, Name: "deploy_container", Response: map[string]any{"endpoint": "http://sandbox"}}}}},
+				},
+			},
+			{
+				Model:    "gemini-2.5-flash",
+				Contents: []*genai.Content{genai.NewContentFromText("and again", genai.RoleUser)},
+			},
+		}
+	}
+	fake := &FakeModel{Script: []FakeTurn{
+		{FunctionCalls: []*genai.FunctionCall{{ID: "call-1", Name: "deploy_container", Args: map[string]any{"profile": "react"}}}},
+		{Text: "Deployed at http://sandbox."},
+		{Err: fmt.Errorf("%w: quota exceeded", ErrUnavailable)},
+	}}
+
+	recorder := NewRecorder(fake, path)
+	var recorded [][]*model.LLMResponse
+	for i, req := range ask("call-1") {
+		responses, err := generate(recorder, req)
+		if (err != nil) != (i == 2) {
+			t.Fatalf("recording call %d: err = %v", i+1, err)
+		}
+		recorded = append(recorded, responses)
+	}
+
+	replayer, err := NewReplayer("replay", path)
+	if err != nil {
+		t.Fatalf("NewReplayer: %v", err)
+	}
+	// ADK makes new call IDs on every run; replay must not depend on them.
+	for i, req := range ask("call-2") {
+		responses, err := generate(replayer, req)
+		if i == 2 {
+			if !Unavailable(err) {
+				t.Errorf("replayed call 3: err = %v, want the recorded unavailable error", err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("replayed call %d: %v", i+1, err)
+		}
+		if len(responses) != len(recorded[i]) {
+			t.Fatalf("replayed call %d: %d responses, want %d", i+1, len(responses), len(recorded[i]))
+		}
+		got, want := responses[0].Content.Parts[0], recorded[i][0].Content.Parts[0]
+		if got.Text != want.Text || (got.FunctionCall == nil) != (want.FunctionCall == nil) {
+			t.Errorf("replayed call %d: part %+v, want %+v", i+1, got, want)
+		}
+		if got.FunctionCall != nil && (got.FunctionCall.ID != "" || got.FunctionCall.Name != "deploy_container") {
+			t.Errorf("replayed call %d: function call %+v, want deploy_container without an ID", i+1, got.FunctionCall)
+		}
+	}
+
+	// Each recording answers once.
+	if _, err := generate(replayer, ask("call-3")[0]); !errors.Is(err, ErrNotRecorded) {
+		t.Errorf("repeated request: err = %v, want ErrNotRecorded", err)
+	}
+}
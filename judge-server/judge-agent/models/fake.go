@@ -0,0 +1,96 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"sync"
+
+	"google.golang.org/adk/model"
+	"google.golang.org/genai"
+)
+
+// FakeTurn is one scripted model reply: Text and FunctionCalls make up the
+// model's content, Response replaces the reply outright, and Err fails the
+// call instead.
+type FakeTurn struct {
+	Text          string
+	FunctionCalls []*genai.FunctionCall
+	Response      *model.LLMResponse
+	Err           error
+}
+
+// FakeModel is an in-memory model.LLM that answers each call with the next
+// turn of Script and records the requests it receives. A call past the end
+// of Script fails.
+type FakeModel struct {
+	Script []FakeTurn
+	// ModelName is what Name reports, "fake" when empty.
+	ModelName string
+
+	mu       sync.Mutex
+	requests []*model.LLMRequest
+}
+
+func (f *FakeModel) Name() string {
+	if f.ModelName == "" {
+		return "fake"
+	}
+	return f.ModelName
+}
+
+func (f *FakeModel) GenerateContent(ctx context.Context, req *model.LLMRequest, _ bool) iter.Seq2[*model.LLMResponse, error] {
+	return func(yield func(*model.LLMResponse, error) bool) {
+		f.mu.Lock()
+		turn := len(f.requests)
+		f.requests = append(f.requests, req)
+		f.mu.Unlock()
+
+		if err := ctx.Err(); err != nil {
+			yield(nil, err)
+			return
+		}
+		if turn >= len(f.Script) {
+			yield(nil, fmt.Errorf("fake model: call %d but only %d turns are scripted", turn+1, len(f.Script)))
+			return
+		}
+		step := f.Script[turn]
+		switch {
+		case step.Err != nil:
+			yield(nil, step.Err)
+		case step.Response != nil:
+			yield(step.Response, nil)
+		default:
+			content := &genai.Content{Role: genai.RoleModel}
+			if step.Text != "" {
+				content.Parts = append(content.Parts, genai.NewPartFromText(step.Text))
+			}
+			for _, call := range step.FunctionCalls {
+				copied := *call
+				content.Parts = append(content.Parts, &genai.Part{FunctionCall: &copied})
+			}
+			yield(&model.LLMResponse{Content: content, TurnComplete: true, FinishReason: genai.FinishReasonStop}, nil)
+		}
+	}
+}
+
+// Requests returns the requests received so far.
+func (f *FakeModel) Requests() []*model.LLMRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]*model.LLMRequest(nil), f.requests...)
+}
@@ -0,0 +1,135 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"google.golang.org/adk/model"
+	"google.golang.org/genai"
+)
+
+// ErrUnavailable marks provider errors that another model may not share:
+// exhausted quota, overload or an outage. Providers other than Gemini wrap
+// it so the fallback chain recognizes their errors.
+var ErrUnavailable = errors.New("model unavailable")
+
+// Unavailable reports whether err means the model could not serve the
+// request right now, rather than that the request was bad.
+func Unavailable(err error) bool {
+	if errors.Is(err, ErrUnavailable) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var apiErr genai.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.Code {
+		case http.StatusTooManyRequests, http.StatusInternalServerError,
+			http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+	}
+	return false
+}
+
+// configuredModel applies a Spec's parameters and timeout to every call.
+type configuredModel struct {
+	model.LLM
+	spec    Spec
+	timeout time.Duration
+}
+
+func (m *configuredModel) GenerateContent(ctx context.Context, req *model.LLMRequest, stream bool) iter.Seq2[*model.LLMResponse, error] {
+	return func(yield func(*model.LLMResponse, error) bool) {
+		if m.timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, m.timeout)
+			defer cancel()
+		}
+		for resp, err := range m.LLM.GenerateContent(ctx, m.apply(req), stream) {
+			if !yield(resp, err) {
+				return
+			}
+		}
+	}
+}
+
+// apply returns a copy of req with the spec's parameters filled in where
+// the agent left them unset.
+func (m *configuredModel) apply(req *model.LLMRequest) *model.LLMRequest {
+	applied := *req
+	applied.Model = m.spec.Name
+	applied.Contents = slices.Clone(req.Contents)
+	config := genai.GenerateContentConfig{}
+	if req.Config != nil {
+		config = *req.Config
+	}
+	if config.Temperature == nil && m.spec.Temperature != nil {
+		temperature := *m.spec.Temperature
+		config.Temperature = &temperature
+	}
+	if config.MaxOutputTokens == 0 {
+		config.MaxOutputTokens = m.spec.MaxOutputTokens
+	}
+	if len(config.SafetySettings) == 0 {
+		for _, setting := range m.spec.SafetySettings {
+			config.SafetySettings = append(config.SafetySettings, &genai.SafetySetting{
+				Category:  genai.HarmCategory(setting.Category),
+				Threshold: genai.HarmBlockThreshold(setting.Threshold),
+			})
+		}
+	}
+	applied.Config = &config
+	return &applied
+}
+
+// fallbackModel tries its models in order, moving on only while the
+// current one is unavailable and has not produced any output yet. The last
+// model's error is returned as is.
+type fallbackModel struct {
+	models []model.LLM
+}
+
+func (m *fallbackModel) Name() string {
+	return m.models[0].Name()
+}
+
+func (m *fallbackModel) GenerateContent(ctx context.Context, req *model.LLMRequest, stream bool) iter.Seq2[*model.LLMResponse, error] {
+	return func(yield func(*model.LLMResponse, error) bool) {
+		for i, llm := range m.models {
+			produced := false
+			var unavailable error
+			for resp, err := range llm.GenerateContent(ctx, req, stream) {
+				if err != nil && !produced && ctx.Err() == nil && Unavailable(err) && i < len(m.models)-1 {
+					unavailable = err
+					break
+				}
+				produced = true
+				if !yield(resp, err) {
+					return
+				}
+			}
+			if unavailable == nil {
+				return
+			}
+			log.Printf("models: %s unavailable, falling back to %s: %v", llm.Name(), m.models[i+1].Name(), unavailable)
+		}
+	}
+}
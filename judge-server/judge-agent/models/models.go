@@ -0,0 +1,343 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package models builds the LLM each agent talks to from configuration:
+// which provider and model, its generation parameters, and the models to
+// fall back to when the primary is out of quota or unavailable. Besides
+// Gemini, any OpenAI-compatible chat completions server (vLLM, llama.cpp,
+// Ollama) can serve an agent through the openai provider.
+//
+// For deterministic runs, a spec with a cassette records every call it
+// makes, and the replay provider later answers the same requests from that
+// cassette without a model. Tests with hand-written replies instead install
+// a FakeModel for an agent with UseModel.
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/adk/model"
+	"google.golang.org/adk/model/gemini"
+	"google.golang.org/genai"
+)
+
+// Providers.
+const (
+	ProviderGemini = "gemini"
+	// ProviderOpenAI serves models behind an OpenAI-compatible chat
+	// completions endpoint at BaseURL.
+	ProviderOpenAI = "openai"
+	// ProviderReplay answers from the cassette a recording run saved, with
+	// no model behind it.
+	ProviderReplay = "replay"
+)
+
+const (
+	defaultModel     = "gemini-2.5-flash"
+	defaultAPIKeyEnv = "GOOGLE_API_KEY"
+	openAIAPIKeyEnv  = "OPENAI_API_KEY"
+)
+
+// SafetySetting blocks one harm category at a threshold, both named as in
+// the Gemini API, e.g. HARM_CATEGORY_HARASSMENT and BLOCK_ONLY_HIGH.
+type SafetySetting struct {
+	Category  string `json:"category"`
+	Threshold string `json:"threshold"`
+}
+
+// Spec is one model an agent can use. Parameters left unset keep the
+// provider's defaults, and never override what the agent sets itself.
+type Spec struct {
+	Provider string `json:"provider,omitempty"`
+	Name     string `json:"name"`
+	// APIKeyEnv names the environment variable holding the API key. Servers
+	// of the openai provider that need no key may leave it unset.
+	APIKeyEnv string `json:"api_key_env,omitempty"`
+	// BaseURL is the API root of the openai provider, e.g.
+	// http://vllm:8000/v1; requests go to BaseURL/chat/completions.
+	BaseURL         string          `json:"base_url,omitempty"`
+	Temperature     *float32        `json:"temperature,omitempty"`
+	MaxOutputTokens int32           `json:"max_output_tokens,omitempty"`
+	SafetySettings  []SafetySetting `json:"safety_settings,omitempty"`
+	// Timeout bounds a single call, as a Go duration; a call that runs out
+	// of time falls back like an unavailable model.
+	Timeout string `json:"timeout,omitempty"`
+	// Cassette is the file the replay provider answers from. For any other
+	// provider, every call is recorded to it for later replay.
+	Cassette string `json:"cassette,omitempty"`
+}
+
+// Chain is a primary model followed by its fallbacks, in order.
+type Chain []Spec
+
+// Config picks a chain per agent name, or Default for agents not listed.
+type Config struct {
+	Default Chain            `json:"default"`
+	Agents  map[string]Chain `json:"agents,omitempty"`
+}
+
+// DefaultConfig is used when no model config is set: Gemini 2.5 Flash with
+// its own defaults for every agent.
+func DefaultConfig() *Config {
+	return &Config{Default: Chain{{Provider: ProviderGemini, Name: defaultModel}}}
+}
+
+// LoadFile reads a JSON model config.
+func LoadFile(path string) (*Config, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read model config %q: %w", path, err)
+	}
+	config := DefaultConfig()
+	if err := json.Unmarshal(contents, config); err != nil {
+		return nil, fmt.Errorf("parse model config %q: %w", path, err)
+	}
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("model config %q: %w", path, err)
+	}
+	return config, nil
+}
+
+var (
+	activeOnce   sync.Once
+	activeConfig *Config
+	activeErr    error
+)
+
+// Active returns the config named by JUDGE_MODEL_CONFIG, or DefaultConfig
+// when the variable is unset. A file that cannot be loaded is an error
+// rather than a quiet fallback to another provider.
+func Active() (*Config, error) {
+	activeOnce.Do(func() {
+		path := strings.TrimSpace(os.Getenv("JUDGE_MODEL_CONFIG"))
+		if path == "" {
+			activeConfig = DefaultConfig()
+			return
+		}
+		activeConfig, activeErr = LoadFile(path)
+	})
+	return activeConfig, activeErr
+}
+
+// Validate rejects configs a model could not be built from.
+func (c *Config) Validate() error {
+	if len(c.Default) == 0 {
+		return fmt.Errorf("default chain needs at least one model")
+	}
+	if err := c.Default.validate(); err != nil {
+		return fmt.Errorf("default: %w", err)
+	}
+	for agent, chain := range c.Agents {
+		if len(chain) == 0 {
+			return fmt.Errorf("agent %s: chain needs at least one model", agent)
+		}
+		if err := chain.validate(); err != nil {
+			return fmt.Errorf("agent %s: %w", agent, err)
+		}
+	}
+	return nil
+}
+
+func (c Chain) validate() error {
+	for i, spec := range c {
+		if err := spec.validate(); err != nil {
+			return fmt.Errorf("model %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+func (s Spec) validate() error {
+	if strings.TrimSpace(s.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+	if _, ok := lookupProvider(s.provider()); !ok {
+		return fmt.Errorf("unknown provider %q", s.Provider)
+	}
+	if s.provider() == ProviderReplay && strings.TrimSpace(s.Cassette) == "" {
+		return fmt.Errorf("replay provider needs a cassette")
+	}
+	if s.provider() == ProviderOpenAI {
+		if u, err := url.Parse(s.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("openai provider needs an http(s) base_url")
+		}
+	}
+	if s.Temperature != nil && (*s.Temperature < 0 || *s.Temperature > 2) {
+		return fmt.Errorf("temperature must be between 0 and 2")
+	}
+	if s.MaxOutputTokens < 0 {
+		return fmt.Errorf("max_output_tokens must not be negative")
+	}
+	for _, setting := range s.SafetySettings {
+		if setting.Category == "" || setting.Threshold == "" {
+			return fmt.Errorf("safety settings need a category and a threshold")
+		}
+	}
+	if _, err := s.timeout(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s Spec) provider() string {
+	if s.Provider == "" {
+		return ProviderGemini
+	}
+	return strings.ToLower(s.Provider)
+}
+
+func (s Spec) apiKey() string {
+	name := s.APIKeyEnv
+	if name == "" {
+		name = defaultAPIKeyEnv
+		if s.provider() == ProviderOpenAI {
+			name = openAIAPIKeyEnv
+		}
+	}
+	return os.Getenv(name)
+}
+
+func (s Spec) timeout() (time.Duration, error) {
+	if s.Timeout == "" {
+		return 0, nil
+	}
+	timeout, err := time.ParseDuration(s.Timeout)
+	if err != nil || timeout < 0 {
+		return 0, fmt.Errorf("invalid timeout %q", s.Timeout)
+	}
+	return timeout, nil
+}
+
+// Chain returns the chain configured for agent.
+func (c *Config) Chain(agent string) Chain {
+	if chain, ok := c.Agents[agent]; ok {
+		return chain
+	}
+	return c.Default
+}
+
+// New builds the model for agent from its chain. A chain of more than one
+// model falls back through them in order.
+func (c *Config) New(ctx context.Context, agent string) (model.LLM, error) {
+	chain := c.Chain(agent)
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("no model configured for agent %s", agent)
+	}
+	built := make([]model.LLM, 0, len(chain))
+	for _, spec := range chain {
+		llm, err := build(ctx, spec)
+		if err != nil {
+			return nil, fmt.Errorf("agent %s: model %s: %w", agent, spec.Name, err)
+		}
+		built = append(built, llm)
+	}
+	if len(built) == 1 {
+		return built[0], nil
+	}
+	return &fallbackModel{models: built}, nil
+}
+
+var (
+	overridesMu sync.Mutex
+	overrides   = map[string]model.LLM{}
+)
+
+// UseModel makes New return llm for agent instead of building it from the
+// config, e.g. a FakeModel in an offline test; a nil llm undoes it.
+func UseModel(agent string, llm model.LLM) {
+	overridesMu.Lock()
+	defer overridesMu.Unlock()
+	if llm == nil {
+		delete(overrides, agent)
+		return
+	}
+	overrides[agent] = llm
+}
+
+// New builds the model for agent from the Active config, unless UseModel
+// set one.
+func New(ctx context.Context, agent string) (model.LLM, error) {
+	overridesMu.Lock()
+	llm, ok := overrides[agent]
+	overridesMu.Unlock()
+	if ok {
+		return llm, nil
+	}
+	config, err := Active()
+	if err != nil {
+		return nil, err
+	}
+	return config.New(ctx, agent)
+}
+
+// Provider builds a provider's model for spec.
+type Provider func(ctx context.Context, spec Spec) (model.LLM, error)
+
+var (
+	providersMu sync.RWMutex
+	providers   = map[string]Provider{
+		ProviderGemini: newGemini,
+		ProviderOpenAI: newOpenAI,
+		ProviderReplay: newReplay,
+	}
+)
+
+// RegisterProvider adds or replaces a provider.
+func RegisterProvider(name string, provider Provider) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	providers[strings.ToLower(name)] = provider
+}
+
+func lookupProvider(name string) (Provider, bool) {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	provider, ok := providers[name]
+	return provider, ok
+}
+
+func build(ctx context.Context, spec Spec) (model.LLM, error) {
+	if err := spec.validate(); err != nil {
+		return nil, err
+	}
+	provider, _ := lookupProvider(spec.provider())
+	llm, err := provider(ctx, spec)
+	if err != nil {
+		return nil, err
+	}
+	if spec.provider() == ProviderReplay {
+		// Replay must see requests as the agent sent them, which is how
+		// they were recorded.
+		return llm, nil
+	}
+	timeout, _ := spec.timeout()
+	llm = &configuredModel{LLM: llm, spec: spec, timeout: timeout}
+	if spec.Cassette != "" {
+		llm = NewRecorder(llm, spec.Cassette)
+	}
+	return llm, nil
+}
+
+func newGemini(ctx context.Context, spec Spec) (model.LLM, error) {
+	return gemini.NewModel(ctx, spec.Name, &genai.ClientConfig{
+		APIKey: spec.apiKey(),
+	})
+}
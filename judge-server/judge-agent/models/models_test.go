@@ -0,0 +1,50 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadFile(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		ok     bool
+	}{
+		{"gemini", `{"default": [{"name": "gemini-2.5-flash"}]}`, true},
+		{"openai", `{"default": [{"provider": "openai", "name": "gpt-4o", "base_url": "https://api.openai.com/v1"}]}`, true},
+		{"not json", `{"default": [`, false},
+		{"unknown provider", `{"default": [{"provider": "nope", "name": "x"}]}`, false},
+		{"openai without base_url", `{"default": [{"provider": "openai", "name": "gpt-4o"}]}`, false},
+		{"replay without cassette", `{"default": [{"provider": "replay", "name": "x"}]}`, false},
+		{"empty agent chain", `{"default": [{"name": "gemini-2.5-flash"}], "agents": {"docker_agent": []}}`, false},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "models.json")
+		if err := os.WriteFile(path, []byte(tt.config), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := LoadFile(path)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: LoadFile() error = %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+	if _, err := LoadFile(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Errorf("missing file: LoadFile() succeeded")
+	}
+}
@@ -0,0 +1,376 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"strings"
+
+	"google.golang.org/adk/model"
+	"google.golang.org/genai"
+)
+
+// openAIErrorBytes caps how much of an error body ends up in the error.
+const openAIErrorBytes = 4 << 10
+
+// openAIModel is a model.LLM for servers with an OpenAI-compatible
+// /chat/completions endpoint, such as vLLM, llama.cpp or Ollama. It does
+// not stream: a streaming call gets the whole reply as one response.
+type openAIModel struct {
+	name     string
+	endpoint string
+	apiKey   string
+	client   *http.Client
+}
+
+func newOpenAI(_ context.Context, spec Spec) (model.LLM, error) {
+	return &openAIModel{
+		name:     spec.Name,
+		endpoint: strings.TrimSuffix(spec.BaseURL, "/") + "/chat/completions",
+		apiKey:   spec.apiKey(),
+		client:   &http.Client{},
+	}, nil
+}
+
+func (m *openAIModel) Name() string {
+	return m.name
+}
+
+func (m *openAIModel) GenerateContent(ctx context.Context, req *model.LLMRequest, _ bool) iter.Seq2[*model.LLMResponse, error] {
+	return func(yield func(*model.LLMResponse, error) bool) {
+		yield(m.generate(ctx, req))
+	}
+}
+
+func (m *openAIModel) generate(ctx context.Context, req *model.LLMRequest) (*model.LLMResponse, error) {
+	body, err := toOpenAIRequest(m.name, req)
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("encode chat request: %w", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, m.endpoint, bytes.NewReader(encoded))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if m.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+m.apiKey)
+	}
+	resp, err := m.client.Do(httpReq)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("%w: call %s: %v", ErrUnavailable, m.endpoint, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, openAIErrorBytes))
+		err := fmt.Errorf("chat completions returned %s: %s", resp.Status, strings.TrimSpace(string(message)))
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError {
+			err = fmt.Errorf("%w: %v", ErrUnavailable, err)
+		}
+		return nil, err
+	}
+	var completion openAIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&completion); err != nil {
+		return nil, fmt.Errorf("decode chat response: %w", err)
+	}
+	return fromOpenAIResponse(completion)
+}
+
+type openAIRequest struct {
+	Model          string          `json:"model"`
+	Messages       []openAIMessage `json:"messages"`
+	Tools          []openAITool    `json:"tools,omitempty"`
+	Temperature    *float32        `json:"temperature,omitempty"`
+	TopP           *float32        `json:"top_p,omitempty"`
+	MaxTokens      int32           `json:"max_tokens,omitempty"`
+	Stop           []string        `json:"stop,omitempty"`
+	Seed           *int32          `json:"seed,omitempty"`
+	ResponseFormat map[string]any  `json:"response_format,omitempty"`
+}
+
+type openAIMessage struct {
+	Role       string           `json:"role"`
+	Content    *string          `json:"content"`
+	ToolCalls  []openAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+	Name       string           `json:"name,omitempty"`
+}
+
+type openAITool struct {
+	Type     string             `json:"type"`
+	Function openAIFunctionDecl `json:"function"`
+}
+
+type openAIFunctionDecl struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Parameters  any    `json:"parameters,omitempty"`
+}
+
+type openAIToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+type openAIResponse struct {
+	Choices []struct {
+		Message      openAIMessage `json:"message"`
+		FinishReason string        `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *struct {
+		PromptTokens     int32 `json:"prompt_tokens"`
+		CompletionTokens int32 `json:"completion_tokens"`
+		TotalTokens      int32 `json:"total_tokens"`
+	} `json:"usage"`
+}
+
+// toOpenAIRequest translates an ADK request. Function calls and responses
+// are matched by ID; calls without one get an ID from their position so
+// the following response can refer to it.
+func toOpenAIRequest(name string, req *model.LLMRequest) (*openAIRequest, error) {
+	body := &openAIRequest{Model: name}
+	config := req.Config
+	if config == nil {
+		config = &genai.GenerateContentConfig{}
+	}
+	if config.SystemInstruction != nil {
+		if text := contentText(config.SystemInstruction); text != "" {
+			body.Messages = append(body.Messages, openAIMessage{Role: "system", Content: &text})
+		}
+	}
+
+	var pendingIDs []string
+	for i, content := range req.Contents {
+		if content == nil {
+			continue
+		}
+		message := openAIMessage{Role: "user"}
+		if content.Role == genai.RoleModel {
+			message.Role = "assistant"
+		}
+		var text strings.Builder
+		var toolMessages []openAIMessage
+		for j, part := range content.Parts {
+			switch {
+			case part.Thought:
+			case part.Text != "":
+				text.WriteString(part.Text)
+			case part.FunctionCall != nil:
+				id := part.FunctionCall.ID
+				if id == "" {
+					id = fmt.Sprintf("call_%d_%d", i, j)
+				}
+				arguments, err := json.Marshal(part.FunctionCall.Args)
+				if err != nil {
+					return nil, fmt.Errorf("encode arguments of %s: %w", part.FunctionCall.Name, err)
+				}
+				call := openAIToolCall{ID: id, Type: "function"}
+				call.Function.Name = part.FunctionCall.Name
+				call.Function.Arguments = string(arguments)
+				message.ToolCalls = append(message.ToolCalls, call)
+				pendingIDs = append(pendingIDs, id)
+			case part.FunctionResponse != nil:
+				id := part.FunctionResponse.ID
+				if id == "" && len(pendingIDs) > 0 {
+					id, pendingIDs = pendingIDs[0], pendingIDs[1:]
+				}
+				result, err := json.Marshal(part.FunctionResponse.Response)
+				if err != nil {
+					return nil, fmt.Errorf("encode result of %s: %w", part.FunctionResponse.Name, err)
+				}
+				output := string(result)
+				toolMessages = append(toolMessages, openAIMessage{
+					Role:       "tool",
+					ToolCallID: id,
+					Name:       part.FunctionResponse.Name,
+					Content:    &output,
+				})
+			default:
+				return nil, fmt.Errorf("openai provider supports text and function parts only")
+			}
+		}
+		if text.Len() > 0 {
+			value := text.String()
+			message.Content = &value
+		}
+		if message.Content != nil || len(message.ToolCalls) > 0 {
+			body.Messages = append(body.Messages, message)
+		}
+		body.Messages = append(body.Messages, toolMessages...)
+	}
+
+	for _, tool := range config.Tools {
+		if tool == nil {
+			continue
+		}
+		for _, declaration := range tool.FunctionDeclarations {
+			function := openAIFunctionDecl{Name: declaration.Name, Description: declaration.Description}
+			switch {
+			case declaration.ParametersJsonSchema != nil:
+				function.Parameters = declaration.ParametersJsonSchema
+			case declaration.Parameters != nil:
+				parameters, err := jsonSchema(declaration.Parameters)
+				if err != nil {
+					return nil, fmt.Errorf("convert parameters of %s: %w", declaration.Name, err)
+				}
+				function.Parameters = parameters
+			default:
+				function.Parameters = map[string]any{"type": "object", "properties": map[string]any{}}
+			}
+			body.Tools = append(body.Tools, openAITool{Type: "function", Function: function})
+		}
+	}
+
+	body.Temperature = config.Temperature
+	body.TopP = config.TopP
+	body.MaxTokens = config.MaxOutputTokens
+	body.Stop = config.StopSequences
+	body.Seed = config.Seed
+	switch {
+	case config.ResponseSchema != nil:
+		schema, err := jsonSchema(config.ResponseSchema)
+		if err != nil {
+			return nil, fmt.Errorf("convert response schema: %w", err)
+		}
+		body.ResponseFormat = responseFormat(schema)
+	case config.ResponseJsonSchema != nil:
+		body.ResponseFormat = responseFormat(config.ResponseJsonSchema)
+	case config.ResponseMIMEType == "application/json":
+		body.ResponseFormat = map[string]any{"type": "json_object"}
+	}
+	return body, nil
+}
+
+func responseFormat(schema any) map[string]any {
+	return map[string]any{
+		"type":        "json_schema",
+		"json_schema": map[string]any{"name": "response", "schema": schema},
+	}
+}
+
+func fromOpenAIResponse(completion openAIResponse) (*model.LLMResponse, error) {
+	if len(completion.Choices) == 0 {
+		return nil, fmt.Errorf("chat response has no choices")
+	}
+	choice := completion.Choices[0]
+	content := &genai.Content{Role: genai.RoleModel}
+	if choice.Message.Content != nil && *choice.Message.Content != "" {
+		content.Parts = append(content.Parts, genai.NewPartFromText(*choice.Message.Content))
+	}
+	for _, call := range choice.Message.ToolCalls {
+		args := map[string]any{}
+		if strings.TrimSpace(call.Function.Arguments) != "" {
+			if err := json.Unmarshal([]byte(call.Function.Arguments), &args); err != nil {
+				return nil, fmt.Errorf("decode arguments of %s: %w", call.Function.Name, err)
+			}
+		}
+		content.Parts = append(content.Parts, &genai.Part{FunctionCall: &genai.FunctionCall{
+			ID:   call.ID,
+			Name: call.Function.Name,
+			Args: args,
+		}})
+	}
+	resp := &model.LLMResponse{Content: content, TurnComplete: true}
+	switch choice.FinishReason {
+	case "stop", "tool_calls", "function_call", "":
+		resp.FinishReason = genai.FinishReasonStop
+	case "length":
+		resp.FinishReason = genai.FinishReasonMaxTokens
+	case "content_filter":
+		resp.FinishReason = genai.FinishReasonSafety
+	default:
+		resp.FinishReason = genai.FinishReasonOther
+	}
+	if completion.Usage != nil {
+		resp.UsageMetadata = &genai.GenerateContentResponseUsageMetadata{
+			PromptTokenCount:     completion.Usage.PromptTokens,
+			CandidatesTokenCount: completion.Usage.CompletionTokens,
+			TotalTokenCount:      completion.Usage.TotalTokens,
+		}
+	}
+	return resp, nil
+}
+
+func contentText(content *genai.Content) string {
+	var b strings.Builder
+	for _, part := range content.Parts {
+		if part.Text != "" && !part.Thought {
+			if b.Len() > 0 {
+				b.WriteString("\n")
+			}
+			b.WriteString(part.Text)
+		}
+	}
+	return b.String()
+}
+
+// jsonSchema converts a genai schema, which spells types in upper case and
+// has Gemini-only keywords, into standard JSON Schema.
+func jsonSchema(schema *genai.Schema) (map[string]any, error) {
+	encoded, err := json.Marshal(schema)
+	if err != nil {
+		return nil, err
+	}
+	var converted map[string]any
+	if err := json.Unmarshal(encoded, &converted); err != nil {
+		return nil, err
+	}
+	normalizeSchema(converted)
+	return converted, nil
+}
+
+func normalizeSchema(schema map[string]any) {
+	if kind, ok := schema["type"].(string); ok {
+		schema["type"] = strings.ToLower(kind)
+		if nullable, _ := schema["nullable"].(bool); nullable {
+			schema["type"] = []any{strings.ToLower(kind), "null"}
+		}
+	}
+	delete(schema, "nullable")
+	delete(schema, "propertyOrdering")
+	if properties, ok := schema["properties"].(map[string]any); ok {
+		for _, property := range properties {
+			if nested, ok := property.(map[string]any); ok {
+				normalizeSchema(nested)
+			}
+		}
+	}
+	if items, ok := schema["items"].(map[string]any); ok {
+		normalizeSchema(items)
+	}
+	if anyOf, ok := schema["anyOf"].([]any); ok {
+		for _, option := range anyOf {
+			if nested, ok := option.(map[string]any); ok {
+				normalizeSchema(nested)
+			}
+		}
+	}
+}
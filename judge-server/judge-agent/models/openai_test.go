@@ -0,0 +1,314 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"google.golang.org/adk/model"
+	"google.golang.org/genai"
+)
+
+// chatServer answers every chat completion with status and reply and
+// keeps the last request it was sent.
+type chatServer struct {
+	*httptest.Server
+	status int
+	reply  string
+
+	authorization string
+	request       map[string]any
+}
+
+func newChatServer(t *testing.T, status int, reply string) *chatServer {
+	t.Helper()
+	server := &chatServer{status: status, reply: reply}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			http.NotFound(w, r)
+			return
+		}
+		server.authorization = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&server.request); err != nil {
+			t.Errorf("decode chat request: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(server.status)
+		w.Write([]byte(server.reply))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func (s *chatServer) model(t *testing.T) model.LLM {
+	t.Helper()
+	llm, err := newOpenAI(context.Background(), Spec{Provider: ProviderOpenAI, Name: "local-model", BaseURL: s.URL + "/v1/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return llm
+}
+
+// jsonValue decodes want the way the server decoded the request.
+func jsonValue(t *testing.T, want string) any {
+	t.Helper()
+	var value any
+	if err := json.Unmarshal([]byte(want), &value); err != nil {
+		t.Fatal(err)
+	}
+	return value
+}
+
+const okReply = `{"choices": [{"message": {"role": "assistant", "content": "ok"}, "finish_reason": "stop"}]}`
+
+func TestOpenAIRequest(t *testing.T) {
+	t.Setenv(openAIAPIKeyEnv, "secret")
+	server := newChatServer(t, http.StatusOK, okReply)
+	temperature := float32(0.5)
+	req := &model.LLMRequest{
+		Contents: []*genai.Content{
+			genai.NewContentFromText("sum 1 and 2", genai.RoleUser),
+			{Role: genai.RoleModel, Parts: []*genai.Part{
+				{Text: "thinking it over", Thought: true},
+				{Text: "Reading the files."},
+				{FunctionCall: &genai.FunctionCall{Name: "read_file", Args: map[string]any{"path": "main.py"}}},
+				{FunctionCall: &genai.FunctionCall{Name: "list_files", Args: map[string]any{}}},
+			}},
+			{Role: genai.RoleUser, Parts: []*genai.Part{
+				{FunctionResponse: &genai.FunctionResponse{Name: "read_file", Response: map[string]any{"contents": "print(3)"}}},
+				{FunctionResponse: &genai.FunctionResponse{Name: "list_files", Response: map[string]any{"files": []any{"main.py"}}}},
+			}},
+		},
+		Config: &genai.GenerateContentConfig{
+			SystemInstruction: &genai.Content{Parts: []*genai.Part{
+				{Text: "You judge submissions."},
+				{Text: "hidden reasoning", Thought: true},
+				{Text: "Answer in JSON."},
+			}},
+			Temperature:     &temperature,
+			MaxOutputTokens: 256,
+			Tools: []*genai.Tool{{FunctionDeclarations: []*genai.FunctionDeclaration{{
+				Name:        "read_file",
+				Description: "Reads a file.",
+				Parameters: &genai.Schema{
+					Type:             genai.TypeObject,
+					PropertyOrdering: []string{"path"},
+					Properties: map[string]*genai.Schema{
+						"path": {Type: genai.TypeString, Nullable: genai.Ptr(true)},
+					},
+				},
+			}, {
+				Name: "list_files",
+			}}}},
+			ResponseSchema: &genai.Schema{
+				Type:       genai.TypeObject,
+				Properties: map[string]*genai.Schema{"verdict": {Type: genai.TypeString}},
+			},
+		},
+	}
+	if _, err := generate(server.model(t), req); err != nil {
+		t.Fatalf("GenerateContent() error = %v", err)
+	}
+
+	if server.authorization != "Bearer secret" {
+		t.Errorf("Authorization = %q, want the key from %s", server.authorization, openAIAPIKeyEnv)
+	}
+	want := jsonValue(t, `{
+		"model": "local-model",
+		"temperature": 0.5,
+		"max_tokens": 256,
+		"messages": [
+			{"role": "system", "content": "You judge submissions.\nAnswer in JSON."},
+			{"role": "user", "content": "sum 1 and 2"},
+			{"role": "assistant", "content": "Reading the files.", "tool_calls": [
+				{"id": "call_1_2", "type": "function", "function": {"name": "read_file", "arguments": "{\"path\":\"main.py\"}"}},
+				{"id": "call_1_3", "type": "function", "function": {"name": "list_files", "arguments": "{}"}}
+			]},
+			{"role": "tool", "tool_call_id": "call_1_2", "name": "read_file", "content": "{\"contents\":\"print(3)\"}"},
+			{"role": "tool", "tool_call_id": "call_1_3", "name": "list_files", "content": "{\"files\":[\"main.py\"]}"}
+		],
+		"tools": [
+			{"type": "function", "function": {"name": "read_file", "description": "Reads a file.", "parameters": {
+				"type": "object",
+				"properties": {"path": {"type": ["string", "null"]}}
+			}}},
+			{"type": "function", "function": {"name": "list_files", "parameters": {"type": "object", "properties": {}}}}
+		],
+		"response_format": {"type": "json_schema", "json_schema": {"name": "response", "schema": {
+			"type": "object",
+			"properties": {"verdict": {"type": "string"}}
+		}}}
+	}`)
+	if !reflect.DeepEqual(server.request, want) {
+		got, _ := json.MarshalIndent(server.request, "", "  ")
+		t.Errorf("chat request =\n%s", got)
+	}
+}
+
+func TestOpenAIRequestKeepsFunctionIDs(t *testing.T) {
+	req := &model.LLMRequest{Contents: []*genai.Content{
+		{Role: genai.RoleModel, Parts: []*genai.Part{
+			{FunctionCall: &genai.FunctionCall{ID: "abc", Name: "run", Args: map[string]any{}}},
+		}},
+		{Role: genai.RoleUser, Parts: []*genai.Part{
+			{FunctionResponse: &genai.FunctionResponse{ID: "abc", Name: "run", Response: map[string]any{}}},
+		}},
+	}}
+	body, err := toOpenAIRequest("m", req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Messages) != 2 || body.Messages[0].ToolCalls[0].ID != "abc" || body.Messages[1].ToolCallID != "abc" {
+		t.Errorf("messages = %+v, want the call and response to keep ID abc", body.Messages)
+	}
+}
+
+func TestOpenAIRequestJSONMode(t *testing.T) {
+	req := &model.LLMRequest{
+		Contents: []*genai.Content{genai.NewContentFromText("hi", genai.RoleUser)},
+		Config:   &genai.GenerateContentConfig{ResponseMIMEType: "application/json"},
+	}
+	body, err := toOpenAIRequest("m", req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]any{"type": "json_object"}; !reflect.DeepEqual(body.ResponseFormat, want) {
+		t.Errorf("response_format = %v, want %v", body.ResponseFormat, want)
+	}
+}
+
+func TestOpenAIRequestRejectsOtherParts(t *testing.T) {
+	req := &model.LLMRequest{Contents: []*genai.Content{{Role: genai.RoleUser, Parts: []*genai.Part{
+		genai.NewPartFromBytes([]byte{0x89, 'P', 'N', 'G'}, "image/png"),
+	}}}}
+	if _, err := toOpenAIRequest("m", req); err == nil {
+		t.Errorf("toOpenAIRequest() accepted an inline image")
+	}
+}
+
+func TestOpenAIResponse(t *testing.T) {
+	server := newChatServer(t, http.StatusOK, `{
+		"choices": [{
+			"message": {"role": "assistant", "content": "Let me look.", "tool_calls": [
+				{"id": "call_9", "type": "function", "function": {"name": "read_file", "arguments": "{\"path\": \"main.py\"}"}},
+				{"id": "call_10", "type": "function", "function": {"name": "list_files", "arguments": ""}}
+			]},
+			"finish_reason": "tool_calls"
+		}],
+		"usage": {"prompt_tokens": 12, "completion_tokens": 5, "total_tokens": 17}
+	}`)
+	responses, err := generate(server.model(t), &model.LLMRequest{
+		Contents: []*genai.Content{genai.NewContentFromText("hi", genai.RoleUser)},
+	})
+	if err != nil {
+		t.Fatalf("GenerateContent() error = %v", err)
+	}
+	if len(responses) != 1 {
+		t.Fatalf("got %d responses, want 1", len(responses))
+	}
+	resp := responses[0]
+	if !resp.TurnComplete || resp.FinishReason != genai.FinishReasonStop {
+		t.Errorf("TurnComplete = %v, FinishReason = %s, want a complete turn that stopped", resp.TurnComplete, resp.FinishReason)
+	}
+	want := &genai.Content{Role: genai.RoleModel, Parts: []*genai.Part{
+		genai.NewPartFromText("Let me look."),
+		{FunctionCall: &genai.FunctionCall{ID: "call_9", Name: "read_file", Args: map[string]any{"path": "main.py"}}},
+		{FunctionCall: &genai.FunctionCall{ID: "call_10", Name: "list_files", Args: map[string]any{}}},
+	}}
+	if !reflect.DeepEqual(resp.Content, want) {
+		t.Errorf("Content = %+v, want %+v", resp.Content, want)
+	}
+	usage := resp.UsageMetadata
+	if usage == nil || usage.PromptTokenCount != 12 || usage.CandidatesTokenCount != 5 || usage.TotalTokenCount != 17 {
+		t.Errorf("UsageMetadata = %+v, want 12 prompt, 5 completion, 17 total tokens", usage)
+	}
+}
+
+func TestOpenAIFinishReasons(t *testing.T) {
+	tests := []struct {
+		reason string
+		want   genai.FinishReason
+	}{
+		{"stop", genai.FinishReasonStop},
+		{"tool_calls", genai.FinishReasonStop},
+		{"function_call", genai.FinishReasonStop},
+		{"", genai.FinishReasonStop},
+		{"length", genai.FinishReasonMaxTokens},
+		{"content_filter", genai.FinishReasonSafety},
+		{"something_new", genai.FinishReasonOther},
+	}
+	for _, tt := range tests {
+		var completion openAIResponse
+		raw := `{"choices": [{"message": {"role": "assistant", "content": "x"}, "finish_reason": "` + tt.reason + `"}]}`
+		if err := json.Unmarshal([]byte(raw), &completion); err != nil {
+			t.Fatal(err)
+		}
+		resp, err := fromOpenAIResponse(completion)
+		if err != nil {
+			t.Errorf("%q: fromOpenAIResponse() error = %v", tt.reason, err)
+			continue
+		}
+		if resp.FinishReason != tt.want {
+			t.Errorf("%q: FinishReason = %s, want %s", tt.reason, resp.FinishReason, tt.want)
+		}
+	}
+}
+
+func TestOpenAIErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		reply       string
+		unavailable bool
+	}{
+		{"rate limited", http.StatusTooManyRequests, `{"error": {"message": "slow down"}}`, true},
+		{"server error", http.StatusInternalServerError, `{"error": {"message": "boom"}}`, true},
+		{"overloaded", http.StatusServiceUnavailable, `overloaded`, true},
+		{"bad request", http.StatusBadRequest, `{"error": {"message": "unknown model"}}`, false},
+		{"unauthorized", http.StatusUnauthorized, `{"error": {"message": "bad key"}}`, false},
+		{"no choices", http.StatusOK, `{"choices": []}`, false},
+		{"bad arguments", http.StatusOK, `{"choices": [{"message": {"tool_calls": [
+			{"id": "1", "type": "function", "function": {"name": "f", "arguments": "{not json"}}
+		]}}]}`, false},
+		{"not json", http.StatusOK, `<html>`, false},
+	}
+	for _, tt := range tests {
+		server := newChatServer(t, tt.status, tt.reply)
+		_, err := generate(server.model(t), &model.LLMRequest{
+			Contents: []*genai.Content{genai.NewContentFromText("hi", genai.RoleUser)},
+		})
+		if err == nil {
+			t.Errorf("%s: GenerateContent() succeeded", tt.name)
+			continue
+		}
+		if got := errors.Is(err, ErrUnavailable); got != tt.unavailable {
+			t.Errorf("%s: errors.Is(%v, ErrUnavailable) = %v, want %v", tt.name, err, got, tt.unavailable)
+		}
+	}
+
+	server := newChatServer(t, http.StatusOK, okReply)
+	llm := server.model(t)
+	server.Close()
+	_, err := generate(llm, &model.LLMRequest{Contents: []*genai.Content{genai.NewContentFromText("hi", genai.RoleUser)}})
+	if !errors.Is(err, ErrUnavailable) {
+		t.Errorf("unreachable server: GenerateContent() error = %v, want ErrUnavailable", err)
+	}
+}
@@ -0,0 +1,475 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package problems loads problem packages: the tests, limits, checker and
+// files the judge needs for a problem, kept on the server so none of it
+// passes through the client.
+//
+// A package is a directory, or a .tar / .tar.gz archive of one, named after
+// the problem ID:
+//
+//	problem.json        the Manifest
+//	tests/NAME.in       stdin of test NAME
+//	tests/NAME.out      expected stdout of test NAME
+//	hidden/...          hidden test files added to the image after the build
+//	protected/...       files that replace the learner's copy in the build context
+//	checker/...         checker program sources, for a "program" checker
+//	solution/...        the reference solution
+//
+// A package with hidden files but no tests/ is judged by running the
+// profile's test suite, such as Jest for react, over the hidden tests. It
+// must protect the profile's dependency files, package.json for react, so
+// the test command and runner are the problem's own.
+package problems
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"main/judge-agent/judging"
+	"main/judge-agent/profiles"
+)
+
+// FormatVersion is the package format this judge reads.
+const FormatVersion = 1
+
+const (
+	manifestFile = "problem.json"
+	testsDir     = "tests/"
+	hiddenDir    = "hidden/"
+	protectedDir = "protected/"
+	checkerDir   = "checker/"
+	solutionDir  = "solution/"
+
+	defaultMaxPackageBytes = 64 << 20
+)
+
+// ErrNotFound is returned for problem IDs with no package.
+var ErrNotFound = errors.New("problem package not found")
+
+var idPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Manifest is problem.json.
+type Manifest struct {
+	FormatVersion int    `json:"format_version"`
+	ID            string `json:"id"`
+	// Version identifies the revision of the problem's contents.
+	Version     string `json:"version"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Category    string `json:"category"`
+	// Profile is the language profile submissions build with; it
+	// defaults to the one named after the category.
+	Profile string         `json:"profile,omitempty"`
+	Limits  judging.Limits `json:"limits,omitempty"`
+	// Checker selects the comparator. A "program" checker is built from
+	// the checker directory, with CheckerProfile or its own Dockerfile.
+	Checker        judging.CheckerSpec `json:"checker,omitempty"`
+	CheckerProfile string              `json:"checker_profile,omitempty"`
+}
+
+// Package is a loaded and validated problem package. File maps are keyed
+// by slash-separated path relative to their directory.
+type Package struct {
+	Manifest       Manifest
+	Tests          []judging.TestCase
+	HiddenFiles    map[string][]byte
+	ProtectedFiles map[string][]byte
+	CheckerFiles   map[string][]byte
+	SolutionFiles  map[string][]byte
+}
+
+// CheckerSpec resolves the manifest's checker, filling in the program
+// source from the checker directory.
+func (p *Package) CheckerSpec() judging.CheckerSpec {
+	spec := p.Manifest.Checker
+	if spec.Type != judging.CheckerProgram {
+		return spec
+	}
+	source := &judging.CheckerSource{Profile: p.Manifest.CheckerProfile, Files: map[string]string{}}
+	for name, contents := range p.CheckerFiles {
+		source.Files[name] = string(contents)
+	}
+	if dockerfile, ok := source.Files["Dockerfile"]; ok && source.Profile == "" {
+		source.DockerFile = dockerfile
+	}
+	spec.Program = source
+	return spec
+}
+
+// Parse builds a package from its files, keyed by slash-separated path
+// relative to the package root.
+func Parse(files map[string][]byte) (*Package, error) {
+	raw, ok := files[manifestFile]
+	if !ok {
+		return nil, fmt.Errorf("missing %s", manifestFile)
+	}
+	pkg := &Package{
+		HiddenFiles:    map[string][]byte{},
+		ProtectedFiles: map[string][]byte{},
+		CheckerFiles:   map[string][]byte{},
+		SolutionFiles:  map[string][]byte{},
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&pkg.Manifest); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", manifestFile, err)
+	}
+
+	inputs := map[string][]byte{}
+	outputs := map[string][]byte{}
+	for name, contents := range files {
+		switch {
+		case name == manifestFile:
+		case strings.HasPrefix(name, testsDir):
+			test := strings.TrimPrefix(name, testsDir)
+			switch {
+			case strings.HasSuffix(test, ".in"):
+				inputs[strings.TrimSuffix(test, ".in")] = contents
+			case strings.HasSuffix(test, ".out"):
+				outputs[strings.TrimSuffix(test, ".out")] = contents
+			default:
+				return nil, fmt.Errorf("%s: test files must end in .in or .out", name)
+			}
+		case strings.HasPrefix(name, hiddenDir):
+			pkg.HiddenFiles[strings.TrimPrefix(name, hiddenDir)] = contents
+		case strings.HasPrefix(name, protectedDir):
+			pkg.ProtectedFiles[strings.TrimPrefix(name, protectedDir)] = contents
+		case strings.HasPrefix(name, checkerDir):
+			pkg.CheckerFiles[strings.TrimPrefix(name, checkerDir)] = contents
+		case strings.HasPrefix(name, solutionDir):
+			pkg.SolutionFiles[strings.TrimPrefix(name, solutionDir)] = contents
+		default:
+			return nil, fmt.Errorf("%s: unexpected file outside the package layout", name)
+		}
+	}
+
+	names := make([]string, 0, len(inputs))
+	for name := range inputs {
+		if _, ok := outputs[name]; !ok {
+			return nil, fmt.Errorf("test %q has no .out file", name)
+		}
+		names = append(names, name)
+	}
+	for name := range outputs {
+		if _, ok := inputs[name]; !ok {
+			return nil, fmt.Errorf("test %q has no .in file", name)
+		}
+	}
+	sortTestNames(names)
+	for _, name := range names {
+		pkg.Tests = append(pkg.Tests, judging.TestCase{
+			Name:           name,
+			Input:          string(inputs[name]),
+			ExpectedOutput: string(outputs[name]),
+		})
+	}
+
+	if err := pkg.validate(); err != nil {
+		return nil, err
+	}
+	return pkg, nil
+}
+
+func (p *Package) validate() error {
+	m := &p.Manifest
+	if m.FormatVersion != FormatVersion {
+		return fmt.Errorf("unsupported format_version %d, want %d", m.FormatVersion, FormatVersion)
+	}
+	if !idPattern.MatchString(m.ID) {
+		return fmt.Errorf("invalid problem id %q", m.ID)
+	}
+	if strings.TrimSpace(m.Version) == "" {
+		return fmt.Errorf("version is required")
+	}
+	if strings.TrimSpace(m.Category) == "" {
+		return fmt.Errorf("category is required")
+	}
+	if m.Profile == "" {
+		m.Profile = m.Category
+	}
+	profile, err := profiles.Lookup(m.Profile)
+	if err != nil {
+		return fmt.Errorf("profile: %w", err)
+	}
+	m.Profile = profile.Name
+	// A package judged by its test suite runs the profile's test command
+	// through its dependency files, such as package.json, so it must ship
+	// them rather than run whatever the learner's copies say.
+	if len(p.Tests) == 0 && len(p.HiddenFiles) > 0 {
+		for _, pattern := range profile.DependencyFiles {
+			if !p.protects(pattern) {
+				return fmt.Errorf("a package judged by its test suite needs %s%s", protectedDir, pattern)
+			}
+		}
+	}
+	if m.Limits.TimeLimitMs < 0 || m.Limits.MemoryLimitKB < 0 {
+		return fmt.Errorf("limits must not be negative")
+	}
+	if m.Checker.Program != nil {
+		return fmt.Errorf("checker program sources belong in %s, not the manifest", checkerDir)
+	}
+	if err := p.CheckerSpec().Validate(); err != nil {
+		return fmt.Errorf("checker: %w", err)
+	}
+	if m.CheckerProfile != "" {
+		if _, err := profiles.Lookup(m.CheckerProfile); err != nil {
+			return fmt.Errorf("checker_profile: %w", err)
+		}
+	}
+	return nil
+}
+
+// protects reports whether a protected file sits at a path matching pattern.
+func (p *Package) protects(pattern string) bool {
+	for name := range p.ProtectedFiles {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// sortTestNames orders numeric names numerically, so test 10 follows 9.
+func sortTestNames(names []string) {
+	sort.Slice(names, func(i, j int) bool {
+		if len(names[i]) != len(names[j]) && isDigits(names[i]) && isDigits(names[j]) {
+			return len(names[i]) < len(names[j])
+		}
+		return names[i] < names[j]
+	})
+}
+
+func isDigits(s string) bool {
+	return s != "" && strings.Trim(s, "0123456789") == ""
+}
+
+// Store loads packages from a directory and caches them until their
+// source changes on disk.
+type Store struct {
+	root     string
+	maxBytes int64
+
+	mu      sync.Mutex
+	entries map[string]storeEntry
+}
+
+type storeEntry struct {
+	source  string
+	version string
+	pkg     *Package
+}
+
+// NewStore reads packages from root. An empty root holds no packages.
+func NewStore(root string) *Store {
+	return &Store{root: root, maxBytes: defaultMaxPackageBytes, entries: map[string]storeEntry{}}
+}
+
+var (
+	activeOnce  sync.Once
+	activeStore *Store
+)
+
+// Active returns the store rooted at MCP_PROBLEMS_DIR.
+func Active() *Store {
+	activeOnce.Do(func() {
+		activeStore = NewStore(strings.TrimSpace(os.Getenv("MCP_PROBLEMS_DIR")))
+	})
+	return activeStore
+}
+
+// Get returns the package for id, loading it on first use and again
+// whenever any file of its directory, or its archive, changes.
+func (s *Store) Get(id string) (*Package, error) {
+	if !idPattern.MatchString(id) {
+		return nil, fmt.Errorf("invalid problem id %q", id)
+	}
+	if s.root == "" {
+		return nil, ErrNotFound
+	}
+	source, isDir, version, err := s.locate(id)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	entry, ok := s.entries[id]
+	s.mu.Unlock()
+	if ok && entry.source == source && entry.version == version {
+		return entry.pkg, nil
+	}
+
+	var files map[string][]byte
+	if isDir {
+		files, err = readDir(source, s.maxBytes)
+	} else {
+		files, err = readArchive(source, s.maxBytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("problem %s: %w", id, err)
+	}
+	pkg, err := Parse(files)
+	if err != nil {
+		return nil, fmt.Errorf("problem %s: %w", id, err)
+	}
+	if pkg.Manifest.ID != id {
+		return nil, fmt.Errorf("problem %s: manifest id is %q", id, pkg.Manifest.ID)
+	}
+
+	s.mu.Lock()
+	s.entries[id] = storeEntry{source: source, version: version, pkg: pkg}
+	s.mu.Unlock()
+	return pkg, nil
+}
+
+// locate finds the package source for id and a version that changes with
+// its contents: for a directory, a digest of the path, size and
+// modification time of every file in it, and for an archive, its own size
+// and modification time.
+func (s *Store) locate(id string) (source string, isDir bool, version string, err error) {
+	dir := filepath.Join(s.root, id)
+	if _, err := os.Stat(filepath.Join(dir, manifestFile)); err == nil {
+		version, err := dirVersion(dir)
+		if err != nil {
+			return "", false, "", fmt.Errorf("problem %s: %w", id, err)
+		}
+		return dir, true, version, nil
+	}
+	for _, ext := range []string{".tar.gz", ".tgz", ".tar"} {
+		archive := dir + ext
+		if info, err := os.Stat(archive); err == nil && info.Mode().IsRegular() {
+			return archive, false, fmt.Sprintf("%d:%d", info.Size(), info.ModTime().UnixNano()), nil
+		}
+	}
+	return "", false, "", ErrNotFound
+}
+
+// dirVersion digests the metadata of every entry under root, so editing,
+// adding or removing any file changes it without reading file contents.
+func dirVersion(root string) (string, error) {
+	hash := sha256.New()
+	err := filepath.WalkDir(root, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, name)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(hash, "%s\x00%s\x00%d\x00%d\n", filepath.ToSlash(rel), info.Mode(), info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func readDir(root string, maxBytes int64) (map[string][]byte, error) {
+	files := map[string][]byte{}
+	var total int64
+	err := filepath.WalkDir(root, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		if !entry.Type().IsRegular() {
+			return fmt.Errorf("%s: only regular files are allowed", name)
+		}
+		rel, err := filepath.Rel(root, name)
+		if err != nil {
+			return err
+		}
+		contents, err := os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		if total += int64(len(contents)); total > maxBytes {
+			return fmt.Errorf("package exceeds %d bytes", maxBytes)
+		}
+		files[filepath.ToSlash(rel)] = contents
+		return nil
+	})
+	return files, err
+}
+
+func readArchive(name string, maxBytes int64) (map[string][]byte, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var reader io.Reader = file
+	if strings.HasSuffix(name, ".gz") || strings.HasSuffix(name, ".tgz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	files := map[string][]byte{}
+	var total int64
+	tr := tar.NewReader(reader)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read archive: %w", err)
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			continue
+		case tar.TypeReg:
+		default:
+			return nil, fmt.Errorf("%s: only regular files are allowed", header.Name)
+		}
+		clean := path.Clean(strings.TrimPrefix(header.Name, "./"))
+		if path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+			return nil, fmt.Errorf("%s: path escapes the package", header.Name)
+		}
+		if total += header.Size; total > maxBytes {
+			return nil, fmt.Errorf("package exceeds %d bytes", maxBytes)
+		}
+		contents, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", header.Name, err)
+		}
+		files[clean] = contents
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package problems
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseTestSuitePackages(t *testing.T) {
+	const manifest = `{"format_version": 1, "id": "todo", "version": "1", "category": "react"}`
+	tests := []struct {
+		name  string
+		files map[string]string
+		ok    bool
+	}{
+		{"protects package.json", map[string]string{
+			"hidden/src/App.test.js": "test",
+			"protected/package.json": "{}",
+		}, true},
+		{"learner package.json", map[string]string{
+			"hidden/src/App.test.js": "test",
+		}, false},
+		{"only a nested package.json", map[string]string{
+			"hidden/src/App.test.js":     "test",
+			"protected/src/package.json": "{}",
+		}, false},
+		{"no test suite", map[string]string{
+			"protected/src/index.js": "",
+		}, true},
+	}
+	for _, tt := range tests {
+		files := map[string][]byte{manifestFile: []byte(manifest)}
+		for name, contents := range tt.files {
+			files[name] = []byte(contents)
+		}
+		_, err := Parse(files)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: Parse() error = %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestStoreReloadsChangedDirectories(t *testing.T) {
+	root := t.TempDir()
+	write := func(name, contents string) {
+		t.Helper()
+		path := filepath.Join(root, "sum", filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(manifestFile, `{"format_version": 1, "id": "sum", "version": "1", "category": "python"}`)
+	write("tests/1.in", "1 2\n")
+	write("tests/1.out", "3\n")
+	store := NewStore(root)
+
+	get := func() *Package {
+		t.Helper()
+		pkg, err := store.Get("sum")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pkg
+	}
+	first := get()
+	if get() != first {
+		t.Errorf("unchanged package was reloaded")
+	}
+
+	// problem.json stays as it was; only a test changes.
+	write("tests/1.out", "4\n")
+	if got := get().Tests[0].ExpectedOutput; got != "4\n" {
+		t.Errorf("after editing a test, expected output = %q, want the new one", got)
+	}
+	write("tests/2.in", "2 2\n")
+	write("tests/2.out", "4\n")
+	if got := len(get().Tests); got != 2 {
+		t.Errorf("after adding a test, got %d tests, want 2", got)
+	}
+}
@@ -0,0 +1,301 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package profiles defines the server-side language profiles used to build
+// submissions, so clients pick a language rather than a Dockerfile. The
+// built-in profiles live in builtin.json; a JSON file named by MCP_PROFILES
+// adds more or replaces them by name.
+package profiles
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"main/judge-agent/testreport"
+)
+
+// Resources are the default container requests and limits for a profile,
+// expressed as Kubernetes quantities.
+type Resources struct {
+	CPURequest    string `json:"cpu_request,omitempty"`
+	CPULimit      string `json:"cpu_limit,omitempty"`
+	MemoryRequest string `json:"memory_request,omitempty"`
+	MemoryLimit   string `json:"memory_limit,omitempty"`
+}
+
+// Profile describes how to turn a submission for one language into an image.
+type Profile struct {
+	Name      string   `json:"name"`
+	Aliases   []string `json:"aliases,omitempty"`
+	BaseImage string   `json:"base_image"`
+	WorkDir   string   `json:"work_dir"`
+	// DependencyFiles are copied before InstallSteps so dependency layers
+	// stay cached when only source files change.
+	DependencyFiles []string  `json:"dependency_files,omitempty"`
+	InstallSteps    []string  `json:"install_steps,omitempty"`
+	BuildSteps      []string  `json:"build_steps,omitempty"`
+	RunCommand      []string  `json:"run_command"`
+	Port            int       `json:"port,omitempty"`
+	Resources       Resources `json:"resources"`
+	// ScratchPaths are extra writable directories the program needs at run
+	// time, since sandboxes get a read-only root filesystem.
+	ScratchPaths []string `json:"scratch_paths,omitempty"`
+	// TimeoutSeconds bounds how long a sandbox may live; zero keeps the
+	// sandbox policy's deadline.
+	TimeoutSeconds int64 `json:"timeout_seconds,omitempty"`
+	// TestCommand runs the submission's test suite inside the running
+	// sandbox and writes a TestReportFormat report to TestReportPath, a
+	// path relative to $JUDGE_REPORT_DIR that may be a glob for runners
+	// that write one file per suite. The directory is made afresh in
+	// scratch space for every run, so a submission cannot ship a report.
+	TestCommand      []string `json:"test_command,omitempty"`
+	TestReportPath   string   `json:"test_report_path,omitempty"`
+	TestReportFormat string   `json:"test_report_format,omitempty"`
+}
+
+// Dockerfile renders the profile as a Dockerfile.
+func (p Profile) Dockerfile() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "FROM %s\n\n", p.BaseImage)
+	p.writeBuild(&b, "")
+	p.writeRun(&b)
+	return b.String()
+}
+
+// HiddenDockerfile renders the profile for a build context holding the
+// submission under sourceDir and server files under hiddenDir. The
+// submission is installed and built in a stage of its own, and the hidden
+// files are copied only into the final stage, after every step that runs
+// the submission's code, so no build step can read or print them.
+func (p Profile) HiddenDockerfile(sourceDir, hiddenDir string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "FROM %s AS submission\n\n", p.BaseImage)
+	p.writeBuild(&b, sourceDir+"/")
+	b.WriteString("FROM submission\n\n")
+	fmt.Fprintf(&b, "COPY %s/ ./\n\n", hiddenDir)
+	p.writeRun(&b)
+	return b.String()
+}
+
+// writeBuild writes the steps that install and build the submission found
+// under source, a context directory ending in a slash or empty for the
+// context root.
+func (p Profile) writeBuild(b *strings.Builder, source string) {
+	fmt.Fprintf(b, "WORKDIR %s\n\n", p.WorkDir)
+	if len(p.DependencyFiles) > 0 {
+		files := make([]string, len(p.DependencyFiles))
+		for i, file := range p.DependencyFiles {
+			files[i] = source + file
+		}
+		fmt.Fprintf(b, "COPY %s ./\n\n", strings.Join(files, " "))
+	}
+	for _, step := range p.InstallSteps {
+		fmt.Fprintf(b, "RUN %s\n", step)
+	}
+	if len(p.InstallSteps) > 0 {
+		b.WriteString("\n")
+	}
+	if source == "" {
+		source = "."
+	}
+	fmt.Fprintf(b, "COPY %s .\n\n", source)
+	for _, step := range p.BuildSteps {
+		fmt.Fprintf(b, "RUN %s\n", step)
+	}
+	if len(p.BuildSteps) > 0 {
+		b.WriteString("\n")
+	}
+}
+
+func (p Profile) writeRun(b *strings.Builder) {
+	if p.Port > 0 {
+		fmt.Fprintf(b, "EXPOSE %d\n\n", p.Port)
+	}
+	command, _ := json.Marshal(p.RunCommand)
+	fmt.Fprintf(b, "CMD %s\n", command)
+}
+
+func (p Profile) validate() error {
+	if strings.TrimSpace(p.Name) == "" {
+		return fmt.Errorf("profile name is required")
+	}
+	if strings.TrimSpace(p.BaseImage) == "" {
+		return fmt.Errorf("profile %q: base image is required", p.Name)
+	}
+	if strings.TrimSpace(p.WorkDir) == "" {
+		return fmt.Errorf("profile %q: work dir is required", p.Name)
+	}
+	if len(p.RunCommand) == 0 {
+		return fmt.Errorf("profile %q: run command is required", p.Name)
+	}
+	if p.Port < 0 || p.Port > 65535 {
+		return fmt.Errorf("profile %q: invalid port %d", p.Name, p.Port)
+	}
+	for _, scratch := range p.ScratchPaths {
+		if !strings.HasPrefix(scratch, "/") {
+			return fmt.Errorf("profile %q: scratch path %q must be absolute", p.Name, scratch)
+		}
+	}
+	if p.TimeoutSeconds < 0 {
+		return fmt.Errorf("profile %q: invalid timeout %d", p.Name, p.TimeoutSeconds)
+	}
+	if len(p.TestCommand) > 0 {
+		if report := path.Clean(p.TestReportPath); report == "." || path.IsAbs(report) || report == ".." || strings.HasPrefix(report, "../") {
+			return fmt.Errorf("profile %q: test report path %q must be relative to the report directory", p.Name, p.TestReportPath)
+		}
+		if !testreport.Supported(p.TestReportFormat) {
+			return fmt.Errorf("profile %q: unsupported test report format %q", p.Name, p.TestReportFormat)
+		}
+	}
+	return nil
+}
+
+// builtinProfiles are the profiles every server has, unless the
+// MCP_PROFILES file replaces them by name.
+//
+//go:embed builtin.json
+var builtinProfiles []byte
+
+// Parse reads a JSON array of profiles and validates each one.
+func Parse(contents []byte) ([]Profile, error) {
+	decoder := json.NewDecoder(bytes.NewReader(contents))
+	decoder.DisallowUnknownFields()
+	var parsed []Profile
+	if err := decoder.Decode(&parsed); err != nil {
+		return nil, err
+	}
+	names := map[string]bool{}
+	for _, p := range parsed {
+		if err := p.validate(); err != nil {
+			return nil, err
+		}
+		for _, name := range append([]string{p.Name}, p.Aliases...) {
+			key := strings.ToLower(name)
+			if names[key] {
+				return nil, fmt.Errorf("profile %q is defined twice", name)
+			}
+			names[key] = true
+		}
+	}
+	return parsed, nil
+}
+
+// LoadFile reads a JSON profiles file.
+func LoadFile(path string) ([]Profile, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read profiles %q: %w", path, err)
+	}
+	parsed, err := Parse(contents)
+	if err != nil {
+		return nil, fmt.Errorf("profiles %q: %w", path, err)
+	}
+	return parsed, nil
+}
+
+var (
+	mu       sync.RWMutex
+	registry = map[string]Profile{}
+	aliases  = map[string]string{}
+
+	loadOnce sync.Once
+	loadErr  error
+)
+
+// Load registers the built-in profiles and those in the file named by
+// MCP_PROFILES, which add to the built-in ones or replace them by name. It
+// runs once; later calls, and Lookup, return its error.
+func Load() error {
+	loadOnce.Do(func() {
+		builtins, err := Parse(builtinProfiles)
+		if err != nil {
+			loadErr = fmt.Errorf("built-in profiles: %w", err)
+			return
+		}
+		loaded := builtins
+		if path := strings.TrimSpace(os.Getenv("MCP_PROFILES")); path != "" {
+			configured, err := LoadFile(path)
+			if err != nil {
+				loadErr = err
+				return
+			}
+			loaded = append(loaded, configured...)
+		}
+		for _, p := range loaded {
+			if err := Register(p); err != nil {
+				loadErr = err
+				return
+			}
+		}
+	})
+	return loadErr
+}
+
+// Register adds or replaces a profile in the registry.
+func Register(p Profile) error {
+	if err := p.validate(); err != nil {
+		return err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	key := strings.ToLower(p.Name)
+	if canonical, ok := aliases[key]; ok && canonical != key {
+		return fmt.Errorf("profile %q is already an alias of %q", p.Name, canonical)
+	}
+	registry[key] = p
+	for _, alias := range p.Aliases {
+		aliases[strings.ToLower(alias)] = key
+	}
+	return nil
+}
+
+// Lookup returns the profile registered under name, ignoring case.
+func Lookup(name string) (Profile, error) {
+	if err := Load(); err != nil {
+		return Profile{}, err
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	key := strings.ToLower(strings.TrimSpace(name))
+	if canonical, ok := aliases[key]; ok {
+		key = canonical
+	}
+	p, ok := registry[key]
+	if !ok {
+		return Profile{}, fmt.Errorf("unknown profile %q", name)
+	}
+	return p, nil
+}
+
+// Names lists the registered profile names in sorted order.
+func Names() []string {
+	if err := Load(); err != nil {
+		return nil
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,77 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package profiles
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHiddenDockerfileRunsNothingAfterHiddenFiles(t *testing.T) {
+	for _, name := range Names() {
+		p, err := Lookup(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dockerfile := p.HiddenDockerfile("submission", "hidden")
+		submission, final, ok := strings.Cut(dockerfile, "FROM submission\n")
+		if !ok {
+			t.Errorf("%s: no final stage in\n%s", name, dockerfile)
+			continue
+		}
+		if strings.Contains(submission, "hidden/") || strings.Contains(submission, "COPY . ") {
+			t.Errorf("%s: the submission stage can see more than submission/:\n%s", name, dockerfile)
+		}
+		if strings.Contains(final, "RUN ") {
+			t.Errorf("%s: the final stage runs a step after copying hidden files:\n%s", name, dockerfile)
+		}
+		if !strings.Contains(final, "COPY hidden/ ./\n") {
+			t.Errorf("%s: the final stage does not copy the hidden files:\n%s", name, dockerfile)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		ok       bool
+	}{
+		{"minimal", `[{"name": "go", "base_image": "golang:1.23", "work_dir": "/app", "run_command": ["/app/main"]}]`, true},
+		{"unknown field", `[{"name": "go", "base_image": "golang:1.23", "work_dir": "/app", "run_command": ["/app/main"], "ports": [1]}]`, false},
+		{"no run command", `[{"name": "go", "base_image": "golang:1.23", "work_dir": "/app"}]`, false},
+		{"absolute report path", `[{"name": "go", "base_image": "golang:1.23", "work_dir": "/app", "run_command": ["/app/main"],
+			"test_command": ["go", "test"], "test_report_path": "/tmp/report.xml", "test_report_format": "junit"}]`, false},
+		{"duplicate name", `[{"name": "go", "base_image": "golang:1.23", "work_dir": "/app", "run_command": ["a"]},
+			{"name": "Go", "base_image": "golang:1.22", "work_dir": "/app", "run_command": ["a"]}]`, false},
+		{"alias clash", `[{"name": "go", "aliases": ["golang"], "base_image": "golang:1.23", "work_dir": "/app", "run_command": ["a"]},
+			{"name": "golang", "base_image": "golang:1.22", "work_dir": "/app", "run_command": ["a"]}]`, false},
+		{"not a list", `{"name": "go"}`, false},
+	}
+	for _, tt := range tests {
+		_, err := Parse([]byte(tt.contents))
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: Parse() error = %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+
+	builtins, err := Parse(builtinProfiles)
+	if err != nil {
+		t.Fatalf("built-in profiles: %v", err)
+	}
+	if len(builtins) != len(Names()) {
+		t.Errorf("registered %v, want the %d built-in profiles", Names(), len(builtins))
+	}
+}
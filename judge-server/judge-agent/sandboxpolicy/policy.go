@@ -0,0 +1,210 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sandboxpolicy describes how the containers that run untrusted
+// submissions are locked down, independent of the runtime that starts them.
+package sandboxpolicy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"main/judge-agent/profiles"
+)
+
+// Seccomp profile values. A localhost profile is written as
+// "Localhost/<path relative to the kubelet seccomp root>".
+const (
+	SeccompRuntimeDefault = "RuntimeDefault"
+	SeccompUnconfined     = "Unconfined"
+	seccompLocalhost      = "Localhost/"
+)
+
+// Policy is the hardening applied to every sandbox. Resources are only
+// fallbacks; a language profile's own resources take precedence.
+type Policy struct {
+	RunAsUser              int64    `json:"run_as_user"`
+	RunAsGroup             int64    `json:"run_as_group"`
+	ReadOnlyRootFilesystem bool     `json:"read_only_root_filesystem"`
+	DropCapabilities       []string `json:"drop_capabilities"`
+	SeccompProfile         string   `json:"seccomp_profile"`
+	// RuntimeClassName selects a sandboxed runtime such as gVisor or Kata
+	// when the cluster provides one.
+	RuntimeClassName string `json:"runtime_class_name,omitempty"`
+	// ScratchPaths are mounted as memory-backed tmpfs so programs still
+	// have somewhere to write with a read-only root filesystem.
+	ScratchPaths                 []string           `json:"scratch_paths"`
+	ScratchSizeLimit             string             `json:"scratch_size_limit"`
+	ActiveDeadlineSeconds        int64              `json:"active_deadline_seconds"`
+	AutomountServiceAccountToken bool               `json:"automount_service_account_token"`
+	Resources                    profiles.Resources `json:"resources"`
+}
+
+// Default returns the policy used when no policy file is configured.
+func Default() *Policy {
+	return &Policy{
+		RunAsUser:              65532,
+		RunAsGroup:             65532,
+		ReadOnlyRootFilesystem: true,
+		DropCapabilities:       []string{"ALL"},
+		SeccompProfile:         SeccompRuntimeDefault,
+		ScratchPaths:           []string{"/tmp"},
+		ScratchSizeLimit:       "64Mi",
+		ActiveDeadlineSeconds:  600,
+		Resources: profiles.Resources{
+			CPURequest:    "100m",
+			CPULimit:      "500m",
+			MemoryRequest: "64Mi",
+			MemoryLimit:   "256Mi",
+		},
+	}
+}
+
+// LoadFile reads a JSON policy. Fields left out of the file keep their
+// Default values.
+func LoadFile(path string) (*Policy, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read sandbox policy %q: %w", path, err)
+	}
+	policy := Default()
+	if err := json.Unmarshal(contents, policy); err != nil {
+		return nil, fmt.Errorf("parse sandbox policy %q: %w", path, err)
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, fmt.Errorf("sandbox policy %q: %w", path, err)
+	}
+	return policy, nil
+}
+
+var (
+	activeOnce   sync.Once
+	activePolicy *Policy
+	activeErr    error
+)
+
+// Active returns the policy named by MCP_SANDBOX_POLICY, or Default when
+// the variable is unset. A file that cannot be loaded is an error rather
+// than a quiet fallback to different hardening.
+func Active() (*Policy, error) {
+	activeOnce.Do(func() {
+		path := strings.TrimSpace(os.Getenv("MCP_SANDBOX_POLICY"))
+		if path == "" {
+			activePolicy = Default()
+			return
+		}
+		activePolicy, activeErr = LoadFile(path)
+	})
+	return activePolicy, activeErr
+}
+
+// Validate rejects policies a runtime could not apply.
+func (p *Policy) Validate() error {
+	if p.RunAsUser <= 0 {
+		return fmt.Errorf("run_as_user must be a non-root UID")
+	}
+	if p.RunAsGroup < 0 {
+		return fmt.Errorf("run_as_group must not be negative")
+	}
+	switch {
+	case p.SeccompProfile == SeccompRuntimeDefault, p.SeccompProfile == SeccompUnconfined:
+	case strings.HasPrefix(p.SeccompProfile, seccompLocalhost) && len(p.SeccompProfile) > len(seccompLocalhost):
+	default:
+		return fmt.Errorf("seccomp_profile must be %s, %s or %s<path>, got %q",
+			SeccompRuntimeDefault, SeccompUnconfined, seccompLocalhost, p.SeccompProfile)
+	}
+	for _, scratch := range p.ScratchPaths {
+		if !path.IsAbs(scratch) || path.Clean(scratch) == "/" {
+			return fmt.Errorf("scratch path %q must be an absolute directory below /", scratch)
+		}
+	}
+	if p.ActiveDeadlineSeconds < 0 {
+		return fmt.Errorf("active_deadline_seconds must not be negative")
+	}
+	quantities := map[string]string{
+		"scratch_size_limit":       p.ScratchSizeLimit,
+		"resources.cpu_request":    p.Resources.CPURequest,
+		"resources.cpu_limit":      p.Resources.CPULimit,
+		"resources.memory_request": p.Resources.MemoryRequest,
+		"resources.memory_limit":   p.Resources.MemoryLimit,
+	}
+	for field, value := range quantities {
+		if value == "" {
+			continue
+		}
+		if _, err := resource.ParseQuantity(value); err != nil {
+			return fmt.Errorf("%s: invalid quantity %q", field, value)
+		}
+	}
+	return nil
+}
+
+// LocalhostSeccompProfile returns the profile path when SeccompProfile
+// names a localhost profile.
+func (p *Policy) LocalhostSeccompProfile() (string, bool) {
+	return strings.CutPrefix(p.SeccompProfile, seccompLocalhost)
+}
+
+// ForProfile returns a copy of p completed with the language profile's
+// defaults: its resources win over the policy's, its scratch paths are
+// added, and its timeout replaces the deadline. A nil profile, as for a raw
+// Dockerfile, leaves the policy defaults in place.
+func (p *Policy) ForProfile(profile *profiles.Profile) Policy {
+	resolved := *p
+	resolved.DropCapabilities = append([]string(nil), p.DropCapabilities...)
+	resolved.ScratchPaths = append([]string(nil), p.ScratchPaths...)
+	if profile == nil {
+		return resolved
+	}
+
+	resources := profile.Resources
+	if resources.CPURequest == "" {
+		resources.CPURequest = p.Resources.CPURequest
+	}
+	if resources.CPULimit == "" {
+		resources.CPULimit = p.Resources.CPULimit
+	}
+	if resources.MemoryRequest == "" {
+		resources.MemoryRequest = p.Resources.MemoryRequest
+	}
+	if resources.MemoryLimit == "" {
+		resources.MemoryLimit = p.Resources.MemoryLimit
+	}
+	resolved.Resources = resources
+
+	for _, scratch := range profile.ScratchPaths {
+		if !containsPath(resolved.ScratchPaths, scratch) {
+			resolved.ScratchPaths = append(resolved.ScratchPaths, scratch)
+		}
+	}
+	if profile.TimeoutSeconds > 0 {
+		resolved.ActiveDeadlineSeconds = profile.TimeoutSeconds
+	}
+	return resolved
+}
+
+func containsPath(paths []string, candidate string) bool {
+	for _, existing := range paths {
+		if path.Clean(existing) == path.Clean(candidate) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sandboxpolicy
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"main/judge-agent/profiles"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Policy)
+		ok     bool
+	}{
+		{"default", func(*Policy) {}, true},
+		{"root user", func(p *Policy) { p.RunAsUser = 0 }, false},
+		{"negative group", func(p *Policy) { p.RunAsGroup = -1 }, false},
+		{"unconfined seccomp", func(p *Policy) { p.SeccompProfile = SeccompUnconfined }, true},
+		{"localhost seccomp", func(p *Policy) { p.SeccompProfile = "Localhost/profiles/judge.json" }, true},
+		{"localhost seccomp without path", func(p *Policy) { p.SeccompProfile = "Localhost/" }, false},
+		{"unknown seccomp", func(p *Policy) { p.SeccompProfile = "docker/default" }, false},
+		{"relative scratch path", func(p *Policy) { p.ScratchPaths = []string{"tmp"} }, false},
+		{"root scratch path", func(p *Policy) { p.ScratchPaths = []string{"/"} }, false},
+		{"negative deadline", func(p *Policy) { p.ActiveDeadlineSeconds = -1 }, false},
+		{"bad scratch size", func(p *Policy) { p.ScratchSizeLimit = "lots" }, false},
+		{"bad memory limit", func(p *Policy) { p.Resources.MemoryLimit = "1 GB" }, false},
+	}
+	for _, tt := range tests {
+		policy := Default()
+		tt.modify(policy)
+		if err := policy.Validate(); (err == nil) != tt.ok {
+			t.Errorf("%s: Validate() error = %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy string
+		ok     bool
+	}{
+		{"empty object keeps defaults", `{}`, true},
+		{"runtime class", `{"runtime_class_name": "gvisor"}`, true},
+		{"not json", `{"run_as_user": `, false},
+		{"root user", `{"run_as_user": 0}`, false},
+		{"bad seccomp", `{"seccomp_profile": "none"}`, false},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "sandbox.json")
+		if err := os.WriteFile(path, []byte(tt.policy), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := LoadFile(path)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: LoadFile() error = %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+	if _, err := LoadFile(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Errorf("missing file: LoadFile() succeeded")
+	}
+}
+
+func TestForProfile(t *testing.T) {
+	policy := Default()
+
+	resolved := policy.ForProfile(nil)
+	if resolved.Resources != policy.Resources || resolved.ActiveDeadlineSeconds != policy.ActiveDeadlineSeconds {
+		t.Errorf("ForProfile(nil) = %+v, want the policy defaults", resolved)
+	}
+
+	profile := &profiles.Profile{
+		Resources:      profiles.Resources{CPULimit: "2", MemoryLimit: "1Gi"},
+		ScratchPaths:   []string{"/tmp/", "/home/runner/.cache"},
+		TimeoutSeconds: 30,
+	}
+	resolved = policy.ForProfile(profile)
+	want := profiles.Resources{
+		CPURequest:    policy.Resources.CPURequest,
+		CPULimit:      "2",
+		MemoryRequest: policy.Resources.MemoryRequest,
+		MemoryLimit:   "1Gi",
+	}
+	if resolved.Resources != want {
+		t.Errorf("resources = %+v, want %+v", resolved.Resources, want)
+	}
+	if got, want := resolved.ScratchPaths, []string{"/tmp", "/home/runner/.cache"}; !slices.Equal(got, want) {
+		t.Errorf("scratch paths = %v, want %v", got, want)
+	}
+	if resolved.ActiveDeadlineSeconds != 30 {
+		t.Errorf("deadline = %d, want the profile timeout 30", resolved.ActiveDeadlineSeconds)
+	}
+
+	// The resolved copy must not share slices with the policy.
+	resolved.DropCapabilities[0] = "NET_RAW"
+	resolved.ScratchPaths[0] = "/var/tmp"
+	if policy.DropCapabilities[0] != "ALL" || policy.ScratchPaths[0] != "/tmp" {
+		t.Errorf("ForProfile() modified the policy: %+v", policy)
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testreport
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// jestResults is the part of `jest --json` output the judge reads.
+type jestResults struct {
+	TestResults []struct {
+		Name             string `json:"name"`
+		Status           string `json:"status"`
+		Message          string `json:"message"`
+		StartTime        int64  `json:"startTime"`
+		EndTime          int64  `json:"endTime"`
+		AssertionResults []struct {
+			AncestorTitles  []string `json:"ancestorTitles"`
+			Title           string   `json:"title"`
+			FullName        string   `json:"fullName"`
+			Status          string   `json:"status"`
+			Duration        *float64 `json:"duration"`
+			FailureMessages []string `json:"failureMessages"`
+		} `json:"assertionResults"`
+	} `json:"testResults"`
+}
+
+// ParseJest reads the results file written by `jest --json --outputFile`.
+func ParseJest(data []byte) (*Report, error) {
+	var results jestResults
+	if err := json.Unmarshal(data, &results); err != nil {
+		return nil, fmt.Errorf("parse jest results: %w", err)
+	}
+	report := &Report{}
+	for _, file := range results.TestResults {
+		suite := Suite{Name: file.Name}
+		if file.EndTime > file.StartTime {
+			suite.DurationMs = file.EndTime - file.StartTime
+		}
+		// A failed file with no assertions never ran, usually a syntax
+		// or import error; its message says why.
+		if file.Status == "failed" && len(file.AssertionResults) == 0 {
+			suite.Error = strings.TrimSpace(file.Message)
+			if suite.Error == "" {
+				suite.Error = "test suite failed to run"
+			}
+		}
+		for _, assertion := range file.AssertionResults {
+			c := Case{
+				Name:     assertion.Title,
+				FullName: assertion.FullName,
+				Failure:  strings.TrimSpace(strings.Join(assertion.FailureMessages, "\n")),
+			}
+			if c.FullName == "" {
+				c.FullName = strings.Join(append(append([]string(nil), assertion.AncestorTitles...), assertion.Title), " ")
+			}
+			if assertion.Duration != nil {
+				c.DurationMs = int64(*assertion.Duration)
+			}
+			switch assertion.Status {
+			case "passed":
+				c.Status = StatusPassed
+			case "failed":
+				c.Status = StatusFailed
+			default:
+				// pending, skipped, todo and disabled all mean not run.
+				c.Status = StatusSkipped
+			}
+			suite.Cases = append(suite.Cases, c)
+		}
+		report.Suites = append(report.Suites, suite)
+	}
+	return report, nil
+}
@@ -0,0 +1,105 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testreport
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseJest(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		want     []string
+		success  bool
+		suiteErr string
+	}{
+		{
+			name: "passing and skipped",
+			input: `{"testResults": [{"name": "/app/src/App.test.js", "status": "passed", "startTime": 100, "endTime": 150,
+				"assertionResults": [
+					{"ancestorTitles": ["App"], "title": "renders", "status": "passed", "duration": 12},
+					{"title": "later", "status": "todo"},
+					{"title": "off", "status": "pending"}
+				]}]}`,
+			want:    []string{"/app/src/App.test.js/renders=passed", "/app/src/App.test.js/later=skipped", "/app/src/App.test.js/off=skipped"},
+			success: true,
+		},
+		{
+			name: "failed assertion",
+			input: `{"testResults": [{"name": "a.test.js", "status": "failed",
+				"assertionResults": [{"title": "adds", "status": "failed", "failureMessages": ["expected 2"]}]}]}`,
+			want: []string{"a.test.js/adds=failed"},
+		},
+		{
+			name:     "suite that failed to run",
+			input:    `{"testResults": [{"name": "b.test.js", "status": "failed", "message": "  SyntaxError: Unexpected token  ", "assertionResults": []}]}`,
+			suiteErr: "SyntaxError: Unexpected token",
+		},
+		{
+			name:     "suite that failed to run without a message",
+			input:    `{"testResults": [{"name": "b.test.js", "status": "failed"}]}`,
+			suiteErr: "test suite failed to run",
+		},
+		{
+			name:  "no tests",
+			input: `{"testResults": []}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := Parse(FormatJest, []byte(tt.input))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if got := statuses(report); !slices.Equal(got, tt.want) {
+				t.Errorf("cases = %v, want %v", got, tt.want)
+			}
+			if report.Success != tt.success {
+				t.Errorf("Success = %v, want %v", report.Success, tt.success)
+			}
+			if tt.suiteErr != "" && report.Suites[0].Error != tt.suiteErr {
+				t.Errorf("suite error = %q, want %q", report.Suites[0].Error, tt.suiteErr)
+			}
+		})
+	}
+}
+
+func TestParseJestNames(t *testing.T) {
+	report, err := ParseJest([]byte(`{"testResults": [{"name": "a.test.js", "startTime": 10, "endTime": 40, "assertionResults": [
+		{"ancestorTitles": ["math", "add"], "title": "sums", "status": "passed", "duration": 7.9},
+		{"title": "kept", "fullName": "given full name", "status": "passed"}
+	]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	suite := report.Suites[0]
+	if suite.DurationMs != 30 {
+		t.Errorf("suite duration = %d, want 30", suite.DurationMs)
+	}
+	if got := suite.Cases[0]; got.FullName != "math add sums" || got.DurationMs != 7 {
+		t.Errorf("first case = %+v, want full name from ancestors and 7ms", got)
+	}
+	if got := suite.Cases[1].FullName; got != "given full name" {
+		t.Errorf("second case full name = %q, want Jest's", got)
+	}
+}
+
+func TestParseUnknownFormat(t *testing.T) {
+	if _, err := Parse("xunit", []byte("<assemblies/>")); err == nil {
+		t.Errorf("Parse() accepted an unknown format")
+	}
+}
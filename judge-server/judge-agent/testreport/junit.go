@@ -0,0 +1,145 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testreport
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// junitSuite is a <testsuite>, or the <testsuites> root, which has the
+// same shape. Some runners nest suites.
+type junitSuite struct {
+	XMLName xml.Name
+	Name    string       `xml:"name,attr"`
+	Time    string       `xml:"time,attr"`
+	Suites  []junitSuite `xml:"testsuite"`
+	Cases   []junitCase  `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string `xml:"name,attr"`
+	Classname string `xml:"classname,attr"`
+	Time      string `xml:"time,attr"`
+	// Status and Result are GoogleTest's way of marking skipped tests.
+	Status    string        `xml:"status,attr"`
+	Result    string        `xml:"result,attr"`
+	Failures  []junitResult `xml:"failure"`
+	Errors    []junitResult `xml:"error"`
+	Skipped   *junitResult  `xml:"skipped"`
+	SystemOut string        `xml:"system-out"`
+	SystemErr string        `xml:"system-err"`
+}
+
+type junitResult struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// ParseJUnit reads a JUnit XML report, as written by pytest, GoogleTest,
+// Catch2, Vitest and most other runners.
+func ParseJUnit(data []byte) (*Report, error) {
+	var root junitSuite
+	if err := xml.NewDecoder(bytes.NewReader(data)).Decode(&root); err != nil {
+		return nil, fmt.Errorf("parse junit report: %w", err)
+	}
+	report := &Report{}
+	switch root.XMLName.Local {
+	case "testsuites":
+		for _, suite := range root.Suites {
+			report.Suites = appendJUnitSuite(report.Suites, suite)
+		}
+	case "testsuite":
+		report.Suites = appendJUnitSuite(report.Suites, root)
+	default:
+		return nil, fmt.Errorf("parse junit report: unexpected root element <%s>", root.XMLName.Local)
+	}
+	return report, nil
+}
+
+// appendJUnitSuite flattens suite and the suites nested in it.
+func appendJUnitSuite(suites []Suite, suite junitSuite) []Suite {
+	if len(suite.Cases) > 0 || len(suite.Suites) == 0 {
+		converted := Suite{Name: suite.Name, DurationMs: parseSeconds(suite.Time)}
+		var caseTotal int64
+		for _, testCase := range suite.Cases {
+			c := junitCaseResult(testCase)
+			caseTotal += c.DurationMs
+			converted.Cases = append(converted.Cases, c)
+		}
+		if converted.DurationMs == 0 {
+			converted.DurationMs = caseTotal
+		}
+		suites = append(suites, converted)
+	}
+	for _, nested := range suite.Suites {
+		suites = appendJUnitSuite(suites, nested)
+	}
+	return suites
+}
+
+func junitCaseResult(testCase junitCase) Case {
+	c := Case{
+		Name:       testCase.Name,
+		FullName:   testCase.Name,
+		Status:     StatusPassed,
+		DurationMs: parseSeconds(testCase.Time),
+	}
+	if testCase.Classname != "" {
+		c.FullName = testCase.Classname + "." + testCase.Name
+	}
+	problems := append(append([]junitResult(nil), testCase.Failures...), testCase.Errors...)
+	switch {
+	case len(problems) > 0:
+		c.Status = StatusFailed
+		var failure []string
+		for _, problem := range problems {
+			failure = appendNonEmpty(failure, problem.Message, problem.Text)
+		}
+		failure = appendNonEmpty(failure, testCase.SystemOut, testCase.SystemErr)
+		c.Failure = strings.Join(failure, "\n")
+	case testCase.Skipped != nil || testCase.Status == "notrun" || testCase.Result == "skipped" || testCase.Result == "suppressed":
+		c.Status = StatusSkipped
+	}
+	return c
+}
+
+// appendNonEmpty appends the trimmed values that are not blank and not a
+// repeat of the previous one, since runners often put the same text in a
+// failure's message and body.
+func appendNonEmpty(lines []string, values ...string) []string {
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" || (len(lines) > 0 && lines[len(lines)-1] == value) {
+			continue
+		}
+		lines = append(lines, value)
+	}
+	return lines
+}
+
+// parseSeconds converts a JUnit time attribute to milliseconds. Some
+// runners group thousands with commas; anything unparsable is zero.
+func parseSeconds(value string) int64 {
+	seconds, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(value), ",", ""), 64)
+	if err != nil || seconds < 0 || math.IsInf(seconds, 0) || math.IsNaN(seconds) {
+		return 0
+	}
+	return int64(math.Round(seconds * 1000))
+}
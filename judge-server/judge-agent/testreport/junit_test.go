@@ -0,0 +1,125 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testreport
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseJUnit(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []string
+		success bool
+		wantErr bool
+	}{
+		{
+			name: "single suite root",
+			input: `<testsuite name="pytest" time="0.5">
+  <testcase classname="test_math" name="test_add" time="0.1"/>
+  <testcase classname="test_math" name="test_div" time="0.2"><failure message="ZeroDivisionError">trace</failure></testcase>
+</testsuite>`,
+			want: []string{"pytest/test_add=passed", "pytest/test_div=failed"},
+		},
+		{
+			name: "nested suites are flattened",
+			input: `<testsuites>
+  <testsuite name="outer">
+    <testsuite name="inner">
+      <testcase name="a"/>
+    </testsuite>
+    <testcase name="b"/>
+  </testsuite>
+  <testsuite name="other"><testcase name="c"/></testsuite>
+</testsuites>`,
+			want:    []string{"outer/b=passed", "inner/a=passed", "other/c=passed"},
+			success: true,
+		},
+		{
+			name: "googletest notrun and skipped results",
+			input: `<testsuites name="AllTests">
+  <testsuite name="Math">
+    <testcase name="Adds" status="run" result="completed" classname="Math"/>
+    <testcase name="DISABLED_Divides" status="notrun" result="suppressed" classname="Math"/>
+    <testcase name="Skips" status="run" result="skipped" classname="Math"><skipped message="GTEST_SKIP"/></testcase>
+  </testsuite>
+</testsuites>`,
+			want:    []string{"Math/Adds=passed", "Math/DISABLED_Divides=skipped", "Math/Skips=skipped"},
+			success: true,
+		},
+		{
+			name:  "errors count as failures",
+			input: `<testsuite name="s"><testcase name="t"><error message="boom"/></testcase></testsuite>`,
+			want:  []string{"s/t=failed"},
+		},
+		{
+			name:  "only skipped tests do not pass",
+			input: `<testsuite name="s"><testcase name="t"><skipped/></testcase></testsuite>`,
+			want:  []string{"s/t=skipped"},
+		},
+		{
+			name:    "unexpected root",
+			input:   `<report/>`,
+			wantErr: true,
+		},
+		{
+			name:    "not XML",
+			input:   `{"testResults": []}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := Parse(FormatJUnit, []byte(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := statuses(report); !slices.Equal(got, tt.want) {
+				t.Errorf("cases = %v, want %v", got, tt.want)
+			}
+			if report.Success != tt.success {
+				t.Errorf("Success = %v, want %v", report.Success, tt.success)
+			}
+		})
+	}
+}
+
+func TestParseJUnitFailureAndDurations(t *testing.T) {
+	report, err := ParseJUnit([]byte(`<testsuite name="s">
+  <testcase classname="pkg.Test" name="t" time="1,234.5">
+    <failure message="expected 2">expected 2</failure>
+    <system-out>got 3</system-out>
+  </testcase>
+</testsuite>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	suite := report.Suites[0]
+	c := suite.Cases[0]
+	if c.FullName != "pkg.Test.t" {
+		t.Errorf("FullName = %q, want pkg.Test.t", c.FullName)
+	}
+	if c.Failure != "expected 2\ngot 3" {
+		t.Errorf("Failure = %q, want the message once and the output", c.Failure)
+	}
+	if c.DurationMs != 1234500 || suite.DurationMs != 1234500 {
+		t.Errorf("durations = %d, %d, want 1234500 from the cases", c.DurationMs, suite.DurationMs)
+	}
+}
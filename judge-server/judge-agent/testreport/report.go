@@ -0,0 +1,121 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package testreport turns the result files test runners write into one
+// report model, so the judge does not care which toolchain ran the tests.
+package testreport
+
+import "fmt"
+
+// Report formats.
+const (
+	FormatJest  = "jest"
+	FormatJUnit = "junit"
+	FormatTAP   = "tap"
+)
+
+// Supported reports whether Parse understands format.
+func Supported(format string) bool {
+	switch format {
+	case FormatJest, FormatJUnit, FormatTAP:
+		return true
+	}
+	return false
+}
+
+// Status is the outcome of a single test case.
+type Status string
+
+const (
+	StatusPassed  Status = "passed"
+	StatusFailed  Status = "failed"
+	StatusSkipped Status = "skipped"
+)
+
+// Case is one test.
+type Case struct {
+	Name       string `json:"name"`
+	FullName   string `json:"full_name,omitempty"`
+	Status     Status `json:"status"`
+	DurationMs int64  `json:"duration_ms"`
+	Failure    string `json:"failure,omitempty"`
+}
+
+// Suite groups the cases of one test file or suite. Error is set when the
+// suite failed as a whole, for example because it did not compile.
+type Suite struct {
+	Name       string `json:"name"`
+	DurationMs int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+	Cases      []Case `json:"cases,omitempty"`
+}
+
+// Report is the result of a whole test run. Success requires at least one
+// test, every test passing or skipped, and no suite error.
+type Report struct {
+	Format  string  `json:"format"`
+	Success bool    `json:"success"`
+	Total   int     `json:"total"`
+	Passed  int     `json:"passed"`
+	Failed  int     `json:"failed"`
+	Skipped int     `json:"skipped"`
+	Suites  []Suite `json:"suites,omitempty"`
+	// Error explains a run that produced no usable report.
+	Error string `json:"error,omitempty"`
+}
+
+// Summarize recomputes the counts and Success from the suites.
+func (r *Report) Summarize() {
+	r.Total, r.Passed, r.Failed, r.Skipped = 0, 0, 0, 0
+	suiteErrors := false
+	for _, suite := range r.Suites {
+		if suite.Error != "" {
+			suiteErrors = true
+		}
+		for _, c := range suite.Cases {
+			r.Total++
+			switch c.Status {
+			case StatusPassed:
+				r.Passed++
+			case StatusFailed:
+				r.Failed++
+			default:
+				r.Skipped++
+			}
+		}
+	}
+	r.Success = r.Error == "" && !suiteErrors && r.Failed == 0 && r.Passed > 0
+}
+
+// Parse reads a report file in the given format.
+func Parse(format string, data []byte) (*Report, error) {
+	var report *Report
+	var err error
+	switch format {
+	case FormatJest:
+		report, err = ParseJest(data)
+	case FormatJUnit:
+		report, err = ParseJUnit(data)
+	case FormatTAP:
+		report, err = ParseTAP(data)
+	default:
+		return nil, fmt.Errorf("unknown test report format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	report.Format = format
+	report.Summarize()
+	return report, nil
+}
@@ -0,0 +1,158 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testreport
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	tapTestLine = regexp.MustCompile(`^(not )?ok\b(?:\s+(\d+))?\s*(.*)$`)
+	tapPlan     = regexp.MustCompile(`^1\.\.(\d+)`)
+)
+
+// ParseTAP reads a TAP stream, versions 12 to 14. Subtests are indented and
+// summarized by their parent's test line, so only top-level tests become
+// cases. A YAML diagnostic block after a failed test is its failure output.
+func ParseTAP(data []byte) (*Report, error) {
+	suite := Suite{}
+	planned := -1
+	seen := false
+	var yaml []string
+	inYAML := false
+	for _, line := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n") {
+		if inYAML {
+			if strings.TrimSpace(line) == "..." {
+				inYAML = false
+				applyTAPDiagnostics(&suite.Cases[len(suite.Cases)-1], yaml)
+				yaml = nil
+			} else {
+				yaml = append(yaml, line)
+			}
+			continue
+		}
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			if strings.TrimSpace(line) == "---" && len(suite.Cases) > 0 {
+				inYAML = true
+			}
+			continue
+		}
+		if match := tapTestLine.FindStringSubmatch(line); match != nil {
+			seen = true
+			suite.Cases = append(suite.Cases, tapCase(match[1] == "", match[2], match[3], len(suite.Cases)+1))
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, "TAP version"):
+			seen = true
+		case tapPlan.MatchString(line):
+			seen = true
+			planned, _ = strconv.Atoi(tapPlan.FindStringSubmatch(line)[1])
+		case strings.HasPrefix(line, "Bail out!"):
+			seen = true
+			suite.Error = "bailed out"
+			if reason := strings.TrimSpace(strings.TrimPrefix(line, "Bail out!")); reason != "" {
+				suite.Error += ": " + reason
+			}
+		case strings.HasPrefix(line, "#") && len(suite.Cases) > 0:
+			// Free-form diagnostics explain the failure just above them.
+			last := &suite.Cases[len(suite.Cases)-1]
+			if last.Status == StatusFailed {
+				last.Failure = strings.Join(appendNonEmpty(splitNonEmpty(last.Failure), strings.TrimPrefix(line, "#")), "\n")
+			}
+		}
+	}
+	if inYAML {
+		applyTAPDiagnostics(&suite.Cases[len(suite.Cases)-1], yaml)
+	}
+	if !seen {
+		return nil, fmt.Errorf("parse tap report: no plan or test lines")
+	}
+	if suite.Error == "" && planned >= 0 && len(suite.Cases) < planned {
+		suite.Error = fmt.Sprintf("planned %d tests but only %d ran", planned, len(suite.Cases))
+	}
+	for _, c := range suite.Cases {
+		suite.DurationMs += c.DurationMs
+	}
+	return &Report{Suites: []Suite{suite}}, nil
+}
+
+// tapCase builds a case from a test line's parts. A TODO test is expected
+// to fail, so failing it counts as not run rather than failed.
+func tapCase(ok bool, number, rest string, position int) Case {
+	description, directive, _ := strings.Cut(rest, " # ")
+	if strings.HasPrefix(rest, "# ") {
+		description, directive = "", strings.TrimPrefix(rest, "# ")
+	}
+	description = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(description), "- "))
+	if description == "" {
+		if number == "" {
+			number = strconv.Itoa(position)
+		}
+		description = "test " + number
+	}
+	c := Case{Name: description, FullName: description, Status: StatusPassed}
+	directive = strings.ToUpper(strings.TrimSpace(directive))
+	switch {
+	case strings.HasPrefix(directive, "SKIP"):
+		c.Status = StatusSkipped
+	case strings.HasPrefix(directive, "TODO") && !ok:
+		c.Status = StatusSkipped
+	case !ok:
+		c.Status = StatusFailed
+	}
+	return c
+}
+
+// applyTAPDiagnostics takes the duration from a YAML block and, for a
+// failed test, keeps the whole block as its failure output.
+func applyTAPDiagnostics(c *Case, yaml []string) {
+	indent := -1
+	for _, line := range yaml {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if width := len(line) - len(strings.TrimLeft(line, " \t")); indent < 0 || width < indent {
+			indent = width
+		}
+	}
+	var block []string
+	for _, line := range yaml {
+		if len(line) >= indent && indent >= 0 {
+			line = line[indent:]
+		}
+		block = append(block, strings.TrimRight(line, " \t"))
+		key, value, found := strings.Cut(line, ":")
+		if found && key == "duration_ms" {
+			if ms, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil && ms >= 0 {
+				c.DurationMs = int64(math.Round(ms))
+			}
+		}
+	}
+	if c.Status == StatusFailed {
+		c.Failure = strings.Join(appendNonEmpty(splitNonEmpty(c.Failure), strings.Join(block, "\n")), "\n")
+	}
+}
+
+func splitNonEmpty(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return []string{text}
+}
@@ -0,0 +1,128 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testreport
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// statuses lists every case as "suite/name=status" in report order.
+func statuses(report *Report) []string {
+	var out []string
+	for _, suite := range report.Suites {
+		for _, c := range suite.Cases {
+			out = append(out, suite.Name+"/"+c.Name+"="+string(c.Status))
+		}
+	}
+	return out
+}
+
+func TestParseTAP(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		want      []string
+		success   bool
+		suiteErr  string
+		failure   string
+		durations []int64
+	}{
+		{
+			name:    "plain",
+			input:   "TAP version 13\n1..2\nok 1 - adds\nok 2 - subtracts\n",
+			want:    []string{"/adds=passed", "/subtracts=passed"},
+			success: true,
+		},
+		{
+			name:    "skip and todo directives",
+			input:   "1..4\nok 1 - fast # SKIP no network\nnot ok 2 - later # TODO not written\nok 3 - done early # todo\nnot ok 4 # skip\n",
+			want:    []string{"/fast=skipped", "/later=skipped", "/done early=passed", "/test 4=skipped"},
+			success: true,
+		},
+		{
+			name:      "failure with YAML block",
+			input:     "TAP version 14\n1..1\nnot ok 1 - divides\n  ---\n  message: 'expected 2'\n  duration_ms: 12.4\n  ...\n",
+			want:      []string{"/divides=failed"},
+			failure:   "message: 'expected 2'\nduration_ms: 12.4",
+			durations: []int64{12},
+		},
+		{
+			name:      "YAML block on a passing test only sets duration",
+			input:     "1..1\nok 1 - adds\n  ---\n  duration_ms: 3\n  ...\n",
+			want:      []string{"/adds=passed"},
+			success:   true,
+			durations: []int64{3},
+		},
+		{
+			name:    "comment diagnostics",
+			input:   "1..1\nnot ok 1 - divides\n# got 3\n# want 2\n",
+			want:    []string{"/divides=failed"},
+			failure: "got 3\nwant 2",
+		},
+		{
+			name:    "indented subtests are summarized by their parent",
+			input:   "1..1\n    ok 1 - inner\n    1..1\nok 1 - outer\n",
+			want:    []string{"/outer=passed"},
+			success: true,
+		},
+		{
+			name:     "bail out",
+			input:    "1..2\nok 1 - adds\nBail out! database down\n",
+			want:     []string{"/adds=passed"},
+			suiteErr: "bailed out: database down",
+		},
+		{
+			name:     "fewer tests than planned",
+			input:    "1..3\nok 1\nok 2\n",
+			want:     []string{"/test 1=passed", "/test 2=passed"},
+			suiteErr: "planned 3 tests but only 2 ran",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := Parse(FormatTAP, []byte(tt.input))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if got := statuses(report); !slices.Equal(got, tt.want) {
+				t.Errorf("cases = %v, want %v", got, tt.want)
+			}
+			if report.Success != tt.success {
+				t.Errorf("Success = %v, want %v", report.Success, tt.success)
+			}
+			suite := report.Suites[0]
+			if suite.Error != tt.suiteErr {
+				t.Errorf("suite error = %q, want %q", suite.Error, tt.suiteErr)
+			}
+			if tt.failure != "" && suite.Cases[0].Failure != tt.failure {
+				t.Errorf("failure = %q, want %q", suite.Cases[0].Failure, tt.failure)
+			}
+			for i, want := range tt.durations {
+				if got := suite.Cases[i].DurationMs; got != want {
+					t.Errorf("case %d duration = %d, want %d", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseTAPRejectsOtherOutput(t *testing.T) {
+	_, err := ParseTAP([]byte("PASS src/App.test.js\n"))
+	if err == nil || !strings.Contains(err.Error(), "no plan or test lines") {
+		t.Errorf("ParseTAP() error = %v, want no plan or test lines", err)
+	}
+}
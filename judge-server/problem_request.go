@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"main/judge-agent/judging"
+	"main/judge-agent/mcptransport"
+	"main/judge-agent/problems"
+)
+
+// problemSummary is what the analyzer is told about a problem package. It
+// leaves out tests, the checker source and the reference solution.
+type problemSummary struct {
+	ID          string         `json:"id"`
+	Version     string         `json:"version"`
+	Title       string         `json:"title,omitempty"`
+	Category    string         `json:"category"`
+	Profile     string         `json:"profile"`
+	Limits      judging.Limits `json:"limits"`
+	CheckerType string         `json:"checker_type,omitempty"`
+	TestCount   int            `json:"test_count"`
+}
+
+func writeProblemError(w http.ResponseWriter, err error) {
+	if errors.Is(err, problems.ErrNotFound) {
+		writeJSONError(w, http.StatusNotFound, "problem_not_found", err.Error())
+		return
+	}
+	log.Printf("Failed to load problem package: %v", err)
+	writeJSONError(w, http.StatusUnprocessableEntity, "invalid_problem", err.Error())
+}
+
+// withProblemContext adds a "problem" summary to every data part of an A2A
+// message that names a problemId with a package, and fills in the problem
+// description when the caller sent none. Requests it cannot parse are
+// passed on untouched for the A2A handler to reject.
+func withProblemContext(store *problems.Store, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			next.ServeHTTP(w, r)
+			return
+		}
+		// Messages may carry a submission's code, so they get the same
+		// bound as a deploy request.
+		limits := mcptransport.ContextLimitsFromEnv()
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limits.MaxEncodedBytes()+maxDeployEnvelopeBytes))
+		r.Body.Close()
+		if err != nil {
+			writeDeployRequestError(w, err)
+			return
+		}
+		if enriched, ok := addProblemContext(store, body); ok {
+			body = enriched
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		r.ContentLength = int64(len(body))
+		next.ServeHTTP(w, r)
+	})
+}
+
+func addProblemContext(store *problems.Store, body []byte) ([]byte, bool) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var request map[string]any
+	if err := decoder.Decode(&request); err != nil {
+		return nil, false
+	}
+	params, _ := request["params"].(map[string]any)
+	message, _ := params["message"].(map[string]any)
+	parts, _ := message["parts"].([]any)
+
+	changed := false
+	for _, part := range parts {
+		fields, _ := part.(map[string]any)
+		if fields["kind"] != "data" {
+			continue
+		}
+		data, _ := fields["data"].(map[string]any)
+		id := problemIDOf(data)
+		if id == "" {
+			continue
+		}
+		problem, err := store.Get(id)
+		if err != nil {
+			if !errors.Is(err, problems.ErrNotFound) {
+				log.Printf("Analyzer: problem %s: %v", id, err)
+			}
+			continue
+		}
+		manifest := problem.Manifest
+		data["problem"] = problemSummary{
+			ID:          manifest.ID,
+			Version:     manifest.Version,
+			Title:       manifest.Title,
+			Category:    manifest.Category,
+			Profile:     manifest.Profile,
+			Limits:      manifest.Limits,
+			CheckerType: manifest.Checker.Type,
+			TestCount:   len(problem.Tests),
+		}
+		if description, _ := data["problemDescription"].(string); description == "" && manifest.Description != "" {
+			data["problemDescription"] = manifest.Description
+		}
+		changed = true
+	}
+	if !changed {
+		return nil, false
+	}
+	enriched, err := json.Marshal(request)
+	if err != nil {
+		return nil, false
+	}
+	return enriched, true
+}
+
+// problemIDOf reads problemId, or problem_id, as sent by the web app,
+// which may be a number or a string.
+func problemIDOf(data map[string]any) string {
+	for _, key := range []string{"problemId", "problem_id"} {
+		switch id := data[key].(type) {
+		case string:
+			return id
+		case json.Number:
+			if _, err := strconv.ParseInt(id.String(), 10, 64); err == nil {
+				return id.String()
+			}
+		}
+	}
+	return ""
+}
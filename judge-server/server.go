@@ -4,8 +4,11 @@ import (
 	"archive/tar"
 	"bytes"
 	"context"
-	"encoding/base64"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net"
@@ -15,36 +18,88 @@ import (
 	"strconv"
 	"strings"
 
-	"github.com/a2aproject/a2a-go/a2a"
+	"github.com/a2aproject/a2a-go/a2aclient"
 	"github.com/a2aproject/a2a-go/a2asrv"
 
 	"main/judge-agent/app"
+	"main/judge-agent/deployjobs"
+	"main/judge-agent/dockerpolicy"
+	"main/judge-agent/judging"
 	"main/judge-agent/mcptransport"
+	"main/judge-agent/problems"
+	"main/judge-agent/profiles"
+	"main/judge-agent/sandboxpolicy"
 
 	"google.golang.org/adk/agent"
 	"google.golang.org/adk/agent/remoteagent"
 	"google.golang.org/adk/cmd/launcher"
 	"google.golang.org/adk/cmd/launcher/full"
-	"google.golang.org/adk/runner"
-	"google.golang.org/adk/server/adka2a"
-	"google.golang.org/adk/session"
 )
 
 type deployRequest struct {
-	DockerFile    string `json:"docker_file"`
-	Base64TarFile string `json:"base64TarFile,omitempty"`
+	DockerFile    string              `json:"docker_file,omitempty"`
+	Profile       string              `json:"profile,omitempty"`
+	ProblemID     string              `json:"problem_id,omitempty"`
+	Tests         []judging.TestCase  `json:"tests,omitempty"`
+	Limits        judging.Limits      `json:"limits,omitempty"`
+	Checker       judging.CheckerSpec `json:"checker,omitempty"`
+	RunTestSuite  bool                `json:"run_test_suite,omitempty"`
+	Base64TarFile string              `json:"base64TarFile,omitempty"`
 }
 
-type deployResponse struct {
-	ContainerName string `json:"container_name"`
-	ContainerID   string `json:"container_id"`
-	ImageName     string `json:"image_name"`
-	ImageID       string `json:"image_id"`
-	BuildLogs     string `json:"build_logs"`
-	BuildFailed   bool   `json:"build_failed"`
+type shutdownRequest struct {
+	ContainerID string `json:"container_id"`
+	DeleteImage bool   `json:"delete_image,omitempty"`
+	ImageName   string `json:"image_name,omitempty"`
 }
 
-func startJudgeAgentServer() string {
+type shutdownResponse struct {
+	ContainerName  string `json:"container_name"`
+	Message        string `json:"message"`
+	AlreadyDeleted bool   `json:"already_deleted"`
+	ImageDeleted   bool   `json:"image_deleted"`
+}
+
+type errorResponse struct {
+	Code    string `json:"code"`
+	Error   string `json:"error"`
+	Details any    `json:"details,omitempty"`
+}
+
+// Paths of the A2A agents besides the analyzer.
+const (
+	dockerPath       = "/docker"
+	plannerPath      = "/plan"
+	orchestratorPath = "/orchestrate"
+)
+
+// agentToken is the bearer token the docker, planner and orchestrator
+// endpoints require, from JUDGE_AGENT_TOKEN. Without one, a random token
+// keeps them usable only from this process.
+func agentToken() string {
+	if token := strings.TrimSpace(os.Getenv("JUDGE_AGENT_TOKEN")); token != "" {
+		return token
+	}
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		log.Fatalf("Failed to generate an agent token: %v", err)
+	}
+	log.Printf("JUDGE_AGENT_TOKEN is not set; the agent endpoints only accept this process")
+	return hex.EncodeToString(random)
+}
+
+// bearerTransport adds a bearer token to every request.
+type bearerTransport struct {
+	token string
+}
+
+func (t bearerTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.Header.Set("Authorization", "Bearer "+t.token)
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func startJudgeAgentServer(token string) string {
 	portEnv := strings.TrimSpace(os.Getenv("JUDGE_SERVER_PORT"))
 	listenAddr := "0.0.0.0:0"
 	if portEnv != "" {
@@ -66,37 +121,61 @@ func startJudgeAgentServer() string {
 
 	go func() {
 		ctx := context.Background()
-		analyzerAgent := app.NewAnalyzerAgent(ctx)
-
 		analyzerPath := "/analyze"
-		analyzerAgentCard := &a2a.AgentCard{
-			Name:               analyzerAgent.Name(),
-			Skills:             adka2a.BuildAgentSkills(analyzerAgent),
-			PreferredTransport: a2a.TransportProtocolJSONRPC,
-			URL:                baseURL.JoinPath(analyzerPath).String(),
-			Capabilities:       a2a.AgentCapabilities{Streaming: true},
+		analyzer, err := app.NewAnalyzerEndpoint(ctx, baseURL.JoinPath(analyzerPath).String())
+		if err != nil {
+			log.Fatalf("Failed to create the analyzer agent: %v", err)
 		}
 
 		mux := http.NewServeMux()
-		mux.Handle(a2asrv.WellKnownAgentCardPath, a2asrv.NewStaticAgentCardHandler(analyzerAgentCard))
-
-		analyzerExecutor := adka2a.NewExecutor(adka2a.ExecutorConfig{
-			RunnerConfig: runner.Config{
-				AppName:        analyzerAgent.Name(),
-				Agent:          analyzerAgent,
-				SessionService: session.InMemoryService(),
-			},
-		})
+		// The analyzer's card stays at the root for clients that predate
+		// the other agents; every agent's card is also under its own path.
+		mux.Handle(a2asrv.WellKnownAgentCardPath, a2asrv.NewStaticAgentCardHandler(analyzer.Card))
+		mountEndpoint(mux, analyzerPath, analyzer, withProblemContext(problems.Active(), analyzer.Handler))
+
+		for _, served := range []struct {
+			path string
+			new  func(context.Context) (agent.Agent, error)
+		}{
+			{dockerPath, app.NewDockerAgent},
+			{plannerPath, app.NewPlannerAgent},
+			{orchestratorPath, app.NewJudgeOrchestrator},
+		} {
+			a, err := served.new(ctx)
+			if err != nil {
+				log.Fatalf("Failed to create the agent served at %s: %v", served.path, err)
+			}
+			// These agents deploy containers, so unlike the analyzer they
+			// are not open to anyone who can reach the port.
+			endpoint := app.NewEndpoint(a, baseURL.JoinPath(served.path).String(), nil)
+			endpoint.RequireToken(token)
+			mountEndpoint(mux, served.path, endpoint, endpoint.Handler)
+		}
 
-		analyzerRequestHandler := a2asrv.NewHandler(analyzerExecutor)
-		analyzerJSONRPCHandler := a2asrv.NewJSONRPCHandler(analyzerRequestHandler)
-		mux.Handle(analyzerPath, analyzerJSONRPCHandler)
-		mux.Handle(analyzerPath+"/", analyzerJSONRPCHandler)
+		jobs := deployjobs.NewStore()
 		mux.HandleFunc("/deploy", func(w http.ResponseWriter, r *http.Request) {
-			handleDeploy(w, r)
+			handleDeploy(w, r, jobs)
+		})
+		mux.HandleFunc("GET /deploy/{id}", func(w http.ResponseWriter, r *http.Request) {
+			handleDeployStatus(w, r, jobs)
+		})
+		mux.HandleFunc("GET /deploy/{id}/logs", func(w http.ResponseWriter, r *http.Request) {
+			handleDeployLogs(w, r, jobs)
+		})
+		mux.HandleFunc("DELETE /deploy/{id}", func(w http.ResponseWriter, r *http.Request) {
+			handleDeployCancel(w, r, jobs)
 		})
+		mux.HandleFunc("/shutdown", handleShutdown)
+		mux.HandleFunc("DELETE /containers/{name}", handleDeleteContainer)
+		mux.HandleFunc("GET /containers/{name}/logs", handleContainerLogs)
 
-		err := http.Serve(listener, mux)
+		reaper := mcptransport.NewReaper(mcptransport.ReaperConfigFromEnv())
+		go reaper.Run(ctx)
+		mux.HandleFunc("GET /gc/stats", func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, http.StatusOK, reaper.Stats())
+		})
+
+		err = http.Serve(listener, mux)
 
 		log.Printf("A2A server stopped: %v", err)
 	}()
@@ -104,67 +183,268 @@ func startJudgeAgentServer() string {
 	return baseURL.String()
 }
 
-func handleDeploy(w http.ResponseWriter, r *http.Request) {
+// mountEndpoint serves endpoint's card under path and handler at path.
+func mountEndpoint(mux *http.ServeMux, path string, endpoint *app.Endpoint, handler http.Handler) {
+	mux.Handle(path+a2asrv.WellKnownAgentCardPath, a2asrv.NewStaticAgentCardHandler(endpoint.Card))
+	mux.Handle(path, handler)
+	mux.Handle(path+"/", handler)
+}
+
+func handleDeploy(w http.ResponseWriter, r *http.Request, jobs *deployjobs.Store) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
 		return
 	}
 
-	var payload deployRequest
-	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
-		http.Error(w, "invalid json body", http.StatusBadRequest)
+	payload, buildContents, err := readDeployRequest(w, r, mcptransport.ContextLimitsFromEnv())
+	if err != nil {
+		writeDeployRequestError(w, err)
 		return
 	}
-	if payload.DockerFile == "" {
-		http.Error(w, "docker_file is required", http.StatusBadRequest)
-		return
+	if payload.ProblemID != "" {
+		problem, err := problems.Active().Get(payload.ProblemID)
+		if err != nil {
+			writeProblemError(w, err)
+			return
+		}
+		if payload.Profile, err = mcptransport.ProblemProfile(problem, payload.DockerFile, payload.Profile); err != nil {
+			code := "problem_build_spec"
+			if !errors.Is(err, mcptransport.ErrProblemBuildSpec) {
+				code = "unknown_profile"
+			}
+			writeJSONError(w, http.StatusBadRequest, code, err.Error())
+			return
+		}
 	}
-
-	var buildContents bytes.Buffer
-	if payload.Base64TarFile != "" {
-		decoded, err := base64.RawURLEncoding.DecodeString(payload.Base64TarFile)
+	switch {
+	case payload.Profile != "" && payload.DockerFile != "":
+		writeJSONError(w, http.StatusBadRequest, "conflicting_build_spec", "docker_file and profile are mutually exclusive")
+		return
+	case payload.Profile != "":
+		profile, err := profiles.Lookup(payload.Profile)
 		if err != nil {
-			http.Error(w, "invalid base64TarFile", http.StatusBadRequest)
+			writeJSONError(w, http.StatusBadRequest, "unknown_profile", err.Error())
 			return
 		}
-		if _, err := buildContents.Write(decoded); err != nil {
-			http.Error(w, "failed to buffer build context", http.StatusInternalServerError)
+		if payload.RunTestSuite && len(profile.TestCommand) == 0 {
+			writeJSONError(w, http.StatusBadRequest, "no_test_suite", fmt.Sprintf("profile %q has no test command", profile.Name))
 			return
 		}
+	case payload.DockerFile == "":
+		writeJSONError(w, http.StatusBadRequest, "missing_build_spec", "docker_file or profile is required")
+		return
+	case !mcptransport.DockerfilesAllowed():
+		writeJSONError(w, http.StatusForbidden, "docker_file_disabled", mcptransport.ErrDockerfileNotAllowed.Error())
+		return
+	default:
+		policy, err := dockerpolicy.Active()
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "policy_unavailable", err.Error())
+			return
+		}
+		if err := policy.Validate(payload.DockerFile); err != nil {
+			var violations *dockerpolicy.ViolationError
+			errors.As(err, &violations)
+			writeJSON(w, http.StatusUnprocessableEntity, errorResponse{
+				Code:    "policy_violation",
+				Error:   err.Error(),
+				Details: violations,
+			})
+			return
+		}
+	}
+
+	if err := payload.Checker.Validate(); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_checker", err.Error())
+		return
+	}
+	if err := mcptransport.CheckClientChecker(payload.Checker); err != nil {
+		writeJSONError(w, http.StatusForbidden, "docker_file_disabled", err.Error())
+		return
+	}
+
+	if buildContents.Len() > 0 {
 		logBuildContext(buildContents.Bytes())
 	}
 
-	_, output, err := mcptransport.DeployContainer(
+	job := jobs.Start(mcptransport.Input{
+		DockerFile:    payload.DockerFile,
+		Profile:       payload.Profile,
+		ProblemID:     payload.ProblemID,
+		Tests:         payload.Tests,
+		Limits:        payload.Limits,
+		Checker:       payload.Checker,
+		RunTestSuite:  payload.RunTestSuite,
+		BuildContents: *buildContents,
+	})
+	w.Header().Set("Location", "/deploy/"+job.ID())
+	writeJSON(w, http.StatusAccepted, job.Status())
+}
+
+func handleDeployStatus(w http.ResponseWriter, r *http.Request, jobs *deployjobs.Store) {
+	job, ok := jobs.Get(r.PathValue("id"))
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, "job_not_found", "deploy job not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, job.Status())
+}
+
+func handleDeployCancel(w http.ResponseWriter, r *http.Request, jobs *deployjobs.Store) {
+	job, ok := jobs.Get(r.PathValue("id"))
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, "job_not_found", "deploy job not found")
+		return
+	}
+	if job.Status().Stage.Terminal() {
+		writeJSONError(w, http.StatusConflict, "job_finished", "deploy job already finished")
+		return
+	}
+	job.Cancel()
+	writeJSON(w, http.StatusAccepted, job.Status())
+}
+
+// handleDeployLogs streams a job's events as Server-Sent Events.
+func handleDeployLogs(w http.ResponseWriter, r *http.Request, jobs *deployjobs.Store) {
+	job, ok := jobs.Get(r.PathValue("id"))
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, "job_not_found", "deploy job not found")
+		return
+	}
+	if err := job.ServeEvents(w, r); errors.Is(err, deployjobs.ErrStreamingUnsupported) {
+		writeJSONError(w, http.StatusInternalServerError, "streaming_unsupported", "streaming unsupported")
+	}
+}
+
+// handleContainerLogs writes a sandbox's output as plain text, following it
+// while the container runs when follow=true. tail limits how many recent
+// lines are sent first.
+func handleContainerLogs(w http.ResponseWriter, r *http.Request) {
+	follow, _ := strconv.ParseBool(r.URL.Query().Get("follow"))
+	var tailLines int64
+	if raw := r.URL.Query().Get("tail"); raw != "" {
+		parsed, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || parsed < 0 {
+			writeJSONError(w, http.StatusBadRequest, "invalid_tail", "tail must be a non-negative integer")
+			return
+		}
+		tailLines = parsed
+	}
+
+	name := r.PathValue("name")
+	logs, err := mcptransport.ContainerLogs(r.Context(), name, mcptransport.LogOptions{
+		Follow:    follow,
+		TailLines: tailLines,
+	})
+	if errors.Is(err, mcptransport.ErrContainerNotFound) {
+		writeJSONError(w, http.StatusNotFound, "container_not_found", "container not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to read logs for %s: %v", name, err)
+		writeJSONError(w, http.StatusBadGateway, "logs_failed", err.Error())
+		return
+	}
+	defer logs.Close()
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	buffer := make([]byte, 32*1024)
+	for {
+		n, err := logs.Read(buffer)
+		if n > 0 {
+			if _, writeErr := w.Write(buffer[:n]); writeErr != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		if err != nil {
+			if err != io.EOF && r.Context().Err() == nil {
+				log.Printf("Log stream for %s ended: %v", name, err)
+			}
+			return
+		}
+	}
+}
+
+func handleShutdown(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+		return
+	}
+
+	var payload shutdownRequest
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_json", "invalid json body")
+		return
+	}
+	shutdownContainer(w, r, payload)
+}
+
+func handleDeleteContainer(w http.ResponseWriter, r *http.Request) {
+	deleteImage, _ := strconv.ParseBool(r.URL.Query().Get("delete_image"))
+	shutdownContainer(w, r, shutdownRequest{
+		ContainerID: r.PathValue("name"),
+		DeleteImage: deleteImage,
+		ImageName:   r.URL.Query().Get("image_name"),
+	})
+}
+
+func shutdownContainer(w http.ResponseWriter, r *http.Request, payload shutdownRequest) {
+	containerName := strings.TrimSpace(payload.ContainerID)
+	if containerName == "" {
+		writeJSONError(w, http.StatusBadRequest, "missing_container_id", "container_id is required")
+		return
+	}
+
+	_, output, err := mcptransport.ShutdownContainer(
 		r.Context(),
 		nil,
-		mcptransport.Input{
-			DockerFile:    payload.DockerFile,
-			BuildContents: buildContents,
+		mcptransport.ShutdownInput{
+			ContainerID: containerName,
+			DeleteImage: payload.DeleteImage,
+			ImageName:   payload.ImageName,
 		},
 	)
+	if errors.Is(err, mcptransport.ErrContainerNotFound) {
+		writeJSONError(w, http.StatusNotFound, "container_not_found", "container not found")
+		return
+	}
+	if errors.Is(err, mcptransport.ErrImageNotAllowed) {
+		writeJSONError(w, http.StatusBadRequest, "image_not_allowed", err.Error())
+		return
+	}
 	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		_ = json.NewEncoder(w).Encode(deployResponse{
-			BuildLogs:   output.BuildLogs,
-			BuildFailed: output.BuildFailed,
-		})
+		log.Printf("Failed to shut down container %s: %v", containerName, err)
+		writeJSONError(w, http.StatusBadGateway, "shutdown_failed", err.Error())
 		return
 	}
 
+	writeJSON(w, http.StatusOK, shutdownResponse{
+		ContainerName:  containerName,
+		Message:        output.Message,
+		AlreadyDeleted: output.AlreadyDeleted,
+		ImageDeleted:   output.ImageDeleted,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(deployResponse{
-		ContainerName: output.ContainerName,
-		ContainerID:   output.ContainerID,
-		ImageName:     output.ImageName,
-		ImageID:       output.ImageID,
-		BuildLogs:     output.BuildLogs,
-		BuildFailed:   output.BuildFailed,
-	}); err != nil {
-		log.Printf("Failed to write deploy response: %v", err)
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Printf("Failed to write response: %v", err)
 	}
 }
 
+func writeJSONError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, errorResponse{Code: code, Error: message})
+}
+
 func logBuildContext(tarBytes []byte) {
 	tr := tar.NewReader(bytes.NewReader(tarBytes))
 	for {
@@ -191,11 +471,25 @@ func logBuildContext(tarBytes []byte) {
 func main() {
 	ctx := context.Background()
 
-	a2aServerAddress := startJudgeAgentServer()
+	if err := profiles.Load(); err != nil {
+		log.Fatalf("Failed to load profiles: %v", err)
+	}
+	if _, err := dockerpolicy.Active(); err != nil {
+		log.Fatalf("Failed to load the Dockerfile policy: %v", err)
+	}
+	if _, err := sandboxpolicy.Active(); err != nil {
+		log.Fatalf("Failed to load the sandbox policy: %v", err)
+	}
+
+	token := agentToken()
+	a2aServerAddress := startJudgeAgentServer(token)
 
 	remoteAgent, err := remoteagent.NewA2A(remoteagent.A2AConfig{
 		Name:            "A2A Judge Orchestrator",
-		AgentCardSource: a2aServerAddress,
+		AgentCardSource: a2aServerAddress + orchestratorPath,
+		ClientFactory: a2aclient.NewFactory(a2aclient.WithJSONRPCTransport(&http.Client{
+			Transport: bearerTransport{token: token},
+		})),
 	})
 	if err != nil {
 		log.Fatalf("Failed to create a remote agent: %v", err)